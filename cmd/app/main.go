@@ -12,6 +12,9 @@ func main() {
 	routes.RegisterRoutes()
 
 	go routes.BookingJob.RunCheckBookingStatus()
+	go routes.PaymentJob.RunReconciliation()
+	go routes.PayoutJob.RunSyncPayouts()
+	go routes.SubscriptionJob.RunSubscriptionRenewal()
 
 	routes.FiberApp.Listen(":3000")
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/agilistikmal/parkingo-core/internal/app/routes"
 	"github.com/agilistikmal/parkingo-core/internal/app/services"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/database"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/disbursement"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/fiberapp"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/validation"
@@ -21,23 +22,44 @@ func InjectRoutes() *routes.Route {
 		fiberapp.NewFiberApp,
 		database.NewDatabase,
 		validation.New,
-		paymentgateway.NewXendit,
+		paymentgateway.NewPaymentProvider,
+		disbursement.NewDisbursementProvider,
 
 		services.NewMailService,
 		services.NewAuthService,
 		services.NewUserService,
 		services.NewJWTService,
+		services.NewLedgerService,
 		services.NewParkingService,
+		services.NewPricingService,
+		services.NewBookingLifecycle,
 		services.NewBookingService,
+		services.NewPaymentService,
+		services.NewPayoutService,
+		services.NewCommissionService,
+		services.NewVoucherService,
+		services.NewSubscriptionService,
+		services.NewParkingSessionService,
 
 		controllers.NewAuthController,
 		controllers.NewUserController,
 		controllers.NewParkingController,
 		controllers.NewBookingController,
+		controllers.NewPricingController,
+		controllers.NewPaymentController,
+		controllers.NewPayoutController,
+		controllers.NewCommissionController,
+		controllers.NewVoucherController,
+		controllers.NewSubscriptionController,
+		controllers.NewParkingSessionController,
 
 		jobs.NewBookingJob,
+		jobs.NewPaymentJob,
+		jobs.NewPayoutJob,
+		jobs.NewSubscriptionJob,
 
 		middlewares.NewAuthMiddleware,
+		middlewares.NewDeviceMiddleware,
 		routes.NewRoute,
 	)
 
@@ -13,6 +13,7 @@ import (
 	"github.com/agilistikmal/parkingo-core/internal/app/routes"
 	"github.com/agilistikmal/parkingo-core/internal/app/services"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/database"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/disbursement"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/fiberapp"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
 	"github.com/agilistikmal/parkingo-core/internal/infrastructure/validation"
@@ -30,13 +31,34 @@ func InjectRoutes() *routes.Route {
 	authService := services.NewAuthService(jwtService)
 	authController := controllers.NewAuthController(jwtService, authService, userService)
 	userController := controllers.NewUserController(userService)
-	parkingService := services.NewParkingService(db, validate)
+	ledgerService := services.NewLedgerService(db)
+	paymentProvider := paymentgateway.NewPaymentProvider()
+	pricingService := services.NewPricingService(db, validate)
+	parkingSessionService := services.NewParkingSessionService(db, validate, paymentProvider, pricingService, ledgerService)
+	parkingService := services.NewParkingService(db, validate, ledgerService, parkingSessionService)
+	deviceMiddleware := middlewares.NewDeviceMiddleware(parkingService)
 	parkingController := controllers.NewParkingController(parkingService)
-	apiClient := paymentgateway.NewXendit()
 	mailService := services.NewMailService()
-	bookingService := services.NewBookingService(db, validate, apiClient, mailService)
+	bookingLifecycle := services.NewBookingLifecycle(db, mailService, ledgerService)
+	bookingService := services.NewBookingService(db, validate, paymentProvider, mailService, bookingLifecycle, pricingService, parkingSessionService)
 	bookingController := controllers.NewBookingController(bookingService, parkingService, userService)
+	pricingController := controllers.NewPricingController(pricingService)
+	subscriptionService := services.NewSubscriptionService(db, validate, paymentProvider, ledgerService, mailService)
+	paymentService := services.NewPaymentService(db, paymentProvider, bookingService, subscriptionService, parkingSessionService)
+	paymentController := controllers.NewPaymentController(paymentService)
+	disbursementProvider := disbursement.NewDisbursementProvider()
+	payoutService := services.NewPayoutService(db, validate, disbursementProvider, ledgerService, mailService)
+	payoutController := controllers.NewPayoutController(payoutService)
+	commissionService := services.NewCommissionService(db, validate)
+	commissionController := controllers.NewCommissionController(commissionService)
+	voucherService := services.NewVoucherService(db, validate)
+	voucherController := controllers.NewVoucherController(voucherService)
+	subscriptionController := controllers.NewSubscriptionController(subscriptionService)
+	parkingSessionController := controllers.NewParkingSessionController(parkingSessionService)
 	bookingJob := jobs.NewBookingJob(bookingService, parkingService)
-	route := routes.NewRoute(app, authMiddleware, authController, userController, parkingController, bookingController, bookingJob)
+	paymentJob := jobs.NewPaymentJob(paymentService)
+	payoutJob := jobs.NewPayoutJob(payoutService)
+	subscriptionJob := jobs.NewSubscriptionJob(subscriptionService)
+	route := routes.NewRoute(app, authMiddleware, deviceMiddleware, authController, userController, parkingController, bookingController, pricingController, paymentController, payoutController, commissionController, voucherController, subscriptionController, parkingSessionController, bookingJob, paymentJob, payoutJob, subscriptionJob)
 	return route
 }
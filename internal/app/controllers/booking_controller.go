@@ -5,8 +5,6 @@ import (
 	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
 	"github.com/agilistikmal/parkingo-core/internal/app/services"
 	"github.com/gofiber/fiber/v2"
-	"github.com/spf13/viper"
-	"github.com/xendit/xendit-go/v6/invoice"
 )
 
 type BookingController struct {
@@ -27,7 +25,7 @@ func (c *BookingController) GetBookings(ctx *fiber.Ctx) error {
 	authUser := ctx.Locals("user").(*models.User)
 
 	filter := &models.BookingFilter{
-		UserID:    ctx.QueryInt("user_id", authUser.ID),
+		UserID:    ctx.QueryInt("user_id", 0),
 		Page:      ctx.QueryInt("page", 1),
 		Limit:     ctx.QueryInt("limit", 10),
 		SortBy:    ctx.Query("sort_by", "created_at"),
@@ -35,10 +33,25 @@ func (c *BookingController) GetBookings(ctx *fiber.Ctx) error {
 		ParkingID: ctx.QueryInt("parking_id", 0),
 	}
 
-	if filter.UserID != authUser.ID {
-		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"message": "You are not allowed to access this resource",
-		})
+	// The author of a parking sees the bookings of every user of it, optionally those of a single user
+	isAuthor := false
+	if filter.ParkingID != 0 {
+		parking, err := c.ParkingService.GetParkingByID(filter.ParkingID)
+		if err != nil {
+			return pkg.HandlerError(ctx, err)
+		}
+		isAuthor = parking.AuthorID == authUser.ID
+	}
+
+	if !isAuthor {
+		if filter.UserID == 0 {
+			filter.UserID = authUser.ID
+		}
+		if filter.UserID != authUser.ID {
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "You are not allowed to access this resource",
+			})
+		}
 	}
 
 	bookings, err := c.BookingService.GetBookings(filter)
@@ -71,7 +84,48 @@ func (c *BookingController) GetBookingsAdmin(ctx *fiber.Ctx) error {
 	})
 }
 
+func (c *BookingController) GetRefunds(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	filter := &models.RefundFilter{
+		UserID:    authUser.ID,
+		BookingID: ctx.QueryInt("booking_id", 0),
+		Page:      ctx.QueryInt("page", 1),
+		Limit:     ctx.QueryInt("limit", 10),
+	}
+
+	refunds, err := c.BookingService.GetRefunds(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": refunds,
+	})
+}
+
+func (c *BookingController) GetRefundsAdmin(ctx *fiber.Ctx) error {
+	filter := &models.RefundFilter{
+		UserID:    ctx.QueryInt("user_id", 0),
+		ParkingID: ctx.QueryInt("parking_id", 0),
+		BookingID: ctx.QueryInt("booking_id", 0),
+		Page:      ctx.QueryInt("page", 1),
+		Limit:     ctx.QueryInt("limit", 10),
+	}
+
+	refunds, err := c.BookingService.GetRefunds(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": refunds,
+	})
+}
+
 func (c *BookingController) GetBookingByID(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
 	id, err := ctx.ParamsInt("id")
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -84,12 +138,38 @@ func (c *BookingController) GetBookingByID(ctx *fiber.Ctx) error {
 		return pkg.HandlerError(ctx, err)
 	}
 
+	if !models.CanAccessBooking(authUser, booking, models.BookingAccessView) {
+		return pkg.HandlerError(ctx, pkg.NewForbiddenError("You are not allowed to view this booking"))
+	}
+
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"data": booking,
 	})
 }
 
+func (c *BookingController) GetBookingTimeline(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid booking ID",
+		})
+	}
+
+	events, err := c.BookingService.GetBookingTimeline(authUser, id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": events,
+	})
+}
+
 func (c *BookingController) GetBookingByReference(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
 	reference := ctx.Params("reference")
 	if reference == "" {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -100,6 +180,9 @@ func (c *BookingController) GetBookingByReference(ctx *fiber.Ctx) error {
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
+	if !models.CanAccessBooking(authUser, booking, models.BookingAccessView) {
+		return pkg.HandlerError(ctx, pkg.NewForbiddenError("You are not allowed to view this booking"))
+	}
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"data": booking,
 	})
@@ -125,84 +208,271 @@ func (c *BookingController) CreateBooking(ctx *fiber.Ctx) error {
 	})
 }
 
-func (c *BookingController) UpdateBooking(ctx *fiber.Ctx) error {
-	id, err := ctx.ParamsInt("id")
+func (c *BookingController) QuoteBooking(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	var req *models.CreateBookingRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	quote, err := c.BookingService.QuoteBooking(authUser.ID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": quote,
+	})
+}
+
+func (c *BookingController) HoldSlot(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	parkingID, err := ctx.ParamsInt("id")
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid booking ID",
+			"message": "Invalid parking ID",
+		})
+	}
+
+	slotID, err := ctx.ParamsInt("slot_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid slot ID",
 		})
 	}
 
-	var req *models.UpdateBookingRequest
+	var req *models.CreateSlotHoldRequest
 	if err := ctx.BodyParser(&req); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid request body",
 		})
 	}
 
-	booking, err := c.BookingService.UpdateBooking(id, req)
+	hold, err := c.BookingService.HoldSlot(authUser.ID, parkingID, slotID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": hold,
+	})
+}
+
+func (c *BookingController) ReleaseSlotHold(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	slotID, err := ctx.ParamsInt("slot_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid slot ID",
+		})
+	}
+
+	err = c.BookingService.ReleaseSlotHold(authUser.ID, slotID, ctx.Params("token"))
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"data": booking,
+		"message": "Slot hold released successfully",
 	})
 }
 
-func (c *BookingController) DeleteBooking(ctx *fiber.Ctx) error {
+func (c *BookingController) GetBookingSeriesByID(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
 	id, err := ctx.ParamsInt("id")
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid booking ID",
+			"message": "Invalid booking series ID",
 		})
 	}
 
-	err = c.BookingService.DeleteBooking(id)
+	series, err := c.BookingService.GetBookingSeriesByID(id)
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
 
-	return ctx.Status(fiber.StatusNoContent).JSON(nil)
+	if series.UserID != authUser.ID && !authUser.IsAdmin() {
+		return pkg.HandlerError(ctx, pkg.NewForbiddenError("You are not allowed to view this booking series"))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": series,
+	})
 }
 
-func (c *BookingController) PaymentCallback(ctx *fiber.Ctx) error {
-	callbackToken := ctx.Get("X-CALLBACK-TOKEN")
-	if callbackToken == "" {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized",
+func (c *BookingController) CreateBookingSeries(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	var req *models.CreateBookingSeriesRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
 		})
 	}
 
-	// Verify the callback token
-	if callbackToken != viper.GetString("xendit.callbak_token") {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized",
+	series, err := c.BookingService.CreateBookingSeries(authUser.ID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": series,
+	})
+}
+
+func (c *BookingController) CancelBookingSeries(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid booking series ID",
 		})
 	}
 
-	var req invoice.InvoiceCallback
+	req := new(models.CancelBookingRequest)
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(req); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	series, err := c.BookingService.CancelBookingSeries(authUser.ID, id, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": series,
+	})
+}
+
+func (c *BookingController) ExtendBooking(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid booking ID",
+		})
+	}
+
+	var req *models.ExtendBookingRequest
 	if err := ctx.BodyParser(&req); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid request body",
 		})
 	}
 
-	// Check if the booking exists
-	booking, err := c.BookingService.GetBookingByReference(req.ExternalId)
+	extension, err := c.BookingService.ExtendBooking(authUser.ID, id, req)
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
 
-	// Update the booking in the database
-	booking, err = c.BookingService.UpdateBooking(booking.ID, &models.UpdateBookingRequest{
-		Status: req.Status,
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": extension,
 	})
+}
+
+func (c *BookingController) CancelBooking(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid booking ID",
+		})
+	}
+
+	req := new(models.CancelBookingRequest)
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(req); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	booking, err := c.BookingService.CancelBooking(authUser.ID, id, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": booking,
+	})
+}
+
+func (c *BookingController) UpdateBooking(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid booking ID",
+		})
+	}
+
+	booking, err := c.BookingService.GetBookingByID(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	if !models.CanAccessBooking(authUser, booking, models.BookingAccessOwn) {
+		return pkg.HandlerError(ctx, pkg.NewForbiddenError("You are not allowed to update this booking"))
+	}
+
+	// Only admins may override the fees, times and status of a booking
+	if models.CanAccessBooking(authUser, booking, models.BookingAccessOverride) {
+		var req *models.AdminUpdateBookingRequest
+		if err := ctx.BodyParser(&req); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+
+		booking, err = c.BookingService.AdminUpdateBooking(authUser, id, req)
+	} else {
+		var req *models.UpdateBookingRequest
+		if err := ctx.BodyParser(&req); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+
+		booking, err = c.BookingService.UpdateBooking(authUser, id, req)
+	}
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": booking,
+	})
+}
+
+func (c *BookingController) DeleteBooking(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid booking ID",
+		})
+	}
+
+	err = c.BookingService.DeleteBooking(id)
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(nil)
+	return ctx.Status(fiber.StatusNoContent).JSON(nil)
 }
 
 func (c *BookingController) ValidateBooking(ctx *fiber.Ctx) error {
@@ -224,6 +494,8 @@ func (c *BookingController) ValidateBooking(ctx *fiber.Ctx) error {
 }
 
 func (c *BookingController) Checkout(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
 	reference := ctx.Params("reference")
 	if reference == "" {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -231,7 +503,7 @@ func (c *BookingController) Checkout(ctx *fiber.Ctx) error {
 		})
 	}
 
-	booking, err := c.BookingService.Checkout(reference)
+	booking, err := c.BookingService.Checkout(authUser, reference)
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
@@ -242,6 +514,8 @@ func (c *BookingController) Checkout(ctx *fiber.Ctx) error {
 }
 
 func (c *BookingController) CheckoutWithPlateNumber(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
 	plateNumber := ctx.Params("plate_number")
 	if plateNumber == "" {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -249,7 +523,7 @@ func (c *BookingController) CheckoutWithPlateNumber(ctx *fiber.Ctx) error {
 		})
 	}
 
-	booking, err := c.BookingService.CheckoutWithPlateNumber(plateNumber)
+	booking, err := c.BookingService.CheckoutWithPlateNumber(authUser, plateNumber)
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CommissionController struct {
+	CommissionService *services.CommissionService
+}
+
+func NewCommissionController(commissionService *services.CommissionService) *CommissionController {
+	return &CommissionController{
+		CommissionService: commissionService,
+	}
+}
+
+func (c *CommissionController) GetCommissionPlans(ctx *fiber.Ctx) error {
+	plans, err := c.CommissionService.GetCommissionPlans()
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": plans,
+	})
+}
+
+func (c *CommissionController) GetCommissionPlanByID(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid commission plan ID",
+		})
+	}
+
+	plan, err := c.CommissionService.GetCommissionPlanByID(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": plan,
+	})
+}
+
+func (c *CommissionController) CreateCommissionPlan(ctx *fiber.Ctx) error {
+	var req *models.CreateCommissionPlanRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	plan, err := c.CommissionService.CreateCommissionPlan(req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": plan,
+	})
+}
+
+func (c *CommissionController) UpdateCommissionPlan(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid commission plan ID",
+		})
+	}
+
+	var req *models.UpdateCommissionPlanRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	plan, err := c.CommissionService.UpdateCommissionPlan(id, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": plan,
+	})
+}
+
+func (c *CommissionController) DeleteCommissionPlan(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid commission plan ID",
+		})
+	}
+
+	err = c.CommissionService.DeleteCommissionPlan(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Commission plan deleted successfully",
+	})
+}
+
+func (c *CommissionController) GetRevenueReport(ctx *fiber.Ctx) error {
+	filter := &models.RevenueReportFilter{
+		ParkingID: ctx.QueryInt("parking_id"),
+	}
+
+	if ctx.Query("from") != "" {
+		from, err := time.Parse(time.RFC3339, ctx.Query("from"))
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid from, expected RFC3339 time",
+			})
+		}
+		filter.From = from
+	}
+
+	if ctx.Query("to") != "" {
+		to, err := time.Parse(time.RFC3339, ctx.Query("to"))
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid to, expected RFC3339 time",
+			})
+		}
+		filter.To = to
+	}
+
+	report, err := c.CommissionService.GetRevenueReport(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": report,
+	})
+}
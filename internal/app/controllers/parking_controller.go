@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"time"
+
 	"github.com/agilistikmal/parkingo-core/internal/app/models"
 	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
 	"github.com/agilistikmal/parkingo-core/internal/app/services"
@@ -85,6 +87,38 @@ func (c *ParkingController) GetParkingBySlug(ctx *fiber.Ctx) error {
 	})
 }
 
+func (c *ParkingController) GetParkingAvailability(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	startAt, err := time.Parse(time.RFC3339, ctx.Query("start_at"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid start_at, expected RFC3339 time",
+		})
+	}
+
+	endAt, err := time.Parse(time.RFC3339, ctx.Query("end_at"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid end_at, expected RFC3339 time",
+		})
+	}
+
+	availability, err := c.ParkingService.GetParkingAvailability(id, startAt, endAt)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": availability,
+	})
+}
+
 func (c *ParkingController) CreateParking(ctx *fiber.Ctx) error {
 	authUser := ctx.Locals("user").(*models.User)
 
@@ -171,12 +205,39 @@ func (c *ParkingController) SyncParking(ctx *fiber.Ctx) error {
 		})
 	}
 
-	err = c.ParkingService.SyncParking(id)
+	report, err := c.ParkingService.SyncParking(id)
 	if err != nil {
 		return pkg.HandlerError(ctx, err)
 	}
 
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Parking synced successfully",
+		"data":    report,
+	})
+}
+
+func (c *ParkingController) GetParkingLedger(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	filter := &models.LedgerEntryFilter{
+		BookingID: ctx.QueryInt("booking_id", 0),
+		Account:   ctx.Query("account"),
+		Type:      ctx.Query("type"),
+		Page:      ctx.QueryInt("page", 1),
+		Limit:     ctx.QueryInt("limit", 50),
+	}
+
+	entries, err := c.ParkingService.GetParkingLedger(id, filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": entries,
 	})
 }
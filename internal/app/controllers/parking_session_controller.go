@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ParkingSessionController struct {
+	ParkingSessionService *services.ParkingSessionService
+}
+
+func NewParkingSessionController(parkingSessionService *services.ParkingSessionService) *ParkingSessionController {
+	return &ParkingSessionController{
+		ParkingSessionService: parkingSessionService,
+	}
+}
+
+func (c *ParkingSessionController) GetParkingSessions(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	sessions, err := c.ParkingSessionService.GetParkingSessions(authUser, &models.ParkingSessionFilter{
+		ParkingID:   parkingID,
+		BookingID:   ctx.QueryInt("booking_id"),
+		PlateNumber: ctx.Query("plate_number"),
+		Status:      ctx.Query("status"),
+		Source:      ctx.Query("source"),
+		Page:        ctx.QueryInt("page", 1),
+		Limit:       ctx.QueryInt("limit", 10),
+	})
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": sessions,
+	})
+}
+
+func (c *ParkingSessionController) GetParkingSessionByReference(ctx *fiber.Ctx) error {
+	session, err := c.ParkingSessionService.GetParkingSessionByReference(ctx.Params("reference"))
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": session,
+	})
+}
+
+func (c *ParkingSessionController) CheckoutParkingSession(ctx *fiber.Ctx) error {
+	session, err := c.ParkingSessionService.CheckoutParkingSession(ctx.Params("plate_number"))
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": session,
+	})
+}
+
+func (c *ParkingSessionController) ReissueInvoice(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking session ID",
+		})
+	}
+
+	session, err := c.ParkingSessionService.ReissueInvoice(authUser, id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": session,
+	})
+}
+
+func (c *ParkingSessionController) GetOccupancyReport(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	filter := &models.OccupancyReportFilter{
+		ParkingID: parkingID,
+	}
+
+	if ctx.Query("from") != "" {
+		from, err := time.Parse(time.RFC3339, ctx.Query("from"))
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid from, expected RFC3339 time",
+			})
+		}
+		filter.From = from
+	}
+
+	if ctx.Query("to") != "" {
+		to, err := time.Parse(time.RFC3339, ctx.Query("to"))
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid to, expected RFC3339 time",
+			})
+		}
+		filter.To = to
+	}
+
+	report, err := c.ParkingSessionService.GetOccupancyReport(authUser, filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": report,
+	})
+}
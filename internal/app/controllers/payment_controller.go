@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/agilistikmal/parkingo-core/internal/app/templates"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PaymentController struct {
+	PaymentService *services.PaymentService
+}
+
+func NewPaymentController(paymentService *services.PaymentService) *PaymentController {
+	return &PaymentController{
+		PaymentService: paymentService,
+	}
+}
+
+func (c *PaymentController) PaymentCallback(ctx *fiber.Ctx) error {
+	_, err := c.PaymentService.HandleWebhook(http.Header(ctx.GetReqHeaders()), ctx.Body())
+	if err != nil {
+		if errors.Is(err, paymentgateway.ErrInvalidWebhookToken) {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Unauthorized",
+			})
+		}
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(nil)
+}
+
+func (c *PaymentController) GetPaymentEvents(ctx *fiber.Ctx) error {
+	filter := &models.PaymentEventFilter{
+		ProcessingStatus: ctx.Query("processing_status"),
+		InvoiceID:        ctx.Query("invoice_id"),
+		ExternalID:       ctx.Query("external_id"),
+		Page:             ctx.QueryInt("page", 1),
+		Limit:            ctx.QueryInt("limit", 10),
+	}
+
+	paymentEvents, err := c.PaymentService.GetPaymentEvents(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": paymentEvents,
+	})
+}
+
+func (c *PaymentController) GetPaymentEventByID(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid payment event ID",
+		})
+	}
+
+	paymentEvent, err := c.PaymentService.GetPaymentEventByID(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": paymentEvent,
+	})
+}
+
+func (c *PaymentController) ReplayPaymentEvent(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid payment event ID",
+		})
+	}
+
+	paymentEvent, err := c.PaymentService.ReplayPaymentEvent(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": paymentEvent,
+	})
+}
+
+func (c *PaymentController) GetLatestReconciliationReport(ctx *fiber.Ctx) error {
+	report, err := c.PaymentService.GetLatestReconciliationReport()
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": report,
+	})
+}
+
+func (c *PaymentController) Reconcile(ctx *fiber.Ctx) error {
+	report, err := c.PaymentService.Reconcile(time.Duration(ctx.QueryInt("hours", 48)) * time.Hour)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": report,
+	})
+}
+
+// FakePayment shows an invoice of the fake provider, the page its invoice links point to.
+func (c *PaymentController) FakePayment(ctx *fiber.Ctx) error {
+	paymentInvoice, err := c.PaymentService.GetFakeInvoice(ctx.Params("id"))
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return renderFakePayment(ctx, paymentInvoice)
+}
+
+func (c *PaymentController) PayFakeInvoice(ctx *fiber.Ctx) error {
+	_, err := c.PaymentService.PayFakeInvoice(ctx.Params("id"))
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return c.FakePayment(ctx)
+}
+
+func renderFakePayment(ctx *fiber.Ctx, paymentInvoice *paymentgateway.Invoice) error {
+	tmpl, err := template.ParseFS(templates.FakePaymentTemplateFS, "fake_payment.html")
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	ctx.Type("html")
+	return tmpl.Execute(ctx.Response().BodyWriter(), paymentInvoice)
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PayoutController struct {
+	PayoutService *services.PayoutService
+}
+
+func NewPayoutController(payoutService *services.PayoutService) *PayoutController {
+	return &PayoutController{
+		PayoutService: payoutService,
+	}
+}
+
+func (c *PayoutController) GetBankAccounts(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	bankAccounts, err := c.PayoutService.GetBankAccounts(authUser.ID)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": bankAccounts,
+	})
+}
+
+func (c *PayoutController) CreateBankAccount(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	var req *models.CreateBankAccountRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	bankAccount, err := c.PayoutService.CreateBankAccount(authUser.ID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": bankAccount,
+	})
+}
+
+func (c *PayoutController) DeleteBankAccount(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid bank account ID",
+		})
+	}
+
+	err = c.PayoutService.DeleteBankAccount(authUser.ID, id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Bank account deleted",
+	})
+}
+
+func (c *PayoutController) GetPayouts(ctx *fiber.Ctx) error {
+	filter := &models.PayoutFilter{
+		ParkingID: ctx.QueryInt("parking_id"),
+		UserID:    ctx.QueryInt("user_id"),
+		Status:    ctx.Query("status"),
+		Page:      ctx.QueryInt("page", 1),
+		Limit:     ctx.QueryInt("limit", 10),
+	}
+
+	payouts, err := c.PayoutService.GetPayouts(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": payouts,
+	})
+}
+
+func (c *PayoutController) GetParkingPayouts(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	filter := &models.PayoutFilter{
+		ParkingID: id,
+		Status:    ctx.Query("status"),
+		Page:      ctx.QueryInt("page", 1),
+		Limit:     ctx.QueryInt("limit", 10),
+	}
+	// Owners only see the payouts they requested
+	if !authUser.IsAdmin() {
+		filter.UserID = authUser.ID
+	}
+
+	payouts, err := c.PayoutService.GetPayouts(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": payouts,
+	})
+}
+
+func (c *PayoutController) GetPayoutByID(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid payout ID",
+		})
+	}
+
+	payout, err := c.PayoutService.GetPayoutByID(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	if payout.UserID != authUser.ID && !authUser.IsAdmin() {
+		return pkg.HandlerError(ctx, pkg.NewForbiddenError("You are not allowed to view this payout"))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": payout,
+	})
+}
+
+func (c *PayoutController) RequestPayout(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	var req *models.CreatePayoutRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	payout, err := c.PayoutService.RequestPayout(authUser, id, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": payout,
+	})
+}
+
+func (c *PayoutController) ApprovePayout(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid payout ID",
+		})
+	}
+
+	req := new(models.ReviewPayoutRequest)
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(req); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	payout, err := c.PayoutService.ApprovePayout(authUser.ID, id, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": payout,
+	})
+}
+
+func (c *PayoutController) RejectPayout(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid payout ID",
+		})
+	}
+
+	req := new(models.ReviewPayoutRequest)
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(req); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	payout, err := c.PayoutService.RejectPayout(authUser.ID, id, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": payout,
+	})
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PricingController struct {
+	PricingService *services.PricingService
+}
+
+func NewPricingController(pricingService *services.PricingService) *PricingController {
+	return &PricingController{
+		PricingService: pricingService,
+	}
+}
+
+func (c *PricingController) GetPricingRules(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	rules, err := c.PricingService.GetPricingRules(parkingID)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": rules,
+	})
+}
+
+func (c *PricingController) CreatePricingRule(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	var req *models.CreatePricingRuleRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	rule, err := c.PricingService.CreatePricingRule(parkingID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": rule,
+	})
+}
+
+func (c *PricingController) UpdatePricingRule(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	ruleID, err := ctx.ParamsInt("rule_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid pricing rule ID",
+		})
+	}
+
+	var req *models.UpdatePricingRuleRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	rule, err := c.PricingService.UpdatePricingRule(parkingID, ruleID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": rule,
+	})
+}
+
+func (c *PricingController) DeletePricingRule(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	ruleID, err := ctx.ParamsInt("rule_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid pricing rule ID",
+		})
+	}
+
+	err = c.PricingService.DeletePricingRule(parkingID, ruleID)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Pricing rule deleted successfully",
+	})
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SubscriptionController struct {
+	SubscriptionService *services.SubscriptionService
+}
+
+func NewSubscriptionController(subscriptionService *services.SubscriptionService) *SubscriptionController {
+	return &SubscriptionController{
+		SubscriptionService: subscriptionService,
+	}
+}
+
+func (c *SubscriptionController) GetSubscriptionPlans(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	plans, err := c.SubscriptionService.GetSubscriptionPlans(parkingID)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": plans,
+	})
+}
+
+func (c *SubscriptionController) CreateSubscriptionPlan(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	var req *models.CreateSubscriptionPlanRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	plan, err := c.SubscriptionService.CreateSubscriptionPlan(parkingID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": plan,
+	})
+}
+
+func (c *SubscriptionController) UpdateSubscriptionPlan(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	planID, err := ctx.ParamsInt("plan_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid subscription plan ID",
+		})
+	}
+
+	var req *models.UpdateSubscriptionPlanRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	plan, err := c.SubscriptionService.UpdateSubscriptionPlan(parkingID, planID, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": plan,
+	})
+}
+
+func (c *SubscriptionController) DeleteSubscriptionPlan(ctx *fiber.Ctx) error {
+	parkingID, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid parking ID",
+		})
+	}
+
+	planID, err := ctx.ParamsInt("plan_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid subscription plan ID",
+		})
+	}
+
+	err = c.SubscriptionService.DeleteSubscriptionPlan(parkingID, planID)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Subscription plan deleted successfully",
+	})
+}
+
+func (c *SubscriptionController) GetSubscriptions(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	filter := &models.SubscriptionFilter{
+		UserID:    authUser.ID,
+		ParkingID: ctx.QueryInt("parking_id", 0),
+		Status:    ctx.Query("status"),
+		Page:      ctx.QueryInt("page", 1),
+		Limit:     ctx.QueryInt("limit", 10),
+	}
+	if authUser.IsAdmin() {
+		filter.UserID = ctx.QueryInt("user_id", 0)
+	}
+
+	subscriptions, err := c.SubscriptionService.GetSubscriptions(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": subscriptions,
+	})
+}
+
+func (c *SubscriptionController) GetSubscriptionByID(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid subscription ID",
+		})
+	}
+
+	subscription, err := c.SubscriptionService.GetSubscriptionByID(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	if subscription.UserID != authUser.ID && !authUser.IsAdmin() {
+		return pkg.HandlerError(ctx, pkg.NewForbiddenError("You are not allowed to view this subscription"))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": subscription,
+	})
+}
+
+func (c *SubscriptionController) CreateSubscription(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	var req *models.CreateSubscriptionRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	subscription, err := c.SubscriptionService.Subscribe(authUser, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": subscription,
+	})
+}
+
+func (c *SubscriptionController) RenewSubscription(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid subscription ID",
+		})
+	}
+
+	invoice, err := c.SubscriptionService.RenewSubscription(authUser, id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": invoice,
+	})
+}
+
+func (c *SubscriptionController) CancelSubscription(ctx *fiber.Ctx) error {
+	authUser := ctx.Locals("user").(*models.User)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid subscription ID",
+		})
+	}
+
+	subscription, err := c.SubscriptionService.CancelSubscription(authUser, id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": subscription,
+	})
+}
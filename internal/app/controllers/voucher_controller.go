@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type VoucherController struct {
+	VoucherService *services.VoucherService
+}
+
+func NewVoucherController(voucherService *services.VoucherService) *VoucherController {
+	return &VoucherController{
+		VoucherService: voucherService,
+	}
+}
+
+func (c *VoucherController) GetVouchers(ctx *fiber.Ctx) error {
+	filter := &models.VoucherFilter{
+		Search: ctx.Query("search"),
+		Page:   ctx.QueryInt("page", 1),
+		Limit:  ctx.QueryInt("limit", 10),
+	}
+
+	vouchers, err := c.VoucherService.GetVouchers(filter)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": vouchers,
+	})
+}
+
+func (c *VoucherController) GetVoucherByID(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid voucher ID",
+		})
+	}
+
+	voucher, err := c.VoucherService.GetVoucherByID(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": voucher,
+	})
+}
+
+func (c *VoucherController) CreateVoucher(ctx *fiber.Ctx) error {
+	var req *models.CreateVoucherRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	voucher, err := c.VoucherService.CreateVoucher(req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data": voucher,
+	})
+}
+
+func (c *VoucherController) UpdateVoucher(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid voucher ID",
+		})
+	}
+
+	var req *models.UpdateVoucherRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	voucher, err := c.VoucherService.UpdateVoucher(id, req)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": voucher,
+	})
+}
+
+func (c *VoucherController) DeleteVoucher(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid voucher ID",
+		})
+	}
+
+	err = c.VoucherService.DeleteVoucher(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Voucher deleted successfully",
+	})
+}
+
+func (c *VoucherController) GetVoucherStats(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid voucher ID",
+		})
+	}
+
+	stats, err := c.VoucherService.GetVoucherStats(id)
+	if err != nil {
+		return pkg.HandlerError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data": stats,
+	})
+}
@@ -3,7 +3,6 @@ package jobs
 import (
 	"time"
 
-	"github.com/agilistikmal/parkingo-core/internal/app/models"
 	"github.com/agilistikmal/parkingo-core/internal/app/services"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
@@ -25,36 +24,36 @@ func NewBookingJob(bookingService *services.BookingService, parkingService *serv
 }
 
 func (j *BookingJob) checkBookingStatusPAID() {
-	logrus.Info("Checking booking status PAID")
-	bookings, err := j.BookingService.GetBookings(&models.BookingFilter{
-		Status: "PAID",
-	})
+	logrus.Info("Checking ended PAID bookings")
+	settled, err := j.BookingService.SettleEndedBookings()
 	if err != nil {
-		logrus.Error("Failed to get bookings: ", err)
+		logrus.Error("Failed to settle ended bookings: ", err)
 		return
 	}
 
-	logrus.Info("Found ", len(bookings), " bookings")
+	logrus.Info("Settled ", settled, " ended bookings")
+}
 
-	for _, booking := range bookings {
-		// if booking is expired, update status to COMPLETED
-		if booking.Status == "PAID" && booking.EndAt.Before(time.Now()) {
-			logrus.Infof("Updating booking %s status to COMPLETED", booking.PaymentReference)
-			_, err = j.BookingService.UpdateBooking(booking.ID, &models.UpdateBookingRequest{
-				Status: "COMPLETED",
-			})
-			if err != nil {
-				logrus.Error("Failed to update booking: ", err)
-			}
+func (j *BookingJob) expireUnpaidBookings() {
+	logrus.Info("Checking expired UNPAID bookings")
+	reclaimed, err := j.BookingService.ExpireUnpaidBookings()
+	if err != nil {
+		logrus.Error("Failed to expire unpaid bookings: ", err)
+		return
+	}
 
-			_, err = j.ParkingService.UpdateParkingSlot(booking.SlotID, &models.UpdateParkingSlotRequest{
-				Status: "AVAILABLE",
-			})
-			if err != nil {
-				logrus.Error("Failed to update parking slot: ", err)
-			}
-		}
+	logrus.Info("Reclaimed ", reclaimed, " expired bookings")
+}
+
+func (j *BookingJob) markNoShows() {
+	logrus.Info("Checking PAID bookings without arrival")
+	marked, err := j.BookingService.MarkNoShows()
+	if err != nil {
+		logrus.Error("Failed to mark no-show bookings: ", err)
+		return
 	}
+
+	logrus.Info("Marked ", marked, " bookings as no-show")
 }
 
 func (j *BookingJob) RunCheckBookingStatus() {
@@ -65,5 +64,19 @@ func (j *BookingJob) RunCheckBookingStatus() {
 		logrus.Error("Failed to add check booking status to cron: ", err)
 		return
 	}
+
+	logrus.Info("Running expire unpaid bookings every minute")
+	_, err = c.AddFunc("* * * * *", j.expireUnpaidBookings)
+	if err != nil {
+		logrus.Error("Failed to add expire unpaid bookings to cron: ", err)
+		return
+	}
+
+	logrus.Info("Running mark no-show bookings every minute")
+	_, err = c.AddFunc("* * * * *", j.markNoShows)
+	if err != nil {
+		logrus.Error("Failed to add mark no-show bookings to cron: ", err)
+		return
+	}
 	c.Start()
 }
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+type PaymentJob struct {
+	PaymentService *services.PaymentService
+	TimeLocation   *time.Location
+}
+
+func NewPaymentJob(paymentService *services.PaymentService) *PaymentJob {
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	return &PaymentJob{
+		PaymentService: paymentService,
+		TimeLocation:   loc,
+	}
+}
+
+func (j *PaymentJob) reconcilePayments() {
+	logrus.Info("Reconciling payments with the payment gateway")
+	report, err := j.PaymentService.Reconcile(48 * time.Hour)
+	if err != nil {
+		logrus.Error("Failed to reconcile payments: ", err)
+		return
+	}
+
+	logrus.Infof("Checked %d payments: %d fixed, %d amount mismatches, %d unknown invoices, %d errors", report.Checked, report.Fixed, report.AmountMismatches, report.UnknownInvoices, report.Errors)
+}
+
+func (j *PaymentJob) RunReconciliation() {
+	logrus.Info("Running payment reconciliation every 10 minutes")
+	c := cron.New(cron.WithLocation(j.TimeLocation))
+	_, err := c.AddFunc("*/10 * * * *", j.reconcilePayments)
+	if err != nil {
+		logrus.Error("Failed to add payment reconciliation to cron: ", err)
+		return
+	}
+	c.Start()
+}
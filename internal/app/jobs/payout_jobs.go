@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+type PayoutJob struct {
+	PayoutService *services.PayoutService
+	TimeLocation  *time.Location
+}
+
+func NewPayoutJob(payoutService *services.PayoutService) *PayoutJob {
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	return &PayoutJob{
+		PayoutService: payoutService,
+		TimeLocation:  loc,
+	}
+}
+
+func (j *PayoutJob) syncPayouts() {
+	settled, err := j.PayoutService.SyncPayouts()
+	if err != nil {
+		logrus.Error("Failed to sync payouts: ", err)
+		return
+	}
+
+	if settled > 0 {
+		logrus.Infof("Settled %d payouts", settled)
+	}
+}
+
+func (j *PayoutJob) RunSyncPayouts() {
+	logrus.Info("Running payout sync every 5 minutes")
+	c := cron.New(cron.WithLocation(j.TimeLocation))
+	_, err := c.AddFunc("*/5 * * * *", j.syncPayouts)
+	if err != nil {
+		logrus.Error("Failed to add payout sync to cron: ", err)
+		return
+	}
+	c.Start()
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+type SubscriptionJob struct {
+	SubscriptionService *services.SubscriptionService
+	TimeLocation        *time.Location
+}
+
+func NewSubscriptionJob(subscriptionService *services.SubscriptionService) *SubscriptionJob {
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	return &SubscriptionJob{
+		SubscriptionService: subscriptionService,
+		TimeLocation:        loc,
+	}
+}
+
+func (j *SubscriptionJob) renewSubscriptions() {
+	reminded, err := j.SubscriptionService.RenewSubscriptions()
+	if err != nil {
+		logrus.Error("Failed to renew subscriptions: ", err)
+	} else if reminded > 0 {
+		logrus.Infof("Reminded %d subscribers of their renewal", reminded)
+	}
+
+	expired, err := j.SubscriptionService.ExpireSubscriptions()
+	if err != nil {
+		logrus.Error("Failed to expire subscriptions: ", err)
+	} else if expired > 0 {
+		logrus.Infof("Expired %d subscriptions", expired)
+	}
+}
+
+func (j *SubscriptionJob) RunSubscriptionRenewal() {
+	logrus.Info("Running subscription renewal every 15 minutes")
+	c := cron.New(cron.WithLocation(j.TimeLocation))
+	_, err := c.AddFunc("*/15 * * * *", j.renewSubscriptions)
+	if err != nil {
+		logrus.Error("Failed to add subscription renewal to cron: ", err)
+		return
+	}
+	c.Start()
+}
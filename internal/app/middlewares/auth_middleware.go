@@ -57,8 +57,8 @@ func (m *AuthMiddleware) VerifyAuthencitated(c *fiber.Ctx) error {
 func (m *AuthMiddleware) VerifyAdminAccess(c *fiber.Ctx) error {
 	authUser := c.Locals("user").(*models.User)
 	if !authUser.IsAdmin() {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized to access this resource",
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "You are not allowed to access this resource",
 		})
 	}
 
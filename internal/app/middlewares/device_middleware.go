@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeviceMiddleware struct {
+	ParkingService *services.ParkingService
+}
+
+func NewDeviceMiddleware(parkingService *services.ParkingService) *DeviceMiddleware {
+	return &DeviceMiddleware{
+		ParkingService: parkingService,
+	}
+}
+
+// VerifyDeviceSignature only lets through requests signed with the device secret of the slot they report on. The
+// slot is taken from the slug and slot_name route params, or from the parking_slug and slot fields of the body.
+// Devices send the unix time in X-Device-Timestamp and the signature from pkg.SignDeviceRequest in
+// X-Device-Signature.
+func (m *DeviceMiddleware) VerifyDeviceSignature(c *fiber.Ctx) error {
+	parkingSlug := c.Params("slug")
+	slotName := c.Params("slot_name")
+	if parkingSlug == "" {
+		var target struct {
+			ParkingSlug string `json:"parking_slug"`
+			Slot        string `json:"slot"`
+		}
+		_ = json.Unmarshal(c.Body(), &target)
+		parkingSlug = target.ParkingSlug
+		slotName = target.Slot
+	}
+
+	timestamp, err := strconv.ParseInt(c.Get("X-Device-Timestamp"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Missing device signature",
+		})
+	}
+
+	slot, err := m.ParkingService.GetParkingSlotByName(parkingSlug, slotName)
+	if err != nil || !pkg.VerifyDeviceRequest(slot.DeviceSecret, c.Get("X-Device-Signature"), timestamp, c.Method(), c.Path(), c.Body(), pkg.GetCurrentTime()) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid device signature",
+		})
+	}
+
+	return c.Next()
+}
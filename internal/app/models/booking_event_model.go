@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	BookingEventCreated          = "CREATED"
+	BookingEventUpdated          = "UPDATED"
+	BookingEventInvoiceIssued    = "INVOICE_ISSUED"
+	BookingEventStatusChanged    = "STATUS_CHANGED"
+	BookingEventExtended         = "EXTENDED"
+	BookingEventValidated        = "VALIDATED"
+	BookingEventOvertimeNotified = "OVERTIME_NOTIFIED"
+	BookingEventCheckedOut       = "CHECKED_OUT"
+)
+
+const (
+	BookingActorUser   = "USER"
+	BookingActorAdmin  = "ADMIN"
+	BookingActorSystem = "SYSTEM"
+)
+
+// Sources of booking events besides the payment event sources and the parking session sources
+const (
+	BookingEventSourceAPI = "API"
+	BookingEventSourceJob = "JOB"
+)
+
+// BookingActor is who caused a booking event and through what.
+type BookingActor struct {
+	Type   string `json:"type"`
+	UserID *int   `json:"user_id"`
+	Source string `json:"source"`
+}
+
+// NewBookingActor returns the actor of a change made by a user, an admin when the user has admin access.
+func NewBookingActor(user *User, source string) *BookingActor {
+	actor := &BookingActor{Type: BookingActorUser, UserID: &user.ID, Source: source}
+	if user.IsAdmin() {
+		actor.Type = BookingActorAdmin
+	}
+	return actor
+}
+
+// NewSystemBookingActor returns the actor of a change made without a user, such as a job or a payment callback.
+func NewSystemBookingActor(source string) *BookingActor {
+	return &BookingActor{Type: BookingActorSystem, Source: source}
+}
+
+// BookingEvent is an entry of the timeline of a booking. Status changes carry the statuses they moved between.
+type BookingEvent struct {
+	ID         int               `json:"id"`
+	BookingID  int               `json:"booking_id"`
+	Type       string            `json:"type"`
+	FromStatus string            `json:"from_status"`
+	ToStatus   string            `json:"to_status"`
+	ActorType  string            `json:"actor_type"`
+	ActorID    *int              `json:"actor_id"`
+	Source     string            `json:"source"`
+	Payload    datatypes.JSONMap `json:"payload" gorm:"type:jsonb"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
@@ -1,66 +1,285 @@
 package models
 
 import (
+	"fmt"
+	"slices"
 	"time"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
+const (
+	BookingStatusUnpaid    = "UNPAID"
+	BookingStatusPaid      = "PAID"
+	BookingStatusCanceled  = "CANCELED"
+	BookingStatusExpired   = "EXPIRED"
+	BookingStatusCompleted = "COMPLETED"
+	// BookingStatusOvertimeUnpaid is a checked out booking waiting for its overtime invoice to be paid
+	BookingStatusOvertimeUnpaid = "OVERTIME_UNPAID"
+	// BookingStatusNoShow is a paid booking whose vehicle was not seen in time, its slot is released
+	BookingStatusNoShow = "NO_SHOW"
+)
+
+// ActiveBookingStatuses are the statuses in which a booking holds its slot for the booked window.
+var ActiveBookingStatuses = []string{BookingStatusUnpaid, BookingStatusPaid}
+
+// BookingTransitions lists the statuses a booking may move to from each status.
+// Statuses without an entry are final.
+var BookingTransitions = map[string][]string{
+	BookingStatusUnpaid:         {BookingStatusPaid, BookingStatusCanceled, BookingStatusExpired},
+	BookingStatusPaid:           {BookingStatusCompleted, BookingStatusCanceled, BookingStatusOvertimeUnpaid, BookingStatusNoShow},
+	BookingStatusOvertimeUnpaid: {BookingStatusCompleted},
+}
+
+type BookingTransitionError struct {
+	Reference string
+	From      string
+	To        string
+}
+
+func (e *BookingTransitionError) Error() string {
+	return fmt.Sprintf("Booking %s cannot change status from %s to %s", e.Reference, e.From, e.To)
+}
+
+func CanTransitionBooking(from string, to string) bool {
+	return slices.Contains(BookingTransitions[from], to)
+}
+
+// BookingAccess is what a user wants to do with a booking.
+type BookingAccess int
+
+const (
+	// BookingAccessView is seeing the booking, allowed to its user and the author of its parking
+	BookingAccessView BookingAccess = iota
+	// BookingAccessOwn is acting on the booking as its user, such as changing the plate number
+	BookingAccessOwn
+	// BookingAccessOverride is changing any field of the booking
+	BookingAccessOverride
+)
+
+// CanAccessBooking reports whether the user may access the booking. Admins may do anything, the author of the
+// parking needs it preloaded on the booking.
+func CanAccessBooking(user *User, booking *Booking, access BookingAccess) bool {
+	if user.IsAdmin() {
+		return true
+	}
+
+	switch access {
+	case BookingAccessView:
+		return booking.UserID == user.ID || (booking.Parking != nil && booking.Parking.AuthorID == user.ID)
+	case BookingAccessOwn:
+		return booking.UserID == user.ID
+	default:
+		return false
+	}
+}
+
 type Booking struct {
-	ID                   int            `json:"id"`
-	UserID               int            `json:"user_id"`
-	User                 *User          `gorm:"foreignKey:UserID" json:"user"`
-	ParkingID            int            `json:"parking_id"`
-	Parking              *Parking       `gorm:"foreignKey:ParkingID" json:"parking"`
-	SlotID               int            `json:"slot_id"`
-	Slot                 *ParkingSlot   `gorm:"foreignKey:SlotID" json:"slot"`
-	PlateNumber          string         `json:"plate_number"`
-	StartAt              time.Time      `json:"start_at"`
-	EndAt                time.Time      `json:"end_at"`
-	TotalHours           int            `json:"total_hours"`
-	TotalFee             float64        `json:"total_fee"`
-	PaymentReference     string         `json:"payment_reference"`
-	PaymentLink          string         `json:"payment_link"`
-	PaymentExpiredAt     time.Time      `json:"payment_expired_at"`
-	Status               string         `json:"status"`
-	IsNotifyOvertimeSent bool           `json:"is_notify_overtime_sent"`
-	CreatedAt            time.Time      `json:"created_at"`
-	UpdatedAt            time.Time      `json:"updated_at"`
-	DeletedAt            gorm.DeletedAt `json:"deleted_at"`
+	ID                   int          `json:"id"`
+	UserID               int          `json:"user_id"`
+	User                 *User        `gorm:"foreignKey:UserID" json:"user"`
+	ParkingID            int          `json:"parking_id"`
+	Parking              *Parking     `gorm:"foreignKey:ParkingID" json:"parking"`
+	SlotID               int          `json:"slot_id"`
+	Slot                 *ParkingSlot `gorm:"foreignKey:SlotID" json:"slot"`
+	PlateNumber          string       `json:"plate_number"`
+	StartAt              time.Time    `json:"start_at"`
+	EndAt                time.Time    `json:"end_at"`
+	TotalHours           int          `json:"total_hours"`
+	TotalFee             float64      `json:"total_fee"`
+	PaymentReference     string       `json:"payment_reference"`
+	PaymentInvoiceID     string       `json:"payment_invoice_id"`
+	PaymentLink          string       `json:"payment_link"`
+	PaymentExpiredAt     time.Time    `json:"payment_expired_at"`
+	Status               string       `json:"status"`
+	IsNotifyOvertimeSent bool         `json:"is_notify_overtime_sent"`
+	OvertimeMinutes      int          `json:"overtime_minutes"`
+	OvertimeFee          float64      `json:"overtime_fee"`
+	OvertimeReference    string       `json:"overtime_reference"`
+	OvertimeInvoiceID    string       `json:"overtime_invoice_id"`
+	OvertimeLink         string       `json:"overtime_link"`
+	OvertimePaidAt       *time.Time   `json:"overtime_paid_at"`
+	// CommissionFee is what the platform kept of the parking fees paid so far under CommissionPlanID, ServiceFee
+	// is charged on top of TotalFee
+	CommissionPlanID *int    `json:"commission_plan_id"`
+	CommissionFee    float64 `json:"commission_fee"`
+	ServiceFee       float64 `json:"service_fee"`
+	// VoucherDiscount was taken off the parking fee, TotalFee is what remained
+	VoucherID       *int    `json:"voucher_id"`
+	VoucherDiscount float64 `json:"voucher_discount"`
+	// SeriesID is set on the occurrences of a recurring booking, which share the invoice of the series
+	SeriesID *int `json:"series_id"`
+	// ArrivedAt is when the plate was first seen at the slot, NoShowAt when the booking was given up without it
+	ArrivedAt  *time.Time         `json:"arrived_at"`
+	NoShowAt   *time.Time         `json:"no_show_at"`
+	Extensions []BookingExtension `gorm:"foreignKey:BookingID" json:"extensions,omitempty"`
+	Refunds    []Refund           `gorm:"foreignKey:BookingID" json:"refunds,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt     `json:"deleted_at"`
 }
 
+// CreateBookingRequest without SlotID books whichever free slot the parking assigns, SlotAttributes limits the
+// assignment to slots having all of them. HoldToken books the slot held during checkout.
 type CreateBookingRequest struct {
-	ParkingID   int       `json:"parking_id" validate:"required"`
-	SlotID      int       `json:"slot_id" validate:"required"`
-	PlateNumber string    `json:"plate_number" validate:"required,min=3,max=16"`
-	StartAt     time.Time `json:"start_at" validate:"required"`
-	EndAt       time.Time `json:"end_at" validate:"required"`
+	ParkingID      int       `json:"parking_id" validate:"required"`
+	SlotID         int       `json:"slot_id" validate:"omitempty,min=0"`
+	SlotAttributes []string  `json:"slot_attributes" validate:"omitempty,dive,min=1,max=32"`
+	PlateNumber    string    `json:"plate_number" validate:"required,min=3,max=16"`
+	StartAt        time.Time `json:"start_at" validate:"required"`
+	EndAt          time.Time `json:"end_at" validate:"required,gtfield=StartAt"`
+	VoucherCode    string    `json:"voucher_code" validate:"omitempty,max=32"`
+	HoldToken      string    `json:"hold_token" validate:"omitempty,max=64"`
+}
+
+type BookingQuote struct {
+	ParkingID       int               `json:"parking_id"`
+	SlotID          int               `json:"slot_id"`
+	SlotName        string            `json:"slot_name"`
+	PlateNumber     string            `json:"plate_number"`
+	StartAt         time.Time         `json:"start_at"`
+	EndAt           time.Time         `json:"end_at"`
+	TotalHours      int               `json:"total_hours"`
+	Items           []PriceLineItem   `json:"items"`
+	Adjustments     []PriceAdjustment `json:"adjustments"`
+	Subtotal        float64           `json:"subtotal"`
+	Discount        float64           `json:"discount"`
+	VoucherCode     string            `json:"voucher_code"`
+	VoucherDiscount float64           `json:"voucher_discount"`
+	TotalFee        float64           `json:"total_fee"`
+	ServiceFee      float64           `json:"service_fee"`
+	AmountDue       float64           `json:"amount_due"`
+	IsValid         bool              `json:"is_valid"`
+	Problems        []string          `json:"problems"`
 }
 
+// UpdateBookingRequest is what the user of a booking may change on it.
 type UpdateBookingRequest struct {
+	PlateNumber string `json:"plate_number" validate:"required,min=3,max=16"`
+}
+
+// AdminUpdateBookingRequest overrides the fields of a booking.
+type AdminUpdateBookingRequest struct {
 	PlateNumber string    `json:"plate_number" validate:"omitempty,min=3,max=16"`
 	StartAt     time.Time `json:"start_at" validate:"omitempty"`
 	EndAt       time.Time `json:"end_at" validate:"omitempty"`
 	TotalHours  int       `json:"total_hours" validate:"omitempty"`
 	TotalFee    float64   `json:"total_fee" validate:"omitempty"`
-	Status      string    `json:"status" validate:"omitempty,oneof=UNPAID PAID CANCELLED EXPIRED COMPLETED"`
+	Status      string    `json:"status" validate:"omitempty,oneof=UNPAID PAID CANCELED EXPIRED COMPLETED OVERTIME_UNPAID NO_SHOW"`
+}
+
+const (
+	BookingExtensionStatusUnpaid  = "UNPAID"
+	BookingExtensionStatusPaid    = "PAID"
+	BookingExtensionStatusExpired = "EXPIRED"
+	BookingExtensionStatusFailed  = "FAILED"
+)
+
+// BookingExtension records a request to move the end of a booking. EndAt only changes once the
+// extension invoice is paid.
+type BookingExtension struct {
+	ID               int       `json:"id"`
+	BookingID        int       `json:"booking_id"`
+	PreviousEndAt    time.Time `json:"previous_end_at"`
+	NewEndAt         time.Time `json:"new_end_at"`
+	AdditionalHours  int       `json:"additional_hours"`
+	Fee              float64   `json:"fee"`
+	PaymentReference string    `json:"payment_reference"`
+	PaymentInvoiceID string    `json:"payment_invoice_id"`
+	PaymentLink      string    `json:"payment_link"`
+	PaymentExpiredAt time.Time `json:"payment_expired_at"`
+	Status           string    `json:"status"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+const (
+	BookingSeriesFrequencyDaily  = "DAILY"
+	BookingSeriesFrequencyWeekly = "WEEKLY"
+)
+
+const (
+	BookingSeriesStatusUnpaid   = "UNPAID"
+	BookingSeriesStatusPaid     = "PAID"
+	BookingSeriesStatusExpired  = "EXPIRED"
+	BookingSeriesStatusCanceled = "CANCELED"
+)
+
+// BookingSeries is a recurring booking. Every occurrence is a Booking of its own, all of them are billed on the
+// invoice of the series. Without SlotID every occurrence gets the free slot the parking assigns.
+type BookingSeries struct {
+	ID               int                      `json:"id"`
+	UserID           int                      `json:"user_id"`
+	User             *User                    `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ParkingID        int                      `json:"parking_id"`
+	Parking          *Parking                 `gorm:"foreignKey:ParkingID" json:"parking,omitempty"`
+	SlotID           *int                     `json:"slot_id"`
+	PlateNumber      string                   `json:"plate_number"`
+	Frequency        string                   `json:"frequency"`
+	Interval         int                      `json:"interval"`
+	DaysOfWeek       datatypes.JSONSlice[int] `json:"days_of_week" gorm:"type:jsonb"`
+	StartTime        string                   `json:"start_time"`
+	EndTime          string                   `json:"end_time"`
+	StartDate        string                   `json:"start_date"`
+	EndDate          string                   `json:"end_date"`
+	TotalFee         float64                  `json:"total_fee"`
+	ServiceFee       float64                  `json:"service_fee"`
+	PaymentReference string                   `json:"payment_reference"`
+	PaymentInvoiceID string                   `json:"payment_invoice_id"`
+	PaymentLink      string                   `json:"payment_link"`
+	PaymentExpiredAt time.Time                `json:"payment_expired_at"`
+	Status           string                   `json:"status"`
+	Bookings         []Booking                `gorm:"foreignKey:SeriesID" json:"bookings,omitempty"`
+	CreatedAt        time.Time                `json:"created_at"`
+	UpdatedAt        time.Time                `json:"updated_at"`
+}
+
+// CreateBookingSeriesRequest repeats the window StartTime-EndTime every Interval days (DAILY) or on DaysOfWeek of
+// every Interval weeks (WEEKLY) from StartDate up to and including EndDate. A window ending before it starts
+// ends on the next day.
+type CreateBookingSeriesRequest struct {
+	ParkingID   int    `json:"parking_id" validate:"required"`
+	SlotID      *int   `json:"slot_id" validate:"omitempty"`
+	PlateNumber string `json:"plate_number" validate:"required,min=3,max=16"`
+	Frequency   string `json:"frequency" validate:"required,oneof=DAILY WEEKLY"`
+	Interval    int    `json:"interval" validate:"min=0,max=52"`
+	DaysOfWeek  []int  `json:"days_of_week" validate:"omitempty,dive,min=0,max=6"`
+	StartTime   string `json:"start_time" validate:"required,datetime=15:04"`
+	EndTime     string `json:"end_time" validate:"required,datetime=15:04,nefield=StartTime"`
+	StartDate   string `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate     string `json:"end_date" validate:"required,datetime=2006-01-02"`
+}
+
+type ExtendBookingRequest struct {
+	EndAt time.Time `json:"end_at" validate:"required"`
+}
+
+type CancelBookingRequest struct {
+	Reason string `json:"reason" validate:"omitempty,max=255"`
 }
 
 type ValidateBookingRequest struct {
 	ParkingSlug string `json:"parking_slug" validate:"required"`
 	Slot        string `json:"slot" validate:"required"`
-	PlateNumber string `json:"plate_number" validate:"required,min=2,max=16"`
+	// PlateNumber is empty when the camera sees the slot emptied
+	PlateNumber string `json:"plate_number" validate:"omitempty,min=2,max=16"`
 }
 
 type ValidateBookingResponse struct {
-	BookingID          int        `json:"booking_id"`
-	Booking            *Booking   `json:"booking"`
-	RequestTime        *time.Time `json:"request_time"`
-	RequestPlateNumber string     `json:"request_plate_number"`
-	BookingPlateNumber string     `json:"booking_plate_number"`
-	Similarity         float64    `json:"similarity"`
-	IsValid            bool       `json:"is_valid"`
-	Reason             string     `json:"reason"`
+	BookingID          int             `json:"booking_id"`
+	Booking            *Booking        `json:"booking"`
+	SubscriptionID     int             `json:"subscription_id"`
+	Subscription       *Subscription   `json:"subscription"`
+	SessionID          int             `json:"session_id"`
+	Session            *ParkingSession `json:"session"`
+	RequestTime        *time.Time      `json:"request_time"`
+	RequestPlateNumber string          `json:"request_plate_number"`
+	BookingPlateNumber string          `json:"booking_plate_number"`
+	Similarity         float64         `json:"similarity"`
+	IsValid            bool            `json:"is_valid"`
+	Reason             string          `json:"reason"`
 }
 
 type BookingFilter struct {
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CommissionPlan is the share of the booking revenue the platform keeps. Percentage applies to every payment of a
+// booking, FlatFee once per booking. ServiceFee is charged to the customer on top of the parking fee as a separate
+// invoice line and is not refunded. Parkings without a plan of their own use the default plan.
+type CommissionPlan struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Percentage float64        `json:"percentage"`
+	FlatFee    float64        `json:"flat_fee"`
+	ServiceFee float64        `json:"service_fee"`
+	IsDefault  bool           `json:"is_default"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at"`
+}
+
+type CreateCommissionPlanRequest struct {
+	Name       string  `json:"name" validate:"required,min=3,max=255"`
+	Percentage float64 `json:"percentage" validate:"min=0,max=100"`
+	FlatFee    float64 `json:"flat_fee" validate:"min=0"`
+	ServiceFee float64 `json:"service_fee" validate:"min=0"`
+	IsDefault  bool    `json:"is_default"`
+}
+
+type UpdateCommissionPlanRequest struct {
+	Name       string   `json:"name" validate:"omitempty,min=3,max=255"`
+	Percentage *float64 `json:"percentage" validate:"omitempty,min=0,max=100"`
+	FlatFee    *float64 `json:"flat_fee" validate:"omitempty,min=0"`
+	ServiceFee *float64 `json:"service_fee" validate:"omitempty,min=0"`
+	IsDefault  *bool    `json:"is_default"`
+}
+
+type RevenueReportFilter struct {
+	ParkingID int       `json:"parking_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// RevenueReport splits the money received within a period between the parkings and the platform.
+type RevenueReport struct {
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	GrossRevenue   float64          `json:"gross_revenue"`
+	OwnerEarnings  float64          `json:"owner_earnings"`
+	Commission     float64          `json:"commission"`
+	ServiceFees    float64          `json:"service_fees"`
+	Refunded       float64          `json:"refunded"`
+	PlatformIncome float64          `json:"platform_income"`
+	Parkings       []ParkingRevenue `json:"parkings"`
+}
+
+type ParkingRevenue struct {
+	ParkingID      int     `json:"parking_id"`
+	ParkingName    string  `json:"parking_name"`
+	GrossRevenue   float64 `json:"gross_revenue"`
+	OwnerEarnings  float64 `json:"owner_earnings"`
+	Commission     float64 `json:"commission"`
+	ServiceFees    float64 `json:"service_fees"`
+	Refunded       float64 `json:"refunded"`
+	PlatformIncome float64 `json:"platform_income"`
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// LedgerAccountParkingAvailable is what the parking owner can still withdraw
+	LedgerAccountParkingAvailable = "PARKING_AVAILABLE"
+	// LedgerAccountParkingWithdrawn is what has been paid out to the parking owner
+	LedgerAccountParkingWithdrawn = "PARKING_WITHDRAWN"
+	// LedgerAccountGatewayClearing is the counterpart of money moving through the payment gateway
+	LedgerAccountGatewayClearing = "GATEWAY_CLEARING"
+	// LedgerAccountPlatformRevenue is the share of a payment kept by the platform
+	LedgerAccountPlatformRevenue = "PLATFORM_REVENUE"
+	// LedgerAccountSuspense holds amounts carried over from the old earnings counters that await review
+	LedgerAccountSuspense = "SUSPENSE"
+)
+
+const (
+	LedgerEntryOpeningBalance      = "OPENING_BALANCE"
+	LedgerEntryOpeningAdjustment   = "OPENING_ADJUSTMENT"
+	LedgerEntryBookingPayment      = "BOOKING_PAYMENT"
+	LedgerEntryExtensionPayment    = "EXTENSION_PAYMENT"
+	LedgerEntryOvertimePayment     = "OVERTIME_PAYMENT"
+	LedgerEntrySubscriptionPayment = "SUBSCRIPTION_PAYMENT"
+	LedgerEntrySessionPayment      = "SESSION_PAYMENT"
+	LedgerEntryRefund              = "REFUND"
+	LedgerEntryRefundReversal      = "REFUND_REVERSAL"
+	LedgerEntryCommission          = "COMMISSION"
+	LedgerEntryServiceFee          = "SERVICE_FEE"
+	LedgerEntryPayout              = "PAYOUT"
+	LedgerEntryPayoutReversal      = "PAYOUT_REVERSAL"
+)
+
+// LedgerEntry is one line of an append-only double-entry ledger. The amounts of all entries sharing a
+// TransactionID add up to zero, and the parking balances are sums of the entries per account.
+type LedgerEntry struct {
+	ID            int       `json:"id"`
+	TransactionID string    `json:"transaction_id"`
+	ParkingID     int       `json:"parking_id"`
+	BookingID     *int      `json:"booking_id"`
+	Account       string    `json:"account"`
+	Type          string    `json:"type"`
+	Amount        float64   `json:"amount"`
+	Reference     string    `json:"reference"`
+	Description   string    `json:"description"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type LedgerEntryFilter struct {
+	ParkingID int    `json:"parking_id"`
+	BookingID int    `json:"booking_id"`
+	Account   string `json:"account"`
+	Type      string `json:"type"`
+	Limit     int    `json:"limit"`
+	Page      int    `json:"page"`
+}
+
+type ParkingBalance struct {
+	TotalEarnings     float64 `json:"total_earnings"`
+	AvailableEarnings float64 `json:"available_earnings"`
+	WithdrawnEarnings float64 `json:"withdrawn_earnings"`
+	PlatformRevenue   float64 `json:"platform_revenue"`
+}
+
+func LoadParkingBalance(db *gorm.DB, parkingID int) (ParkingBalance, error) {
+	balances, err := LoadParkingBalances(db, []int{parkingID})
+	if err != nil {
+		return ParkingBalance{}, err
+	}
+
+	return balances[parkingID], nil
+}
+
+// LoadParkingBalances sums the ledger entries of several parkings at once, parkings without entries are missing
+// from the result.
+func LoadParkingBalances(db *gorm.DB, parkingIDs []int) (map[int]ParkingBalance, error) {
+	var rows []struct {
+		ParkingID int
+		Account   string
+		Balance   float64
+	}
+	err := db.Model(&LedgerEntry{}).
+		Select("parking_id, account, COALESCE(SUM(amount), 0) AS balance").
+		Where("parking_id IN ?", parkingIDs).
+		Group("parking_id, account").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[int]ParkingBalance, len(parkingIDs))
+	for _, row := range rows {
+		balance := balances[row.ParkingID]
+		switch row.Account {
+		case LedgerAccountParkingAvailable:
+			balance.AvailableEarnings = row.Balance
+		case LedgerAccountParkingWithdrawn:
+			balance.WithdrawnEarnings = row.Balance
+		case LedgerAccountPlatformRevenue:
+			balance.PlatformRevenue = row.Balance
+		}
+		balance.TotalEarnings = balance.AvailableEarnings + balance.WithdrawnEarnings
+		balances[row.ParkingID] = balance
+	}
+
+	return balances, nil
+}
+
+// LedgerConsistencyReport compares what the bookings of a parking should have posted with what the ledger holds.
+type LedgerConsistencyReport struct {
+	ParkingID              int                      `json:"parking_id"`
+	Balance                ParkingBalance           `json:"balance"`
+	CheckedBookings        int                      `json:"checked_bookings"`
+	UnbalancedTransactions []string                 `json:"unbalanced_transactions"`
+	Issues                 []LedgerConsistencyIssue `json:"issues"`
+	IsConsistent           bool                     `json:"is_consistent"`
+}
+
+type LedgerConsistencyIssue struct {
+	BookingID int     `json:"booking_id"`
+	Reference string  `json:"reference"`
+	Type      string  `json:"type"`
+	Expected  float64 `json:"expected"`
+	Recorded  float64 `json:"recorded"`
+}
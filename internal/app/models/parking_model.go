@@ -7,6 +7,16 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	// SlotAssignmentClosest assigns the free slot closest to an entrance of the layout
+	SlotAssignmentClosest = "CLOSEST"
+	// SlotAssignmentBalanced assigns the free slot booked least recently so wear is spread over the slots
+	SlotAssignmentBalanced = "BALANCED"
+	// SlotAssignmentBestFit assigns the free slot with the fewest attributes the booking did not ask for, keeping
+	// e.g. EV chargers free for the vehicles that need them
+	SlotAssignmentBestFit = "BEST_FIT"
+)
+
 type Parking struct {
 	ID                int            `json:"id"`
 	AuthorID          int            `json:"author_id"`
@@ -20,67 +30,136 @@ type Parking struct {
 	Distance          float64        `json:"distance" gorm:"-"`
 	Layout            datatypes.JSON `json:"layout" gorm:"type:jsonb"`
 	Slots             []ParkingSlot  `json:"slots" gorm:"foreignKey:parking_id;references:ID"`
-	TotalEarnings     float64        `json:"total_earnings"`
+	TotalEarnings     float64        `json:"total_earnings" gorm:"-"`
 	TotalBookings     int            `json:"total_bookings"`
-	AvailableEarnings float64        `json:"available_earnings"`
-	WithdrawnEarnings float64        `json:"withdrawn_earnings"`
-	CreatedAt         time.Time      `json:"created_at"`
-	UpdatedAt         time.Time      `json:"updated_at"`
-	DeletedAt         *time.Time     `json:"deleted_at"`
+	AvailableEarnings float64        `json:"available_earnings" gorm:"-"`
+	WithdrawnEarnings float64        `json:"withdrawn_earnings" gorm:"-"`
+	// OvertimeRate is charged per started hour past the booking end, the slot fee is used when it is 0
+	OvertimeRate         float64 `json:"overtime_rate"`
+	OvertimeGraceMinutes int     `json:"overtime_grace_minutes"`
+	// Canceling at least CancelFullRefundHours before the start refunds everything, later cancellations before the
+	// start refund CancelPartialRefundPercent and nothing is refunded once the booking started
+	CancelFullRefundHours      int `json:"cancel_full_refund_hours"`
+	CancelPartialRefundPercent int `json:"cancel_partial_refund_percent"`
+	// CommissionPlanID overrides the default commission plan for this parking
+	CommissionPlanID *int            `json:"commission_plan_id"`
+	CommissionPlan   *CommissionPlan `gorm:"foreignKey:CommissionPlanID" json:"commission_plan,omitempty"`
+	// A paid booking whose plate is not seen within NoShowMinutes of its start is a no-show, NoShowRefundPercent of
+	// what was paid is refunded then. A window of 0, the default, never marks bookings as no-show.
+	NoShowMinutes       int `json:"no_show_minutes"`
+	NoShowRefundPercent int `json:"no_show_refund_percent"`
+	// SlotAssignment picks the slot of bookings made without one, entrances are the "E" cells of the layout
+	SlotAssignment string `json:"slot_assignment"`
+	// PlatformFees is what the platform kept of the payments for this parking
+	PlatformFees float64    `json:"platform_fees" gorm:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+}
+
+// SetBalance fills in the earnings of the parking derived from its ledger entries.
+func (p *Parking) SetBalance(balance ParkingBalance) {
+	p.TotalEarnings = balance.TotalEarnings
+	p.AvailableEarnings = balance.AvailableEarnings
+	p.WithdrawnEarnings = balance.WithdrawnEarnings
+	p.PlatformFees = balance.PlatformRevenue
 }
 
 type ParkingSlot struct {
-	ID        int            `json:"id"`
-	ParkingID int            `json:"parking_id"`
-	Parking   *Parking       `gorm:"foreignKey:parking_id;references:ID" json:"parking,omitempty"`
-	Name      string         `json:"name"`
-	Status    string         `json:"status"`
-	Fee       float64        `json:"fee"`
-	Row       int            `json:"row"`
-	Col       int            `json:"col"`
-	ESPHmac   string         `json:"esp_hmac"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at"`
+	ID        int      `json:"id"`
+	ParkingID int      `json:"parking_id"`
+	Parking   *Parking `gorm:"foreignKey:parking_id;references:ID" json:"parking,omitempty"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	Fee       float64  `json:"fee"`
+	Row       int      `json:"row"`
+	Col       int      `json:"col"`
+	ESPHmac   string   `json:"esp_hmac"`
+	// DeviceSecret signs the requests of the sensor and camera of the slot, it is never returned
+	DeviceSecret string `json:"-"`
+	// Attributes describe the slot, e.g. EV_CHARGER or WIDE, bookings can require them
+	Attributes datatypes.JSONSlice[string] `json:"attributes" gorm:"type:jsonb"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	UpdatedAt  time.Time                   `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt              `json:"deleted_at"`
 }
 
 type CreateParkingRequest struct {
-	Slug       string         `json:"slug" validate:"required,min=3,max=255"`
-	Name       string         `json:"name" validate:"required,min=3,max=255"`
-	Address    string         `json:"address" validate:"required,min=3,max=255"`
-	DefaultFee float64        `json:"default_fee" validate:"required,min=0"`
-	Latitude   float64        `json:"latitude" validate:"required"`
-	Longitude  float64        `json:"longitude" validate:"required"`
-	Layout     datatypes.JSON `json:"layout" validate:"required"`
+	Slug                       string         `json:"slug" validate:"required,min=3,max=255"`
+	Name                       string         `json:"name" validate:"required,min=3,max=255"`
+	Address                    string         `json:"address" validate:"required,min=3,max=255"`
+	DefaultFee                 float64        `json:"default_fee" validate:"required,min=0"`
+	Latitude                   float64        `json:"latitude" validate:"required"`
+	Longitude                  float64        `json:"longitude" validate:"required"`
+	Layout                     datatypes.JSON `json:"layout" validate:"required"`
+	OvertimeRate               float64        `json:"overtime_rate" validate:"omitempty,min=0"`
+	OvertimeGraceMinutes       *int           `json:"overtime_grace_minutes" validate:"omitempty,min=0"`
+	CancelFullRefundHours      *int           `json:"cancel_full_refund_hours" validate:"omitempty,min=0"`
+	CancelPartialRefundPercent *int           `json:"cancel_partial_refund_percent" validate:"omitempty,min=0,max=100"`
+	CommissionPlanID           *int           `json:"commission_plan_id" validate:"omitempty,min=0"`
+	NoShowMinutes              *int           `json:"no_show_minutes" validate:"omitempty,min=0"`
+	NoShowRefundPercent        *int           `json:"no_show_refund_percent" validate:"omitempty,min=0,max=100"`
+	SlotAssignment             string         `json:"slot_assignment" validate:"omitempty,oneof=CLOSEST BALANCED BEST_FIT"`
 }
 
 type UpdateParkingRequest struct {
-	Slug       string         `json:"slug" validate:"omitempty,min=3,max=255"`
-	Name       string         `json:"name" validate:"omitempty,min=3,max=255"`
-	Address    string         `json:"address" validate:"omitempty,min=3,max=255"`
-	DefaultFee float64        `json:"default_fee" validate:"omitempty,min=0"`
-	Latitude   float64        `json:"latitude" validate:"omitempty"`
-	Longitude  float64        `json:"longitude" validate:"omitempty"`
-	Layout     datatypes.JSON `json:"layout" validate:"omitempty"`
+	Slug                       string         `json:"slug" validate:"omitempty,min=3,max=255"`
+	Name                       string         `json:"name" validate:"omitempty,min=3,max=255"`
+	Address                    string         `json:"address" validate:"omitempty,min=3,max=255"`
+	DefaultFee                 float64        `json:"default_fee" validate:"omitempty,min=0"`
+	Latitude                   float64        `json:"latitude" validate:"omitempty"`
+	Longitude                  float64        `json:"longitude" validate:"omitempty"`
+	Layout                     datatypes.JSON `json:"layout" validate:"omitempty"`
+	OvertimeRate               *float64       `json:"overtime_rate" validate:"omitempty,min=0"`
+	OvertimeGraceMinutes       *int           `json:"overtime_grace_minutes" validate:"omitempty,min=0"`
+	CancelFullRefundHours      *int           `json:"cancel_full_refund_hours" validate:"omitempty,min=0"`
+	CancelPartialRefundPercent *int           `json:"cancel_partial_refund_percent" validate:"omitempty,min=0,max=100"`
+	CommissionPlanID           *int           `json:"commission_plan_id" validate:"omitempty,min=0"`
+	NoShowMinutes              *int           `json:"no_show_minutes" validate:"omitempty,min=0"`
+	NoShowRefundPercent        *int           `json:"no_show_refund_percent" validate:"omitempty,min=0,max=100"`
+	SlotAssignment             string         `json:"slot_assignment" validate:"omitempty,oneof=CLOSEST BALANCED BEST_FIT"`
 }
 
 type CreateParkingSlotRequest struct {
-	ParkingID int     `json:"parking_id" validate:"required"`
-	Name      string  `json:"name" validate:"required,min=1,max=8"`
-	Status    string  `json:"status" validate:"required,oneof=AVAILABLE BOOKED OCCUPIED"`
-	Fee       float64 `json:"fee" validate:"required,min=0"`
-	Row       int     `json:"row" validate:"required"`
-	Col       int     `json:"col" validate:"required"`
-	ESPHmac   string  `json:"esp_hmac" validate:"omitempty"`
+	ParkingID    int      `json:"parking_id" validate:"required"`
+	Name         string   `json:"name" validate:"required,min=1,max=8"`
+	Status       string   `json:"status" validate:"required,oneof=AVAILABLE BOOKED OCCUPIED"`
+	Fee          float64  `json:"fee" validate:"required,min=0"`
+	Row          int      `json:"row" validate:"required"`
+	Col          int      `json:"col" validate:"required"`
+	ESPHmac      string   `json:"esp_hmac" validate:"omitempty"`
+	DeviceSecret string   `json:"device_secret" validate:"omitempty,min=16,max=255"`
+	Attributes   []string `json:"attributes" validate:"omitempty,dive,min=1,max=32"`
 }
 
 type UpdateParkingSlotRequest struct {
-	Name    string  `json:"name" validate:"omitempty,min=1,max=8"`
-	Status  string  `json:"status" validate:"omitempty,oneof=AVAILABLE BOOKED OCCUPIED"`
-	Fee     float64 `json:"fee" validate:"omitempty,min=0"`
-	Row     int     `json:"row" validate:"omitempty"`
-	Col     int     `json:"col" validate:"omitempty"`
-	ESPHmac string  `json:"esp_hmac" validate:"omitempty"`
+	Name         string   `json:"name" validate:"omitempty,min=1,max=8"`
+	Status       string   `json:"status" validate:"omitempty,oneof=AVAILABLE BOOKED OCCUPIED"`
+	Fee          float64  `json:"fee" validate:"omitempty,min=0"`
+	Row          int      `json:"row" validate:"omitempty"`
+	Col          int      `json:"col" validate:"omitempty"`
+	ESPHmac      string   `json:"esp_hmac" validate:"omitempty"`
+	DeviceSecret string   `json:"device_secret" validate:"omitempty,min=16,max=255"`
+	Attributes   []string `json:"attributes" validate:"omitempty,dive,min=1,max=32"`
+}
+
+type ParkingAvailability struct {
+	ParkingID int                `json:"parking_id"`
+	StartAt   time.Time          `json:"start_at"`
+	EndAt     time.Time          `json:"end_at"`
+	Slots     []SlotAvailability `json:"slots"`
+}
+
+type SlotAvailability struct {
+	Slot        ParkingSlot    `json:"slot"`
+	IsAvailable bool           `json:"is_available"`
+	Conflicts   []TimeInterval `json:"conflicts"`
+}
+
+type TimeInterval struct {
+	StartAt time.Time `json:"start_at"`
+	EndAt   time.Time `json:"end_at"`
+	Source  string    `json:"source"`
 }
 
 type ParkingImage struct {
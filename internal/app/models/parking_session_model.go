@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+const (
+	// ParkingSessionStatusOpen is a vehicle still parked
+	ParkingSessionStatusOpen = "OPEN"
+	// ParkingSessionStatusClosed is a vehicle of a booking or subscription, or one whose plate was never read, that
+	// left, it has nothing to pay
+	ParkingSessionStatusClosed = "CLOSED"
+	ParkingSessionStatusUnpaid = "UNPAID"
+	ParkingSessionStatusPaid   = "PAID"
+)
+
+// Sources a session entry or exit can be recorded by
+const (
+	ParkingSessionSourceSensor = "SENSOR"
+	ParkingSessionSourceCamera = "CAMERA"
+	ParkingSessionSourceManual = "MANUAL"
+	ParkingSessionSourceGate   = "GATE"
+)
+
+// ParkingSession is the time a vehicle actually occupied a slot, from its entry to its exit. Sessions of a booking
+// or subscription only record presence, a walk-in parked without either is billed with the tariff of the slot once
+// the slot frees or the plate checks out.
+type ParkingSession struct {
+	ID               int           `json:"id"`
+	ParkingID        int           `json:"parking_id"`
+	Parking          *Parking      `gorm:"foreignKey:ParkingID" json:"parking,omitempty"`
+	SlotID           int           `json:"slot_id"`
+	Slot             *ParkingSlot  `gorm:"foreignKey:SlotID" json:"slot,omitempty"`
+	BookingID        *int          `json:"booking_id"`
+	Booking          *Booking      `gorm:"foreignKey:BookingID" json:"booking,omitempty"`
+	SubscriptionID   *int          `json:"subscription_id"`
+	Subscription     *Subscription `gorm:"foreignKey:SubscriptionID" json:"subscription,omitempty"`
+	PlateNumber      string        `json:"plate_number"`
+	Source           string        `json:"source"`
+	ExitSource       string        `json:"exit_source"`
+	EnteredAt        time.Time     `json:"entered_at"`
+	ExitedAt         *time.Time    `json:"exited_at"`
+	TotalHours       int           `json:"total_hours"`
+	Fee              float64       `json:"fee"`
+	CommissionFee    float64       `json:"commission_fee"`
+	PaymentReference string        `json:"payment_reference"`
+	PaymentInvoiceID string        `json:"payment_invoice_id"`
+	PaymentLink      string        `json:"payment_link"`
+	PaymentExpiredAt *time.Time    `json:"payment_expired_at"`
+	Status           string        `json:"status"`
+	PaidAt           *time.Time    `json:"paid_at"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+}
+
+// IsGuest reports whether the session is a walk-in paying for the time parked.
+func (s *ParkingSession) IsGuest() bool {
+	return s.BookingID == nil && s.SubscriptionID == nil
+}
+
+type ParkingSessionFilter struct {
+	ParkingID   int    `json:"parking_id"`
+	BookingID   int    `json:"booking_id"`
+	PlateNumber string `json:"plate_number"`
+	Status      string `json:"status"`
+	Source      string `json:"source"`
+	Limit       int    `json:"limit"`
+	Page        int    `json:"page"`
+}
+
+type OccupancyReportFilter struct {
+	ParkingID int       `json:"parking_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// OccupancyReport compares the time slots were reserved by bookings with the time vehicles actually occupied them.
+type OccupancyReport struct {
+	ParkingID       int             `json:"parking_id"`
+	From            time.Time       `json:"from"`
+	To              time.Time       `json:"to"`
+	BookedMinutes   int             `json:"booked_minutes"`
+	OccupiedMinutes int             `json:"occupied_minutes"`
+	GuestMinutes    int             `json:"guest_minutes"`
+	Sessions        int             `json:"sessions"`
+	Slots           []SlotOccupancy `json:"slots"`
+}
+
+type SlotOccupancy struct {
+	SlotID          int    `json:"slot_id"`
+	SlotName        string `json:"slot_name"`
+	BookedMinutes   int    `json:"booked_minutes"`
+	OccupiedMinutes int    `json:"occupied_minutes"`
+	GuestMinutes    int    `json:"guest_minutes"`
+	Sessions        int    `json:"sessions"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	PaymentEventStatusReceived   = "RECEIVED"
+	PaymentEventStatusProcessing = "PROCESSING"
+	PaymentEventStatusProcessed  = "PROCESSED"
+	PaymentEventStatusFailed     = "FAILED"
+
+	PaymentEventSourceWebhook        = "WEBHOOK"
+	PaymentEventSourceReconciliation = "RECONCILIATION"
+)
+
+// PaymentEvent is a verified payment gateway callback. EventID identifies the change the gateway reported
+// (invoice and status), so repeated deliveries of the same callback map to the same event.
+type PaymentEvent struct {
+	ID               int            `json:"id"`
+	EventID          string         `json:"event_id"`
+	Provider         string         `json:"provider"`
+	Source           string         `json:"source"`
+	InvoiceID        string         `json:"invoice_id"`
+	ExternalID       string         `json:"external_id"`
+	Status           string         `json:"status"`
+	Amount           float64        `json:"amount"`
+	PaidAmount       float64        `json:"paid_amount"`
+	Payload          datatypes.JSON `json:"payload" gorm:"type:jsonb"`
+	ProcessingStatus string         `json:"processing_status"`
+	BookingID        *int           `json:"booking_id"`
+	Error            string         `json:"error"`
+	Attempts         int            `json:"attempts"`
+	DeliveryCount    int            `json:"delivery_count"`
+	LastDeliveredAt  time.Time      `json:"last_delivered_at"`
+	ProcessedAt      *time.Time     `json:"processed_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+type PaymentEventFilter struct {
+	ProcessingStatus string `json:"processing_status"`
+	InvoiceID        string `json:"invoice_id"`
+	ExternalID       string `json:"external_id"`
+	Limit            int    `json:"limit"`
+	Page             int    `json:"page"`
+}
+
+const (
+	ReconciliationResultFixed          = "FIXED"
+	ReconciliationResultAmountMismatch = "AMOUNT_MISMATCH"
+	ReconciliationResultUnknownInvoice = "UNKNOWN_INVOICE"
+	ReconciliationResultError          = "ERROR"
+)
+
+// ReconciliationReport summarizes a run comparing non-final local payments with their invoices at the gateway.
+// Items only lists the payments that needed attention.
+type ReconciliationReport struct {
+	ID               int                                     `json:"id"`
+	Provider         string                                  `json:"provider"`
+	StartedAt        time.Time                               `json:"started_at"`
+	FinishedAt       time.Time                               `json:"finished_at"`
+	Checked          int                                     `json:"checked"`
+	Fixed            int                                     `json:"fixed"`
+	AmountMismatches int                                     `json:"amount_mismatches"`
+	UnknownInvoices  int                                     `json:"unknown_invoices"`
+	Errors           int                                     `json:"errors"`
+	Items            datatypes.JSONSlice[ReconciliationItem] `json:"items" gorm:"type:jsonb"`
+	CreatedAt        time.Time                               `json:"created_at"`
+}
+
+type ReconciliationItem struct {
+	Kind           string  `json:"kind"`
+	Reference      string  `json:"reference"`
+	InvoiceID      string  `json:"invoice_id"`
+	Result         string  `json:"result"`
+	LocalStatus    string  `json:"local_status"`
+	GatewayStatus  string  `json:"gateway_status"`
+	ExpectedAmount float64 `json:"expected_amount"`
+	GatewayAmount  float64 `json:"gateway_amount"`
+	Message        string  `json:"message"`
+}
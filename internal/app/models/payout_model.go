@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PayoutStatusRequested  = "REQUESTED"
+	PayoutStatusApproved   = "APPROVED"
+	PayoutStatusRejected   = "REJECTED"
+	PayoutStatusProcessing = "PROCESSING"
+	PayoutStatusCompleted  = "COMPLETED"
+	PayoutStatusFailed     = "FAILED"
+)
+
+// PayoutTransitions lists the statuses a payout may move to from each status.
+// Statuses without an entry are final.
+var PayoutTransitions = map[string][]string{
+	PayoutStatusRequested:  {PayoutStatusApproved, PayoutStatusRejected},
+	PayoutStatusApproved:   {PayoutStatusProcessing, PayoutStatusCompleted, PayoutStatusFailed},
+	PayoutStatusProcessing: {PayoutStatusCompleted, PayoutStatusFailed},
+}
+
+type PayoutTransitionError struct {
+	Reference string
+	From      string
+	To        string
+}
+
+func (e *PayoutTransitionError) Error() string {
+	return fmt.Sprintf("Payout %s cannot change status from %s to %s", e.Reference, e.From, e.To)
+}
+
+func CanTransitionPayout(from string, to string) bool {
+	return slices.Contains(PayoutTransitions[from], to)
+}
+
+// BankAccount is where the payouts of a parking owner are transferred to. BankCode is the channel code of the
+// disbursement provider, e.g. ID_BCA.
+type BankAccount struct {
+	ID                int            `json:"id"`
+	UserID            int            `json:"user_id"`
+	BankCode          string         `json:"bank_code"`
+	AccountNumber     string         `json:"account_number"`
+	AccountHolderName string         `json:"account_holder_name"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"deleted_at"`
+}
+
+// Payout is a withdrawal of the available earnings of a parking. The amount leaves the available balance once
+// an admin approves it, and is returned to it when the transfer fails.
+type Payout struct {
+	ID               int                   `json:"id"`
+	ParkingID        int                   `json:"parking_id"`
+	Parking          *Parking              `gorm:"foreignKey:ParkingID" json:"parking,omitempty"`
+	UserID           int                   `json:"user_id"`
+	BankAccountID    int                   `json:"bank_account_id"`
+	BankAccount      *BankAccount          `gorm:"foreignKey:BankAccountID" json:"bank_account,omitempty"`
+	Reference        string                `json:"reference"`
+	Amount           float64               `json:"amount"`
+	Status           string                `json:"status"`
+	Provider         string                `json:"provider"`
+	ProviderPayoutID string                `json:"provider_payout_id"`
+	Note             string                `json:"note"`
+	ReviewedBy       *int                  `json:"reviewed_by"`
+	ReviewedAt       *time.Time            `json:"reviewed_at"`
+	CompletedAt      *time.Time            `json:"completed_at"`
+	Histories        []PayoutStatusHistory `gorm:"foreignKey:PayoutID" json:"histories,omitempty"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
+}
+
+// PayoutStatusHistory records a single status change of a payout. ActorID is empty for changes made by the
+// disbursement provider.
+type PayoutStatusHistory struct {
+	ID         int       `json:"id"`
+	PayoutID   int       `json:"payout_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    *int      `json:"actor_id"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PayoutFilter struct {
+	ParkingID int    `json:"parking_id"`
+	UserID    int    `json:"user_id"`
+	Status    string `json:"status"`
+	Limit     int    `json:"limit"`
+	Page      int    `json:"page"`
+}
+
+type CreateBankAccountRequest struct {
+	BankCode          string `json:"bank_code" validate:"required,max=32"`
+	AccountNumber     string `json:"account_number" validate:"required,numeric,max=32"`
+	AccountHolderName string `json:"account_holder_name" validate:"required,min=3,max=255"`
+}
+
+type CreatePayoutRequest struct {
+	BankAccountID int     `json:"bank_account_id" validate:"required"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+}
+
+type ReviewPayoutRequest struct {
+	Note string `json:"note" validate:"omitempty,max=255"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	PricingRuleTimeBand  = "TIME_BAND"
+	PricingRuleDayRate   = "DAY_RATE"
+	PricingRuleFirstHour = "FIRST_HOUR"
+	PricingRuleDailyCap  = "DAILY_CAP"
+	PricingRuleEvent     = "EVENT"
+)
+
+// PricingRule adjusts the hourly fee of a parking, or of a single slot when SlotID is set.
+//
+//   - TIME_BAND charges Rate for hours starting between StartTime and EndTime, optionally only on DaysOfWeek
+//   - DAY_RATE charges Rate for hours on DaysOfWeek (e.g. weekend rates)
+//   - FIRST_HOUR charges Rate for the first booked hour
+//   - DAILY_CAP limits the fee of a single calendar day to MaxFee
+//   - EVENT charges Rate for hours between StartsAt and EndsAt and overrides every other rate
+type PricingRule struct {
+	ID         int                      `json:"id"`
+	ParkingID  int                      `json:"parking_id"`
+	SlotID     *int                     `json:"slot_id"`
+	Name       string                   `json:"name"`
+	Type       string                   `json:"type"`
+	DaysOfWeek datatypes.JSONSlice[int] `json:"days_of_week" gorm:"type:jsonb"`
+	StartTime  string                   `json:"start_time"`
+	EndTime    string                   `json:"end_time"`
+	StartsAt   *time.Time               `json:"starts_at"`
+	EndsAt     *time.Time               `json:"ends_at"`
+	Rate       float64                  `json:"rate"`
+	MaxFee     float64                  `json:"max_fee"`
+	Priority   int                      `json:"priority"`
+	IsActive   bool                     `json:"is_active"`
+	CreatedAt  time.Time                `json:"created_at"`
+	UpdatedAt  time.Time                `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt           `json:"deleted_at"`
+}
+
+type CreatePricingRuleRequest struct {
+	SlotID     *int       `json:"slot_id" validate:"omitempty"`
+	Name       string     `json:"name" validate:"required,min=3,max=255"`
+	Type       string     `json:"type" validate:"required,oneof=TIME_BAND DAY_RATE FIRST_HOUR DAILY_CAP EVENT"`
+	DaysOfWeek []int      `json:"days_of_week" validate:"omitempty,dive,min=0,max=6"`
+	StartTime  string     `json:"start_time" validate:"omitempty,datetime=15:04"`
+	EndTime    string     `json:"end_time" validate:"omitempty,datetime=15:04"`
+	StartsAt   *time.Time `json:"starts_at" validate:"required_if=Type EVENT"`
+	EndsAt     *time.Time `json:"ends_at" validate:"required_if=Type EVENT"`
+	Rate       float64    `json:"rate" validate:"min=0"`
+	MaxFee     float64    `json:"max_fee" validate:"required_if=Type DAILY_CAP,min=0"`
+	Priority   int        `json:"priority"`
+	IsActive   *bool      `json:"is_active"`
+}
+
+type UpdatePricingRuleRequest struct {
+	Name       string     `json:"name" validate:"omitempty,min=3,max=255"`
+	DaysOfWeek []int      `json:"days_of_week" validate:"omitempty,dive,min=0,max=6"`
+	StartTime  string     `json:"start_time" validate:"omitempty,datetime=15:04"`
+	EndTime    string     `json:"end_time" validate:"omitempty,datetime=15:04"`
+	StartsAt   *time.Time `json:"starts_at" validate:"omitempty"`
+	EndsAt     *time.Time `json:"ends_at" validate:"omitempty"`
+	Rate       *float64   `json:"rate" validate:"omitempty,min=0"`
+	MaxFee     *float64   `json:"max_fee" validate:"omitempty,min=0"`
+	Priority   *int       `json:"priority"`
+	IsActive   *bool      `json:"is_active"`
+}
+
+type PriceBreakdown struct {
+	TotalHours  int               `json:"total_hours"`
+	Items       []PriceLineItem   `json:"items"`
+	Adjustments []PriceAdjustment `json:"adjustments"`
+	Subtotal    float64           `json:"subtotal"`
+	Discount    float64           `json:"discount"`
+	Total       float64           `json:"total"`
+}
+
+type PriceLineItem struct {
+	Name     string    `json:"name"`
+	StartAt  time.Time `json:"start_at"`
+	Rate     float64   `json:"rate"`
+	Quantity int       `json:"quantity"`
+	Amount   float64   `json:"amount"`
+}
+
+type PriceAdjustment struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
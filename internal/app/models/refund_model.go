@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+const (
+	RefundPolicyFull    = "FULL"
+	RefundPolicyPartial = "PARTIAL"
+	RefundPolicyNone    = "NONE"
+	RefundPolicyNoShow  = "NO_SHOW"
+
+	RefundStatusPending   = "PENDING"
+	RefundStatusSucceeded = "SUCCEEDED"
+	RefundStatusFailed    = "FAILED"
+)
+
+// Refund is money returned for a single paid invoice of a booking, the booking invoice or one of its extensions.
+// CommissionAmount is the part of it the platform returns from its commission.
+type Refund struct {
+	ID               int       `json:"id"`
+	BookingID        int       `json:"booking_id"`
+	Booking          *Booking  `gorm:"foreignKey:BookingID" json:"booking,omitempty"`
+	UserID           int       `json:"user_id"`
+	ParkingID        int       `json:"parking_id"`
+	PaymentInvoiceID string    `json:"payment_invoice_id"`
+	ReferenceID      string    `json:"reference_id"`
+	ProviderRefundID string    `json:"provider_refund_id"`
+	Policy           string    `json:"policy"`
+	PaidAmount       float64   `json:"paid_amount"`
+	Amount           float64   `json:"amount"`
+	CommissionAmount float64   `json:"commission_amount"`
+	Reason           string    `json:"reason"`
+	Status           string    `json:"status"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type RefundFilter struct {
+	UserID    int `json:"user_id"`
+	ParkingID int `json:"parking_id"`
+	BookingID int `json:"booking_id"`
+	Limit     int `json:"limit"`
+	Page      int `json:"page"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	SlotHoldStatusActive   = "ACTIVE"
+	SlotHoldStatusConsumed = "CONSUMED"
+	SlotHoldStatusReleased = "RELEASED"
+)
+
+// SlotHold keeps a slot free for one user during checkout. An active hold stops holding the slot once
+// ExpiresAt has passed, and is consumed by the booking created with its token.
+type SlotHold struct {
+	ID        int          `json:"id"`
+	Token     string       `json:"token"`
+	UserID    int          `json:"user_id"`
+	ParkingID int          `json:"parking_id"`
+	SlotID    int          `json:"slot_id"`
+	Slot      *ParkingSlot `gorm:"foreignKey:SlotID" json:"slot,omitempty"`
+	StartAt   time.Time    `json:"start_at"`
+	EndAt     time.Time    `json:"end_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Status    string       `json:"status"`
+	BookingID *int         `json:"booking_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+func (h *SlotHold) IsActive(now time.Time) bool {
+	return h.Status == SlotHoldStatusActive && h.ExpiresAt.After(now)
+}
+
+type CreateSlotHoldRequest struct {
+	StartAt time.Time `json:"start_at" validate:"required"`
+	EndAt   time.Time `json:"end_at" validate:"required,gtfield=StartAt"`
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	SubscriptionPeriodWeekly  = "WEEKLY"
+	SubscriptionPeriodMonthly = "MONTHLY"
+)
+
+const (
+	// SubscriptionStatusPending is waiting for the payment of its first period
+	SubscriptionStatusPending  = "PENDING"
+	SubscriptionStatusActive   = "ACTIVE"
+	SubscriptionStatusExpired  = "EXPIRED"
+	SubscriptionStatusCanceled = "CANCELED"
+)
+
+const (
+	SubscriptionInvoiceStatusUnpaid  = "UNPAID"
+	SubscriptionInvoiceStatusPaid    = "PAID"
+	SubscriptionInvoiceStatusExpired = "EXPIRED"
+	// SubscriptionInvoiceStatusRefunded is a payment returned because it could no longer start a period
+	SubscriptionInvoiceStatusRefunded = "REFUNDED"
+)
+
+// SubscriptionPlan is a pass for a parking sold per period. The pass is only valid on DaysOfWeek between
+// StartTime and EndTime when they are set. Capacity limits the subscriptions held at the same time, 0 means
+// unlimited.
+type SubscriptionPlan struct {
+	ID          int                      `json:"id"`
+	ParkingID   int                      `json:"parking_id"`
+	Parking     *Parking                 `gorm:"foreignKey:ParkingID" json:"parking,omitempty"`
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Period      string                   `json:"period"`
+	Price       float64                  `json:"price"`
+	DaysOfWeek  datatypes.JSONSlice[int] `json:"days_of_week" gorm:"type:jsonb"`
+	StartTime   string                   `json:"start_time"`
+	EndTime     string                   `json:"end_time"`
+	Capacity    int                      `json:"capacity"`
+	IsActive    bool                     `json:"is_active"`
+	CreatedAt   time.Time                `json:"created_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt           `json:"deleted_at"`
+}
+
+// Subscription is a pass held by a user for a single plate. It is valid until CurrentPeriodEnd, and is renewed
+// for another period when the renewal invoice is paid.
+type Subscription struct {
+	ID                 int                   `json:"id"`
+	PlanID             int                   `json:"plan_id"`
+	Plan               *SubscriptionPlan     `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
+	UserID             int                   `json:"user_id"`
+	User               *User                 `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ParkingID          int                   `json:"parking_id"`
+	PlateNumber        string                `json:"plate_number"`
+	Status             string                `json:"status"`
+	AutoRenew          bool                  `json:"auto_renew"`
+	CurrentPeriodStart *time.Time            `json:"current_period_start"`
+	CurrentPeriodEnd   *time.Time            `json:"current_period_end"`
+	IsReminderSent     bool                  `json:"is_reminder_sent"`
+	Invoices           []SubscriptionInvoice `gorm:"foreignKey:SubscriptionID" json:"invoices,omitempty"`
+	CreatedAt          time.Time             `json:"created_at"`
+	UpdatedAt          time.Time             `json:"updated_at"`
+}
+
+// SubscriptionInvoice is the payment of one period of a subscription.
+type SubscriptionInvoice struct {
+	ID               int        `json:"id"`
+	SubscriptionID   int        `json:"subscription_id"`
+	PeriodStart      time.Time  `json:"period_start"`
+	PeriodEnd        time.Time  `json:"period_end"`
+	Amount           float64    `json:"amount"`
+	PaymentReference string     `json:"payment_reference"`
+	PaymentInvoiceID string     `json:"payment_invoice_id"`
+	PaymentLink      string     `json:"payment_link"`
+	PaymentExpiredAt time.Time  `json:"payment_expired_at"`
+	Status           string     `json:"status"`
+	PaidAt           *time.Time `json:"paid_at"`
+	ProviderRefundID string     `json:"provider_refund_id"`
+	Note             string     `json:"note"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+type CreateSubscriptionPlanRequest struct {
+	Name        string  `json:"name" validate:"required,min=3,max=255"`
+	Description string  `json:"description" validate:"omitempty,max=255"`
+	Period      string  `json:"period" validate:"required,oneof=WEEKLY MONTHLY"`
+	Price       float64 `json:"price" validate:"required,gt=0"`
+	DaysOfWeek  []int   `json:"days_of_week" validate:"omitempty,dive,min=0,max=6"`
+	StartTime   string  `json:"start_time" validate:"omitempty,datetime=15:04"`
+	EndTime     string  `json:"end_time" validate:"omitempty,datetime=15:04"`
+	Capacity    int     `json:"capacity" validate:"min=0"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+type UpdateSubscriptionPlanRequest struct {
+	Name        string   `json:"name" validate:"omitempty,min=3,max=255"`
+	Description string   `json:"description" validate:"omitempty,max=255"`
+	Price       *float64 `json:"price" validate:"omitempty,gt=0"`
+	DaysOfWeek  []int    `json:"days_of_week" validate:"omitempty,dive,min=0,max=6"`
+	StartTime   *string  `json:"start_time" validate:"omitempty"`
+	EndTime     *string  `json:"end_time" validate:"omitempty"`
+	Capacity    *int     `json:"capacity" validate:"omitempty,min=0"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+type CreateSubscriptionRequest struct {
+	PlanID      int    `json:"plan_id" validate:"required"`
+	PlateNumber string `json:"plate_number" validate:"required,min=2,max=16"`
+	AutoRenew   *bool  `json:"auto_renew"`
+}
+
+type SubscriptionFilter struct {
+	UserID    int    `json:"user_id"`
+	ParkingID int    `json:"parking_id"`
+	Status    string `json:"status"`
+	Limit     int    `json:"limit"`
+	Page      int    `json:"page"`
+}
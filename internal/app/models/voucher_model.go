@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	VoucherDiscountPercentage = "PERCENTAGE"
+	VoucherDiscountFixed      = "FIXED"
+
+	// VoucherRedemptionStatusActive counts towards the usage limits of the voucher
+	VoucherRedemptionStatusActive = "ACTIVE"
+	// VoucherRedemptionStatusReleased belongs to a booking that was never paid, the voucher can be used again
+	VoucherRedemptionStatusReleased = "RELEASED"
+)
+
+// Voucher is a promo code discounting the parking fee of a booking. MaxDiscount caps percentage discounts,
+// a limit of 0 means unlimited and an empty ParkingIDs list means every parking.
+type Voucher struct {
+	ID                int                      `json:"id"`
+	Code              string                   `json:"code"`
+	Description       string                   `json:"description"`
+	DiscountType      string                   `json:"discount_type"`
+	DiscountValue     float64                  `json:"discount_value"`
+	MaxDiscount       float64                  `json:"max_discount"`
+	MinSpend          float64                  `json:"min_spend"`
+	StartsAt          *time.Time               `json:"starts_at"`
+	EndsAt            *time.Time               `json:"ends_at"`
+	UsageLimit        int                      `json:"usage_limit"`
+	UsageLimitPerUser int                      `json:"usage_limit_per_user"`
+	ParkingIDs        datatypes.JSONSlice[int] `json:"parking_ids" gorm:"type:jsonb"`
+	IsActive          bool                     `json:"is_active"`
+	CreatedAt         time.Time                `json:"created_at"`
+	UpdatedAt         time.Time                `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt           `json:"deleted_at"`
+}
+
+type VoucherRedemption struct {
+	ID        int       `json:"id"`
+	VoucherID int       `json:"voucher_id"`
+	UserID    int       `json:"user_id"`
+	BookingID int       `json:"booking_id"`
+	Discount  float64   `json:"discount"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateVoucherRequest struct {
+	Code              string     `json:"code" validate:"required,alphanum,min=3,max=32"`
+	Description       string     `json:"description" validate:"omitempty,max=255"`
+	DiscountType      string     `json:"discount_type" validate:"required,oneof=PERCENTAGE FIXED"`
+	DiscountValue     float64    `json:"discount_value" validate:"required,gt=0"`
+	MaxDiscount       float64    `json:"max_discount" validate:"min=0"`
+	MinSpend          float64    `json:"min_spend" validate:"min=0"`
+	StartsAt          *time.Time `json:"starts_at" validate:"omitempty"`
+	EndsAt            *time.Time `json:"ends_at" validate:"omitempty"`
+	UsageLimit        int        `json:"usage_limit" validate:"min=0"`
+	UsageLimitPerUser int        `json:"usage_limit_per_user" validate:"min=0"`
+	ParkingIDs        []int      `json:"parking_ids" validate:"omitempty,dive,min=1"`
+	IsActive          *bool      `json:"is_active"`
+}
+
+type UpdateVoucherRequest struct {
+	Description       string     `json:"description" validate:"omitempty,max=255"`
+	DiscountValue     *float64   `json:"discount_value" validate:"omitempty,gt=0"`
+	MaxDiscount       *float64   `json:"max_discount" validate:"omitempty,min=0"`
+	MinSpend          *float64   `json:"min_spend" validate:"omitempty,min=0"`
+	StartsAt          *time.Time `json:"starts_at" validate:"omitempty"`
+	EndsAt            *time.Time `json:"ends_at" validate:"omitempty"`
+	UsageLimit        *int       `json:"usage_limit" validate:"omitempty,min=0"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user" validate:"omitempty,min=0"`
+	ParkingIDs        []int      `json:"parking_ids" validate:"omitempty,dive,min=1"`
+	IsActive          *bool      `json:"is_active"`
+}
+
+type VoucherFilter struct {
+	Search string `json:"search"`
+	Limit  int    `json:"limit"`
+	Page   int    `json:"page"`
+}
+
+type VoucherStats struct {
+	VoucherID        int     `json:"voucher_id"`
+	Code             string  `json:"code"`
+	Redemptions      int64   `json:"redemptions"`
+	ReleasedCount    int64   `json:"released_count"`
+	UniqueUsers      int64   `json:"unique_users"`
+	PaidBookings     int64   `json:"paid_bookings"`
+	TotalDiscount    float64 `json:"total_discount"`
+	RemainingUsage   *int64  `json:"remaining_usage"`
+	RevenueGenerated float64 `json:"revenue_generated"`
+}
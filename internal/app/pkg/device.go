@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// DeviceSignatureTolerance is how far the timestamp of a signed device request may be from the server time.
+const DeviceSignatureTolerance = 5 * time.Minute
+
+// SignDeviceRequest returns the hex encoded HMAC-SHA256 of a device request. The timestamp is part of the signed
+// message so a captured request can only be replayed within DeviceSignatureTolerance.
+func SignDeviceRequest(secret string, timestamp int64, method string, path string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "\n" + method + "\n" + path + "\n"))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDeviceRequest checks the signature of a device request made at timestamp against the secret of its slot.
+func VerifyDeviceRequest(secret string, signature string, timestamp int64, method string, path string, body []byte, now time.Time) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	sentAt := time.Unix(timestamp, 0)
+	if sentAt.Before(now.Add(-DeviceSignatureTolerance)) || sentAt.After(now.Add(DeviceSignatureTolerance)) {
+		return false
+	}
+
+	expected := SignDeviceRequest(secret, timestamp, method, path, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -22,6 +23,27 @@ func HandlerError(ctx *fiber.Ctx, err error) error {
 		})
 	}
 
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return ctx.Status(appErr.Code).JSON(fiber.Map{
+			"message": appErr.Message,
+		})
+	}
+
+	var transitionErr *models.BookingTransitionError
+	if errors.As(err, &transitionErr) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": transitionErr.Error(),
+		})
+	}
+
+	var payoutTransitionErr *models.PayoutTransitionError
+	if errors.As(err, &payoutTransitionErr) {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": payoutTransitionErr.Error(),
+		})
+	}
+
 	switch e := err.(type) {
 	case validator.ValidationErrors:
 		// Handle go-validator error
@@ -0,0 +1,21 @@
+package pkg
+
+import "github.com/gofiber/fiber/v2"
+
+// AppError carries the HTTP status a service error should be reported with.
+type AppError struct {
+	Code    int
+	Message string
+}
+
+func (e *AppError) Error() string {
+	return e.Message
+}
+
+func NewConflictError(message string) *AppError {
+	return &AppError{Code: fiber.StatusConflict, Message: message}
+}
+
+func NewForbiddenError(message string) *AppError {
+	return &AppError{Code: fiber.StatusForbidden, Message: message}
+}
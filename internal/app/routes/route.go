@@ -8,32 +8,65 @@ import (
 )
 
 type Route struct {
-	FiberApp          *fiber.App
-	AuthMiddleware    *middlewares.AuthMiddleware
-	AuthController    *controllers.AuthController
-	UserController    *controllers.UserController
-	ParkingController *controllers.ParkingController
-	BookingController *controllers.BookingController
-	BookingJob        *jobs.BookingJob
+	FiberApp                 *fiber.App
+	AuthMiddleware           *middlewares.AuthMiddleware
+	DeviceMiddleware         *middlewares.DeviceMiddleware
+	AuthController           *controllers.AuthController
+	UserController           *controllers.UserController
+	ParkingController        *controllers.ParkingController
+	BookingController        *controllers.BookingController
+	PricingController        *controllers.PricingController
+	PaymentController        *controllers.PaymentController
+	PayoutController         *controllers.PayoutController
+	CommissionController     *controllers.CommissionController
+	VoucherController        *controllers.VoucherController
+	SubscriptionController   *controllers.SubscriptionController
+	ParkingSessionController *controllers.ParkingSessionController
+	BookingJob               *jobs.BookingJob
+	PaymentJob               *jobs.PaymentJob
+	PayoutJob                *jobs.PayoutJob
+	SubscriptionJob          *jobs.SubscriptionJob
 }
 
 func NewRoute(
 	fiberApp *fiber.App,
 	authMiddleware *middlewares.AuthMiddleware,
+	deviceMiddleware *middlewares.DeviceMiddleware,
 	authController *controllers.AuthController,
 	userController *controllers.UserController,
 	parkingController *controllers.ParkingController,
 	bookingController *controllers.BookingController,
+	pricingController *controllers.PricingController,
+	paymentController *controllers.PaymentController,
+	payoutController *controllers.PayoutController,
+	commissionController *controllers.CommissionController,
+	voucherController *controllers.VoucherController,
+	subscriptionController *controllers.SubscriptionController,
+	parkingSessionController *controllers.ParkingSessionController,
 	bookingJob *jobs.BookingJob,
+	paymentJob *jobs.PaymentJob,
+	payoutJob *jobs.PayoutJob,
+	subscriptionJob *jobs.SubscriptionJob,
 ) *Route {
 	return &Route{
-		FiberApp:          fiberApp,
-		AuthMiddleware:    authMiddleware,
-		AuthController:    authController,
-		UserController:    userController,
-		ParkingController: parkingController,
-		BookingController: bookingController,
-		BookingJob:        bookingJob,
+		FiberApp:                 fiberApp,
+		AuthMiddleware:           authMiddleware,
+		DeviceMiddleware:         deviceMiddleware,
+		AuthController:           authController,
+		UserController:           userController,
+		ParkingController:        parkingController,
+		BookingController:        bookingController,
+		PricingController:        pricingController,
+		PaymentController:        paymentController,
+		PayoutController:         payoutController,
+		CommissionController:     commissionController,
+		VoucherController:        voucherController,
+		SubscriptionController:   subscriptionController,
+		ParkingSessionController: parkingSessionController,
+		BookingJob:               bookingJob,
+		PaymentJob:               paymentJob,
+		PayoutJob:                payoutJob,
+		SubscriptionJob:          subscriptionJob,
 	}
 }
 
@@ -49,6 +82,9 @@ func (r *Route) RegisterRoutes() {
 	userRoutes.Get("/me", r.AuthMiddleware.VerifyAuthencitated, r.UserController.GetCurrentUser)
 	userRoutes.Patch("/me", r.AuthMiddleware.VerifyAuthencitated, r.UserController.UpdateCurrentUser)
 	userRoutes.Delete("/me", r.AuthMiddleware.VerifyAuthencitated, r.UserController.DeleteCurrentUser)
+	userRoutes.Get("/me/bank-accounts", r.AuthMiddleware.VerifyAuthencitated, r.PayoutController.GetBankAccounts)
+	userRoutes.Post("/me/bank-accounts", r.AuthMiddleware.VerifyAuthencitated, r.PayoutController.CreateBankAccount)
+	userRoutes.Delete("/me/bank-accounts/:id", r.AuthMiddleware.VerifyAuthencitated, r.PayoutController.DeleteBankAccount)
 	// ADMIN ROLE
 	userRoutes.Get("/", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.UserController.GetAllUsers)
 	userRoutes.Get("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.UserController.GetUserByID)
@@ -59,23 +95,98 @@ func (r *Route) RegisterRoutes() {
 	parkingRoutes := v1.Group("/parkings")
 	parkingRoutes.Get("/", r.ParkingController.GetParkings)
 	parkingRoutes.Get("/:id", r.ParkingController.GetParkingByID)
+	parkingRoutes.Get("/:id/availability", r.ParkingController.GetParkingAvailability)
 	parkingRoutes.Get("/slug/:slug", r.ParkingController.GetParkingBySlug)
-	parkingRoutes.Patch("/slug/:slug/slot/:slot_name/status/:status", r.ParkingController.UpdateParkingSlotStatus)
+	parkingRoutes.Patch("/slug/:slug/slot/:slot_name/status/:status", r.DeviceMiddleware.VerifyDeviceSignature, r.ParkingController.UpdateParkingSlotStatus)
 	parkingRoutes.Post("/", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.ParkingController.CreateParking)
 	parkingRoutes.Patch("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.ParkingController.UpdateParking)
 	parkingRoutes.Delete("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.ParkingController.DeleteParking)
 	parkingRoutes.Post("/:id/sync", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.ParkingController.SyncParking)
+	parkingRoutes.Get("/:id/ledger", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.ParkingController.GetParkingLedger)
+	parkingRoutes.Get("/:id/payouts", r.AuthMiddleware.VerifyAuthencitated, r.PayoutController.GetParkingPayouts)
+	parkingRoutes.Post("/:id/payouts", r.AuthMiddleware.VerifyAuthencitated, r.PayoutController.RequestPayout)
+	parkingRoutes.Post("/:id/slots/:slot_id/hold", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.HoldSlot)
+	parkingRoutes.Delete("/:id/slots/:slot_id/hold/:token", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.ReleaseSlotHold)
+	parkingRoutes.Get("/:id/pricing-rules", r.PricingController.GetPricingRules)
+	parkingRoutes.Post("/:id/pricing-rules", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PricingController.CreatePricingRule)
+	parkingRoutes.Patch("/:id/pricing-rules/:rule_id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PricingController.UpdatePricingRule)
+	parkingRoutes.Delete("/:id/pricing-rules/:rule_id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PricingController.DeletePricingRule)
+	parkingRoutes.Get("/:id/sessions", r.AuthMiddleware.VerifyAuthencitated, r.ParkingSessionController.GetParkingSessions)
+	parkingRoutes.Get("/:id/occupancy", r.AuthMiddleware.VerifyAuthencitated, r.ParkingSessionController.GetOccupancyReport)
+	parkingRoutes.Get("/:id/subscription-plans", r.SubscriptionController.GetSubscriptionPlans)
+	parkingRoutes.Post("/:id/subscription-plans", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.SubscriptionController.CreateSubscriptionPlan)
+	parkingRoutes.Patch("/:id/subscription-plans/:plan_id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.SubscriptionController.UpdateSubscriptionPlan)
+	parkingRoutes.Delete("/:id/subscription-plans/:plan_id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.SubscriptionController.DeleteSubscriptionPlan)
 
 	bookingRoutes := v1.Group("/bookings")
 	bookingRoutes.Get("/", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.GetBookings)
 	bookingRoutes.Get("/history", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.BookingController.GetBookingsAdmin)
+	bookingRoutes.Get("/refunds", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.GetRefunds)
+	bookingRoutes.Get("/refunds/history", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.BookingController.GetRefundsAdmin)
+	bookingRoutes.Get("/series/:id", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.GetBookingSeriesByID)
+	bookingRoutes.Post("/series", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.CreateBookingSeries)
+	bookingRoutes.Post("/series/:id/cancel", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.CancelBookingSeries)
 	bookingRoutes.Get("/:id", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.GetBookingByID)
+	bookingRoutes.Get("/:id/timeline", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.GetBookingTimeline)
 	bookingRoutes.Get("/reference/:reference", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.GetBookingByReference)
 	bookingRoutes.Post("/", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.CreateBooking)
-	bookingRoutes.Post("/callback/payment", r.BookingController.PaymentCallback)
+	bookingRoutes.Post("/quote", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.QuoteBooking)
+	bookingRoutes.Post("/:id/cancel", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.CancelBooking)
+	bookingRoutes.Post("/:id/extend", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.ExtendBooking)
+	bookingRoutes.Post("/callback/payment", r.PaymentController.PaymentCallback)
 	bookingRoutes.Patch("/:id", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.UpdateBooking)
-	bookingRoutes.Delete("/:id", r.AuthMiddleware.VerifyAuthencitated, r.BookingController.DeleteBooking)
-	bookingRoutes.Post("/validate", r.BookingController.ValidateBooking)
+	bookingRoutes.Delete("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.BookingController.DeleteBooking)
+	bookingRoutes.Post("/validate", r.DeviceMiddleware.VerifyDeviceSignature, r.BookingController.ValidateBooking)
 	bookingRoutes.Post("/checkout/:reference", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.BookingController.Checkout)
 	bookingRoutes.Post("/checkout/plate-number/:plate_number", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.BookingController.CheckoutWithPlateNumber)
+
+	paymentRoutes := v1.Group("/payments")
+	paymentRoutes.Get("/events", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PaymentController.GetPaymentEvents)
+	paymentRoutes.Get("/events/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PaymentController.GetPaymentEventByID)
+	paymentRoutes.Post("/events/:id/replay", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PaymentController.ReplayPaymentEvent)
+	paymentRoutes.Get("/reconciliations/latest", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PaymentController.GetLatestReconciliationReport)
+	paymentRoutes.Post("/reconciliations", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PaymentController.Reconcile)
+
+	payoutRoutes := v1.Group("/payouts")
+	payoutRoutes.Get("/", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PayoutController.GetPayouts)
+	payoutRoutes.Get("/:id", r.AuthMiddleware.VerifyAuthencitated, r.PayoutController.GetPayoutByID)
+	payoutRoutes.Post("/:id/approve", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PayoutController.ApprovePayout)
+	payoutRoutes.Post("/:id/reject", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.PayoutController.RejectPayout)
+
+	commissionPlanRoutes := v1.Group("/commission-plans")
+	commissionPlanRoutes.Get("/", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.CommissionController.GetCommissionPlans)
+	commissionPlanRoutes.Get("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.CommissionController.GetCommissionPlanByID)
+	commissionPlanRoutes.Post("/", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.CommissionController.CreateCommissionPlan)
+	commissionPlanRoutes.Patch("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.CommissionController.UpdateCommissionPlan)
+	commissionPlanRoutes.Delete("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.CommissionController.DeleteCommissionPlan)
+
+	voucherRoutes := v1.Group("/vouchers")
+	voucherRoutes.Get("/", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.VoucherController.GetVouchers)
+	voucherRoutes.Get("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.VoucherController.GetVoucherByID)
+	voucherRoutes.Get("/:id/stats", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.VoucherController.GetVoucherStats)
+	voucherRoutes.Post("/", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.VoucherController.CreateVoucher)
+	voucherRoutes.Patch("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.VoucherController.UpdateVoucher)
+	voucherRoutes.Delete("/:id", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.VoucherController.DeleteVoucher)
+
+	subscriptionRoutes := v1.Group("/subscriptions")
+	subscriptionRoutes.Get("/", r.AuthMiddleware.VerifyAuthencitated, r.SubscriptionController.GetSubscriptions)
+	subscriptionRoutes.Get("/:id", r.AuthMiddleware.VerifyAuthencitated, r.SubscriptionController.GetSubscriptionByID)
+	subscriptionRoutes.Post("/", r.AuthMiddleware.VerifyAuthencitated, r.SubscriptionController.CreateSubscription)
+	subscriptionRoutes.Post("/:id/renew", r.AuthMiddleware.VerifyAuthencitated, r.SubscriptionController.RenewSubscription)
+	subscriptionRoutes.Post("/:id/cancel", r.AuthMiddleware.VerifyAuthencitated, r.SubscriptionController.CancelSubscription)
+
+	parkingSessionRoutes := v1.Group("/parking-sessions")
+	parkingSessionRoutes.Get("/reference/:reference", r.ParkingSessionController.GetParkingSessionByReference)
+	parkingSessionRoutes.Post("/checkout/plate-number/:plate_number", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.ParkingSessionController.CheckoutParkingSession)
+	parkingSessionRoutes.Post("/:id/invoice", r.AuthMiddleware.VerifyAuthencitated, r.ParkingSessionController.ReissueInvoice)
+
+	reportRoutes := v1.Group("/reports")
+	reportRoutes.Get("/revenue", r.AuthMiddleware.VerifyAuthencitated, r.AuthMiddleware.VerifyAdminAccess, r.CommissionController.GetRevenueReport)
+
+	// The invoice links of the fake payment provider point here, they only exist while it is configured
+	if _, ok := r.PaymentController.PaymentService.FakeProvider(); ok {
+		fakePaymentRoutes := r.FiberApp.Group("/fake-payments")
+		fakePaymentRoutes.Get("/:id", r.PaymentController.FakePayment)
+		fakePaymentRoutes.Post("/:id", r.PaymentController.PayFakeInvoice)
+	}
 }
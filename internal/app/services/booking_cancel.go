@@ -0,0 +1,246 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// CalculateRefund applies the cancellation policy of a parking to an amount paid for a booking starting at startAt.
+func CalculateRefund(startAt time.Time, canceledAt time.Time, fullRefundHours int, partialRefundPercent int, paid float64) (string, float64) {
+	switch {
+	case !canceledAt.Before(startAt):
+		return models.RefundPolicyNone, 0
+	case startAt.Sub(canceledAt) >= time.Duration(fullRefundHours)*time.Hour:
+		return models.RefundPolicyFull, paid
+	default:
+		return models.RefundPolicyPartial, math.Floor(paid * float64(partialRefundPercent) / 100)
+	}
+}
+
+func (s *BookingService) GetRefunds(filter *models.RefundFilter) ([]*models.Refund, error) {
+	var refunds []*models.Refund
+	query := s.DB.Preload("Booking").Order("created_at DESC")
+
+	if filter != nil {
+		if filter.UserID != 0 {
+			query = query.Where("user_id = ?", filter.UserID)
+		}
+		if filter.ParkingID != 0 {
+			query = query.Where("parking_id = ?", filter.ParkingID)
+		}
+		if filter.BookingID != 0 {
+			query = query.Where("booking_id = ?", filter.BookingID)
+		}
+		if filter.Limit != 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Page != 0 {
+			query = query.Offset((filter.Page - 1) * filter.Limit)
+		}
+	}
+
+	err := query.Find(&refunds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+// CancelBooking cancels a booking of the user. Unpaid bookings only have their invoice expired, paid bookings are
+// refunded per paid invoice according to the cancellation policy of the parking. The service fee is not refunded.
+func (s *BookingService) CancelBooking(userID int, id int, req *models.CancelBookingRequest) (*models.Booking, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := s.GetBookingByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.UserID != userID {
+		return nil, pkg.NewForbiddenError("You are not allowed to cancel this booking")
+	}
+	actor := bookingOwnerActor(userID)
+
+	switch booking.Status {
+	case models.BookingStatusUnpaid:
+		// The invoice is shared with the other occurrences of the series
+		if booking.SeriesID != nil {
+			return nil, pkg.NewConflictError("an unpaid occurrence can only be canceled with its whole series")
+		}
+
+		s.expireInvoice(booking.PaymentInvoiceID)
+
+		err = s.Lifecycle.Transition(booking, models.BookingStatusCanceled, actor)
+		if err != nil {
+			return nil, err
+		}
+
+		return booking, nil
+	case models.BookingStatusPaid:
+	default:
+		return nil, pkg.NewConflictError("booking can no longer be canceled")
+	}
+
+	now := pkg.GetCurrentTime()
+	refunds := s.paidInvoices(booking)
+
+	var created []*models.Refund
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := s.Lifecycle.TransitionTx(tx, booking, models.BookingStatusCanceled, actor)
+		if err != nil {
+			return err
+		}
+
+		created, err = s.recordRefundsTx(tx, booking, refunds, req.Reason, now, func(paid float64) (string, float64) {
+			return CalculateRefund(booking.StartAt, now, booking.Parking.CancelFullRefundHours, booking.Parking.CancelPartialRefundPercent, paid)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, refund := range created {
+		s.issueRefund(refund)
+	}
+
+	s.Lifecycle.Notify(booking)
+
+	return s.GetBookingByID(booking.ID)
+}
+
+// paidInvoices lists every invoice paid for a booking as a refund still to be sized. Invoices of extensions
+// waiting for payment are expired on the way.
+func (s *BookingService) paidInvoices(booking *models.Booking) []*models.Refund {
+	refunds := []*models.Refund{}
+	bookingPaid := booking.TotalFee
+	for _, extension := range booking.Extensions {
+		switch extension.Status {
+		case models.BookingExtensionStatusUnpaid:
+			s.expireInvoice(extension.PaymentInvoiceID)
+		case models.BookingExtensionStatusPaid:
+			bookingPaid -= extension.Fee
+			if extension.Fee > 0 {
+				refunds = append(refunds, &models.Refund{
+					PaymentInvoiceID: extension.PaymentInvoiceID,
+					ReferenceID:      extension.PaymentReference,
+					PaidAmount:       extension.Fee,
+				})
+			}
+		}
+	}
+
+	return append([]*models.Refund{{
+		PaymentInvoiceID: booking.PaymentInvoiceID,
+		ReferenceID:      booking.PaymentReference,
+		PaidAmount:       bookingPaid,
+	}}, refunds...)
+}
+
+// recordRefundsTx sizes the refunds with policy and records those worth issuing within tx, along with expiring the
+// unpaid extensions of the booking. The returned refunds are issued once tx is committed.
+func (s *BookingService) recordRefundsTx(tx *gorm.DB, booking *models.Booking, refunds []*models.Refund, reason string, now time.Time, policy func(paid float64) (string, float64)) ([]*models.Refund, error) {
+	err := tx.Model(&models.BookingExtension{}).
+		Where("booking_id = ? AND status = ?", booking.ID, models.BookingExtensionStatusUnpaid).
+		Updates(map[string]interface{}{
+			"status":     models.BookingExtensionStatusExpired,
+			"updated_at": now,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var created []*models.Refund
+	for _, refund := range refunds {
+		refund.Policy, refund.Amount = policy(refund.PaidAmount)
+		if refund.Amount <= 0 || refund.PaymentInvoiceID == "" {
+			continue
+		}
+
+		refund.BookingID = booking.ID
+		refund.UserID = booking.UserID
+		refund.ParkingID = booking.ParkingID
+		refund.Reason = reason
+		refund.Status = models.RefundStatusPending
+		if booking.TotalFee > 0 {
+			refund.CommissionAmount = math.Round(refund.Amount*booking.CommissionFee/booking.TotalFee*100) / 100
+		}
+		err = tx.Create(refund).Error
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.Lifecycle.Ledger.RecordRefundTx(tx, refund)
+		if err != nil {
+			return nil, err
+		}
+
+		created = append(created, refund)
+	}
+
+	return created, nil
+}
+
+// issueRefund sends a recorded refund to the payment gateway. A refund the gateway rejects is marked FAILED and
+// reversed in the ledger.
+func (s *BookingService) issueRefund(refund *models.Refund) {
+	paymentRefund, refundErr := s.PaymentProvider.Refund(&paymentgateway.RefundRequest{
+		InvoiceID:   refund.PaymentInvoiceID,
+		ReferenceID: refund.ReferenceID + "-RFD",
+		Amount:      refund.Amount,
+		Reason:      "CANCELLATION",
+	})
+	if refundErr != nil {
+		logrus.Errorf("Failed to refund %s: %v", refund.ReferenceID, refundErr)
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(refund).Updates(map[string]interface{}{
+				"status": models.RefundStatusFailed,
+				"note":   refundErr.Error(),
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			return s.Lifecycle.Ledger.RecordRefundReversalTx(tx, refund)
+		})
+		if err != nil {
+			logrus.Errorf("Failed to mark refund %s as failed: %v", refund.ReferenceID, err)
+		}
+		return
+	}
+
+	// Xendit settles refunds asynchronously, they stay PENDING until the gateway confirms them
+	status := models.RefundStatusPending
+	if paymentRefund.Status == models.RefundStatusSucceeded {
+		status = models.RefundStatusSucceeded
+	}
+
+	err := s.DB.Model(refund).Updates(map[string]interface{}{
+		"status":             status,
+		"provider_refund_id": paymentRefund.ID,
+	}).Error
+	if err != nil {
+		logrus.Errorf("Failed to update refund %s: %v", refund.ReferenceID, err)
+	}
+}
+
+func (s *BookingService) expireInvoice(invoiceID string) {
+	if invoiceID == "" {
+		return
+	}
+
+	_, err := s.PaymentProvider.ExpireInvoice(invoiceID)
+	if err != nil {
+		logrus.Errorf("Failed to expire invoice %s: %v", invoiceID, err)
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// recordBookingEventTx appends an event to the timeline of a booking within tx.
+func recordBookingEventTx(tx *gorm.DB, booking *models.Booking, eventType string, actor *models.BookingActor, payload map[string]interface{}) error {
+	return tx.Create(newBookingEvent(booking, eventType, actor, payload)).Error
+}
+
+// recordBookingCreatedTx starts the timeline of a new booking.
+func recordBookingCreatedTx(tx *gorm.DB, booking *models.Booking, actor *models.BookingActor) error {
+	return recordBookingEventTx(tx, booking, models.BookingEventCreated, actor, map[string]interface{}{
+		"slot_id":      booking.SlotID,
+		"plate_number": booking.PlateNumber,
+		"start_at":     booking.StartAt,
+		"end_at":       booking.EndAt,
+		"total_fee":    booking.TotalFee,
+	})
+}
+
+// recordInvoiceIssuedTx records the invoice a booking is paid with.
+func recordInvoiceIssuedTx(tx *gorm.DB, booking *models.Booking, actor *models.BookingActor, kind string, reference string, paymentInvoice *paymentgateway.Invoice) error {
+	return recordBookingEventTx(tx, booking, models.BookingEventInvoiceIssued, actor, map[string]interface{}{
+		"kind":       kind,
+		"reference":  reference,
+		"invoice_id": paymentInvoice.ID,
+		"amount":     paymentInvoice.Amount,
+		"expires_at": paymentInvoice.ExpiryDate,
+	})
+}
+
+// recordBookingEvent appends an event that already happened to the timeline of a booking. A failure is only logged,
+// the event is not undone for it.
+func (s *BookingService) recordBookingEvent(booking *models.Booking, eventType string, actor *models.BookingActor, payload map[string]interface{}) {
+	err := s.DB.Create(newBookingEvent(booking, eventType, actor, payload)).Error
+	if err != nil {
+		logrus.Errorf("Failed to record %s event of booking %s: %v", eventType, booking.PaymentReference, err)
+	}
+}
+
+// bookingOwnerActor is the actor of a change the user of a booking made through the API.
+func bookingOwnerActor(userID int) *models.BookingActor {
+	return &models.BookingActor{Type: models.BookingActorUser, UserID: &userID, Source: models.BookingEventSourceAPI}
+}
+
+func newBookingEvent(booking *models.Booking, eventType string, actor *models.BookingActor, payload map[string]interface{}) *models.BookingEvent {
+	return &models.BookingEvent{
+		BookingID: booking.ID,
+		Type:      eventType,
+		ActorType: actor.Type,
+		ActorID:   actor.UserID,
+		Source:    actor.Source,
+		Payload:   payload,
+		CreatedAt: pkg.GetCurrentTime(),
+	}
+}
+
+// GetBookingTimeline lists the events of a booking, oldest first, for the user of the booking or an admin.
+func (s *BookingService) GetBookingTimeline(user *models.User, id int) ([]*models.BookingEvent, error) {
+	var booking models.Booking
+	err := s.DB.Select("id, user_id").First(&booking, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.UserID != user.ID && !user.IsAdmin() {
+		return nil, pkg.NewForbiddenError("You are not allowed to view the timeline of this booking")
+	}
+
+	var events []*models.BookingEvent
+	err = s.DB.Where("booking_id = ?", id).Order("created_at ASC, id ASC").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
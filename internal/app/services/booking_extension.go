@@ -0,0 +1,360 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const bookingExtensionReferencePrefix = "PKGO-EXT-"
+
+var errExtensionNotApplicable = errors.New("booking changed since the extension was requested")
+
+// ExtendBooking requests a later end for a paid booking. The slot is kept for the additional window while the
+// supplementary invoice is pending, and the booking only moves once that invoice is paid.
+func (s *BookingService) ExtendBooking(userID int, id int, req *models.ExtendBookingRequest) (*models.BookingExtension, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := s.GetBookingByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.UserID != userID {
+		return nil, pkg.NewForbiddenError("You are not allowed to extend this booking")
+	}
+	actor := bookingOwnerActor(userID)
+
+	if booking.Status != models.BookingStatusPaid {
+		return nil, pkg.NewConflictError("only paid bookings can be extended")
+	}
+
+	now := pkg.GetCurrentTime()
+	if booking.EndAt.Before(now) {
+		return nil, pkg.NewConflictError("booking has already ended")
+	}
+
+	if req.EndAt.Sub(booking.EndAt) < time.Hour {
+		return nil, errors.New("booking must be extended by at least 1 hour")
+	}
+
+	var pendingCount int64
+	err = s.DB.Model(&models.BookingExtension{}).
+		Where("booking_id = ? AND status = ? AND payment_expired_at > ?", booking.ID, models.BookingExtensionStatusUnpaid, now).
+		Count(&pendingCount).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if pendingCount > 0 {
+		return nil, pkg.NewConflictError("booking already has a pending extension")
+	}
+
+	conflicts, err := findSlotConflicts(s.DB, slotConflictFilter{SlotID: booking.SlotID, ExcludeBookingID: booking.ID}, booking.EndAt, req.EndAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conflicts[booking.SlotID]) > 0 {
+		return nil, ErrSlotAlreadyBooked
+	}
+
+	currentPrice, err := s.PricingService.QuoteSlotPrice(booking.Slot, booking.StartAt, booking.EndAt)
+	if err != nil {
+		return nil, err
+	}
+
+	extendedPrice, err := s.PricingService.QuoteSlotPrice(booking.Slot, booking.StartAt, req.EndAt)
+	if err != nil {
+		return nil, err
+	}
+
+	extension := models.BookingExtension{
+		BookingID:        booking.ID,
+		PreviousEndAt:    booking.EndAt,
+		NewEndAt:         req.EndAt,
+		AdditionalHours:  extendedPrice.TotalHours - currentPrice.TotalHours,
+		Fee:              extendedPrice.Total - currentPrice.Total,
+		PaymentReference: bookingExtensionReferencePrefix + pkg.RandomString(8),
+		Status:           models.BookingExtensionStatusUnpaid,
+	}
+
+	// Caps can make the extra hours free, there is nothing to pay for
+	if extension.Fee <= 0 {
+		extension.Fee = 0
+		extension.PaymentExpiredAt = now
+		err = s.DB.Create(&extension).Error
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.applyExtension(booking, &extension, actor)
+		if err != nil {
+			return nil, err
+		}
+
+		return &extension, nil
+	}
+
+	// The extension is committed before the invoice is created, its expiry keeps a second request out meanwhile
+	extension.PaymentExpiredAt = now.Add(bookingInvoiceDuration)
+	err = s.DB.Create(&extension).Error
+	if err != nil {
+		return nil, err
+	}
+
+	paymentInvoice, err := s.PaymentProvider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{
+		ExternalID:         extension.PaymentReference,
+		Amount:             extension.Fee,
+		Currency:           "IDR",
+		Description:        fmt.Sprintf("Parking extension for %s", booking.PlateNumber),
+		PayerEmail:         booking.User.Email,
+		CustomerID:         strconv.Itoa(booking.User.ID),
+		CustomerName:       booking.User.FullName,
+		SuccessRedirectURL: fmt.Sprintf("https://parkingo.agil.zip/b/%s", booking.PaymentReference),
+		Duration:           bookingInvoiceDuration,
+		Items: []paymentgateway.InvoiceItem{
+			{
+				Name:        fmt.Sprintf("%s | %s | %s | Extension until %s", booking.Parking.Name, booking.Slot.Name, booking.PlateNumber, req.EndAt.In(now.Location()).Format("2006-01-02 15:04")),
+				Price:       extension.Fee,
+				Quantity:    1,
+				ReferenceID: booking.Parking.Slug,
+			},
+		},
+	})
+	if err != nil {
+		logrus.Error("Payment Error:", err)
+		s.abandonExtension(&extension)
+		return nil, err
+	}
+
+	extension.PaymentInvoiceID = paymentInvoice.ID
+	extension.PaymentLink = paymentInvoice.InvoiceURL
+	extension.PaymentExpiredAt = paymentInvoice.ExpiryDate
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&extension).Updates(map[string]interface{}{
+			"payment_invoice_id": extension.PaymentInvoiceID,
+			"payment_link":       extension.PaymentLink,
+			"payment_expired_at": extension.PaymentExpiredAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return recordInvoiceIssuedTx(tx, booking, actor, "EXTENSION", extension.PaymentReference, paymentInvoice)
+	})
+	if err != nil {
+		s.expireInvoice(paymentInvoice.ID)
+		s.abandonExtension(&extension)
+		return nil, err
+	}
+
+	go s.MailService.SendMail(booking.User.Email, fmt.Sprintf("Booking Extension %s", booking.PaymentReference), fmt.Sprintf("Your extension request has been created. Please complete the payment: %s", extension.PaymentLink))
+
+	return &extension, nil
+}
+
+func (s *BookingService) handleExtensionPayment(event *paymentgateway.WebhookEvent, actor *models.BookingActor) (*models.Booking, error) {
+	var extension models.BookingExtension
+	err := s.DB.Where("payment_reference = ?", event.ExternalID).First(&extension).Error
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := s.GetBookingByID(extension.BookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case event.IsPaid():
+		err = checkPaidEvent(event, extension.Fee)
+		if err != nil {
+			return nil, err
+		}
+		err = s.applyExtension(booking, &extension, actor)
+	case event.Status == paymentgateway.InvoiceStatusExpired:
+		err = s.DB.Model(&models.BookingExtension{}).
+			Where("id = ? AND status = ?", extension.ID, models.BookingExtensionStatusUnpaid).
+			Updates(map[string]interface{}{
+				"status":     models.BookingExtensionStatusExpired,
+				"updated_at": pkg.GetCurrentTime(),
+			}).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetBookingByID(booking.ID)
+}
+
+// applyExtension moves the booking end once the extension is paid. When the booking can no longer be extended
+// the extension is marked FAILED and its payment refunded. An extension expired by a cancellation can still be
+// paid at the gateway, its payment is applied or refunded the same way.
+func (s *BookingService) applyExtension(booking *models.Booking, extension *models.BookingExtension, actor *models.BookingActor) error {
+	applied := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.BookingExtension{}).
+			Where("id = ? AND status IN ?", extension.ID, []string{models.BookingExtensionStatusUnpaid, models.BookingExtensionStatusExpired}).
+			Updates(map[string]interface{}{
+				"status":     models.BookingExtensionStatusPaid,
+				"updated_at": pkg.GetCurrentTime(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Already handled by a previous delivery
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var current models.Booking
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, booking.ID).Error
+		if err != nil {
+			return err
+		}
+
+		if current.Status != models.BookingStatusPaid || !current.EndAt.Equal(extension.PreviousEndAt) {
+			return errExtensionNotApplicable
+		}
+
+		err = tx.Model(&current).Updates(map[string]interface{}{
+			"end_at":      extension.NewEndAt,
+			"total_hours": current.TotalHours + extension.AdditionalHours,
+			"total_fee":   current.TotalFee + extension.Fee,
+			"updated_at":  pkg.GetCurrentTime(),
+		}).Error
+		if err != nil {
+			if isSlotOverlapError(err) {
+				return ErrSlotAlreadyBooked
+			}
+			return err
+		}
+
+		if extension.Fee > 0 {
+			err = s.Lifecycle.Ledger.RecordPaymentTx(tx, models.LedgerEntryExtensionPayment, &current, extension.PaymentReference, extension.Fee)
+			if err != nil {
+				return err
+			}
+
+			err = s.Lifecycle.ChargeCommissionTx(tx, &current, extension.PaymentReference, extension.Fee)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = recordBookingEventTx(tx, &current, models.BookingEventExtended, actor, map[string]interface{}{
+			"reference":       extension.PaymentReference,
+			"previous_end_at": extension.PreviousEndAt,
+			"new_end_at":      extension.NewEndAt,
+			"fee":             extension.Fee,
+		})
+		if err != nil {
+			return err
+		}
+
+		applied = true
+		return nil
+	})
+	if errors.Is(err, errExtensionNotApplicable) || errors.Is(err, ErrSlotAlreadyBooked) {
+		return s.failExtension(booking, extension, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	if applied {
+		extension.Status = models.BookingExtensionStatusPaid
+		booking.EndAt = extension.NewEndAt
+		go s.MailService.SendMail(booking.User.Email, fmt.Sprintf("Booking Extended %s", booking.PaymentReference), fmt.Sprintf("Your booking has been extended until %s. Booking detail: https://parkingo.agil.zip/b/%s", extension.NewEndAt.In(pkg.GetCurrentTime().Location()).Format("2006-01-02 15:04"), booking.PaymentReference))
+	}
+
+	return nil
+}
+
+// abandonExtension expires an extension whose invoice could not be attached.
+func (s *BookingService) abandonExtension(extension *models.BookingExtension) {
+	err := s.DB.Model(&models.BookingExtension{}).
+		Where("id = ? AND status = ?", extension.ID, models.BookingExtensionStatusUnpaid).
+		Updates(map[string]interface{}{
+			"status":     models.BookingExtensionStatusExpired,
+			"updated_at": pkg.GetCurrentTime(),
+		}).Error
+	if err != nil {
+		logrus.Errorf("Failed to expire extension %s without invoice: %v", extension.PaymentReference, err)
+	}
+}
+
+// failExtension marks a paid extension that can no longer be applied FAILED. Its payment is credited to the parking
+// and refunded in full like any other refund, so the ledger and the refunds of the booking account for it.
+func (s *BookingService) failExtension(booking *models.Booking, extension *models.BookingExtension, reason string) error {
+	now := pkg.GetCurrentTime()
+	failed := false
+	var created []*models.Refund
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.BookingExtension{}).
+			Where("id = ? AND status IN ?", extension.ID, []string{models.BookingExtensionStatusUnpaid, models.BookingExtensionStatusExpired}).
+			Updates(map[string]interface{}{
+				"status":     models.BookingExtensionStatusFailed,
+				"note":       reason,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Already handled by a previous delivery
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		failed = true
+
+		if extension.Fee <= 0 || extension.PaymentInvoiceID == "" {
+			return nil
+		}
+
+		err := s.Lifecycle.Ledger.RecordPaymentTx(tx, models.LedgerEntryExtensionPayment, booking, extension.PaymentReference, extension.Fee)
+		if err != nil {
+			return err
+		}
+
+		// No commission was charged on the extension, none is returned with its refund
+		refunded := *booking
+		refunded.CommissionFee = 0
+		created, err = s.recordRefundsTx(tx, &refunded, []*models.Refund{{
+			PaymentInvoiceID: extension.PaymentInvoiceID,
+			ReferenceID:      extension.PaymentReference,
+			PaidAmount:       extension.Fee,
+		}}, reason, now, func(paid float64) (string, float64) {
+			return models.RefundPolicyFull, paid
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !failed {
+		return nil
+	}
+	extension.Status = models.BookingExtensionStatusFailed
+
+	for _, refund := range created {
+		s.issueRefund(refund)
+	}
+
+	go s.MailService.SendMail(booking.User.Email, fmt.Sprintf("Booking Extension Failed %s", booking.PaymentReference), fmt.Sprintf("Your booking could not be extended (%s). Any payment for the extension will be refunded. Booking detail: https://parkingo.agil.zip/b/%s", reason, booking.PaymentReference))
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// BookingLifecycle is the only place where a booking status may change.
+// It validates the transition against models.BookingTransitions and applies the side effects of the new status.
+type BookingLifecycle struct {
+	DB          *gorm.DB
+	MailService *MailService
+	Ledger      *LedgerService
+}
+
+func NewBookingLifecycle(db *gorm.DB, mailService *MailService, ledger *LedgerService) *BookingLifecycle {
+	return &BookingLifecycle{
+		DB:          db,
+		MailService: mailService,
+		Ledger:      ledger,
+	}
+}
+
+var bookingSlotStatuses = map[string]string{
+	models.BookingStatusUnpaid:    "BOOKED",
+	models.BookingStatusPaid:      "OCCUPIED",
+	models.BookingStatusCanceled:  "AVAILABLE",
+	models.BookingStatusExpired:   "AVAILABLE",
+	models.BookingStatusCompleted: "AVAILABLE",
+	// The vehicle is held at the exit until the overtime is paid
+	models.BookingStatusOvertimeUnpaid: "OCCUPIED",
+	// The slot is given to walk-ins
+	models.BookingStatusNoShow: "AVAILABLE",
+}
+
+// updateBookingSlotTx keeps the slot status in line with a booking moving from one status to another. Only a booking
+// running now holds the slot, and the slot is only released from the status the booking put it in while no vehicle
+// is parked on it and no other booking of it is running.
+func updateBookingSlotTx(tx *gorm.DB, booking *models.Booking, from string, to string) error {
+	slotStatus, ok := bookingSlotStatuses[to]
+	if !ok {
+		return nil
+	}
+
+	now := pkg.GetCurrentTime()
+	query := tx.Model(&models.ParkingSlot{}).Where("id = ?", booking.SlotID)
+	if slotStatus == "AVAILABLE" {
+		heldStatus, ok := bookingSlotStatuses[from]
+		if !ok || heldStatus == "AVAILABLE" {
+			return nil
+		}
+
+		query = query.Where("status = ?", heldStatus).
+			Where("NOT EXISTS (?)", tx.Model(&models.ParkingSession{}).Select("1").
+				Where("slot_id = ? AND status = ?", booking.SlotID, models.ParkingSessionStatusOpen)).
+			Where("NOT EXISTS (?)", tx.Model(&models.Booking{}).Select("1").
+				Where("slot_id = ? AND id <> ? AND status IN ? AND start_at <= ? AND end_at > ?", booking.SlotID, booking.ID,
+					[]string{models.BookingStatusUnpaid, models.BookingStatusPaid, models.BookingStatusOvertimeUnpaid},
+					now.Add(bookingArrivalTolerance), now))
+	} else {
+		// An overtime booking already ended but its vehicle is still on the slot
+		running := !booking.StartAt.After(now.Add(bookingArrivalTolerance)) && (to == models.BookingStatusOvertimeUnpaid || booking.EndAt.After(now))
+		if !running {
+			return nil
+		}
+
+		query = query.Where("status <> ?", slotStatus)
+	}
+
+	result := query.Update("status", slotStatus)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 && booking.Slot != nil {
+		booking.Slot.Status = slotStatus
+	}
+
+	return nil
+}
+
+// Transition moves the booking to the given status in its own transaction and notifies the user once committed.
+func (l *BookingLifecycle) Transition(booking *models.Booking, to string, actor *models.BookingActor) error {
+	changed := false
+	err := l.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		changed, err = l.TransitionTx(tx, booking, to, actor)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		l.Notify(booking)
+	}
+
+	return nil
+}
+
+// TransitionTx moves the booking to the given status within tx. It reports false without error when the
+// booking already has that status, so repeated deliveries of the same change are harmless. The change is recorded
+// on the timeline of the booking with the actor that caused it.
+func (l *BookingLifecycle) TransitionTx(tx *gorm.DB, booking *models.Booking, to string, actor *models.BookingActor) (bool, error) {
+	from := booking.Status
+	if from == to {
+		return false, nil
+	}
+
+	if !models.CanTransitionBooking(from, to) {
+		return false, &models.BookingTransitionError{Reference: booking.PaymentReference, From: from, To: to}
+	}
+
+	result := tx.Model(&models.Booking{}).
+		Where("id = ? AND status = ?", booking.ID, from).
+		Updates(map[string]interface{}{
+			"status":     to,
+			"updated_at": pkg.GetCurrentTime(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	// Someone else changed the booking since it was loaded
+	if result.RowsAffected == 0 {
+		var current models.Booking
+		err := tx.Select("status").First(&current, booking.ID).Error
+		if err != nil {
+			return false, err
+		}
+
+		booking.Status = current.Status
+		if current.Status == to {
+			return false, nil
+		}
+		return false, &models.BookingTransitionError{Reference: booking.PaymentReference, From: current.Status, To: to}
+	}
+	booking.Status = to
+
+	event := newBookingEvent(booking, models.BookingEventStatusChanged, actor, nil)
+	event.FromStatus = from
+	event.ToStatus = to
+	err := tx.Create(event).Error
+	if err != nil {
+		return false, err
+	}
+
+	err = updateBookingSlotTx(tx, booking, from, to)
+	if err != nil {
+		return false, err
+	}
+
+	// A voucher on a booking that was never paid can be used again
+	if from == models.BookingStatusUnpaid && (to == models.BookingStatusExpired || to == models.BookingStatusCanceled) && booking.VoucherID != nil {
+		err := tx.Model(&models.VoucherRedemption{}).
+			Where("booking_id = ? AND status = ?", booking.ID, models.VoucherRedemptionStatusActive).
+			Updates(map[string]interface{}{
+				"status":     models.VoucherRedemptionStatusReleased,
+				"updated_at": pkg.GetCurrentTime(),
+			}).Error
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if to == models.BookingStatusPaid {
+		err := tx.Model(&models.Parking{}).Where("id = ?", booking.ParkingID).Update("total_bookings", gorm.Expr("total_bookings + 1")).Error
+		if err != nil {
+			return false, err
+		}
+
+		err = l.Ledger.RecordPaymentTx(tx, models.LedgerEntryBookingPayment, booking, booking.PaymentReference, booking.TotalFee)
+		if err != nil {
+			return false, err
+		}
+
+		err = l.Ledger.RecordServiceFeeTx(tx, booking, booking.PaymentReference, booking.ServiceFee)
+		if err != nil {
+			return false, err
+		}
+
+		err = l.Ledger.RecordCommissionTx(tx, booking, booking.PaymentReference, booking.CommissionFee)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// ChargeCommissionTx keeps the commission on a later payment for a booking, such as an extension or overtime.
+func (l *BookingLifecycle) ChargeCommissionTx(tx *gorm.DB, booking *models.Booking, reference string, amount float64) error {
+	plan, err := bookingCommissionPlan(tx, booking)
+	if err != nil || plan == nil {
+		return err
+	}
+
+	commission := CalculateCommission(plan, amount, false)
+	if commission == 0 {
+		return nil
+	}
+
+	err = tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Update("commission_fee", gorm.Expr("commission_fee + ?", commission)).Error
+	if err != nil {
+		return err
+	}
+	booking.CommissionFee += commission
+
+	return l.Ledger.RecordCommissionTx(tx, booking, reference, commission)
+}
+
+func (l *BookingLifecycle) Notify(booking *models.Booking) {
+	var subject, content string
+	link := fmt.Sprintf("https://parkingo.agil.zip/b/%s", booking.PaymentReference)
+
+	switch booking.Status {
+	case models.BookingStatusPaid:
+		subject = fmt.Sprintf("Payment Received %s", booking.PaymentReference)
+		content = fmt.Sprintf("Your payment has been received and your parking slot is reserved. Booking detail: %s", link)
+	case models.BookingStatusCanceled:
+		subject = fmt.Sprintf("Booking Canceled %s", booking.PaymentReference)
+		content = fmt.Sprintf("Your booking has been canceled. Booking detail: %s", link)
+	case models.BookingStatusExpired:
+		subject = fmt.Sprintf("Booking Expired %s", booking.PaymentReference)
+		content = fmt.Sprintf("Your booking has expired because the payment was not completed in time. Booking detail: %s", link)
+	case models.BookingStatusOvertimeUnpaid:
+		subject = fmt.Sprintf("Overtime Fee %s", booking.PaymentReference)
+		content = fmt.Sprintf("Your booking ended %d minutes ago. Please pay the overtime fee of IDR %.0f to complete your checkout: %s", booking.OvertimeMinutes, booking.OvertimeFee, booking.OvertimeLink)
+	case models.BookingStatusNoShow:
+		subject = fmt.Sprintf("Booking No-Show %s", booking.PaymentReference)
+		content = fmt.Sprintf("Your vehicle did not arrive in time, so your parking slot has been released. Any refund under the no-show policy of the parking is on its way. Booking detail: %s", link)
+	case models.BookingStatusCompleted:
+		subject = fmt.Sprintf("Booking Completed %s", booking.PaymentReference)
+		content = fmt.Sprintf("Thank you for parking with us. Booking detail: %s", link)
+	default:
+		return
+	}
+
+	user := booking.User
+	if user == nil {
+		err := l.DB.First(&user, booking.UserID).Error
+		if err != nil {
+			logrus.Error("Failed to get booking user: ", err)
+			return
+		}
+	}
+
+	go l.MailService.SendMail(user.Email, subject, content)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// CalculateNoShowRefund applies the no-show policy of a parking to an amount paid for a booking.
+func CalculateNoShowRefund(refundPercent int, paid float64) (string, float64) {
+	if refundPercent <= 0 {
+		return models.RefundPolicyNone, 0
+	}
+	return models.RefundPolicyNoShow, math.Floor(paid * float64(min(refundPercent, 100)) / 100)
+}
+
+// IsNoShow reports whether the plate of a paid booking was not seen within the no-show window of its parking.
+func IsNoShow(booking *models.Booking, parking *models.Parking, now time.Time) bool {
+	if parking == nil || parking.NoShowMinutes <= 0 {
+		return false
+	}
+	if booking.Status != models.BookingStatusPaid || booking.ArrivedAt != nil {
+		return false
+	}
+	return !now.Before(booking.StartAt.Add(time.Duration(parking.NoShowMinutes) * time.Minute))
+}
+
+// MarkNoShows releases the slots of paid bookings whose vehicle never arrived, refunding them according to the
+// no-show policy of their parking.
+func (s *BookingService) MarkNoShows() (int, error) {
+	now := pkg.GetCurrentTime()
+
+	var bookings []*models.Booking
+	err := s.DB.Preload("User").Preload("Slot").Preload("Parking").Preload("Extensions").
+		Where("status = ? AND arrived_at IS NULL AND start_at <= ?", models.BookingStatusPaid, now).
+		Where("parking_id IN (?)", s.DB.Model(&models.Parking{}).Select("id").Where("no_show_minutes > 0")).
+		Find(&bookings).Error
+	if err != nil {
+		return 0, err
+	}
+
+	actor := models.NewSystemBookingActor(models.BookingEventSourceJob)
+	marked := 0
+	for _, booking := range bookings {
+		if !IsNoShow(booking, booking.Parking, now) {
+			continue
+		}
+
+		refunds := s.paidInvoices(booking)
+
+		changed := false
+		var created []*models.Refund
+		err = s.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			changed, err = s.Lifecycle.TransitionTx(tx, booking, models.BookingStatusNoShow, actor)
+			if err != nil || !changed {
+				return err
+			}
+
+			err = tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Update("no_show_at", now).Error
+			if err != nil {
+				return err
+			}
+			booking.NoShowAt = &now
+
+			created, err = s.recordRefundsTx(tx, booking, refunds, "No-show", now, func(paid float64) (string, float64) {
+				return CalculateNoShowRefund(booking.Parking.NoShowRefundPercent, paid)
+			})
+			return err
+		})
+		if err != nil {
+			logrus.Errorf("Failed to mark booking %s as no-show: %v", booking.PaymentReference, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		for _, refund := range created {
+			s.issueRefund(refund)
+		}
+
+		marked++
+		s.Lifecycle.Notify(booking)
+	}
+
+	return marked, nil
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const bookingOvertimeReferencePrefix = "PKGO-OVT-"
+
+// CalculateOvertime returns how long the vehicle stayed past endAt and the fee for it. Nothing is charged within
+// the grace period, past it every started hour is charged at rate.
+func CalculateOvertime(endAt time.Time, checkoutAt time.Time, graceMinutes int, rate float64) (int, float64) {
+	overtime := checkoutAt.Sub(endAt)
+	if overtime <= time.Duration(graceMinutes)*time.Minute {
+		return 0, 0
+	}
+
+	minutes := int(math.Ceil(overtime.Minutes()))
+	hours := math.Ceil(overtime.Hours())
+
+	return minutes, hours * rate
+}
+
+// checkout completes the booking, or issues an overtime invoice first when the vehicle left after the grace period.
+// Overtime bookings only complete once the invoice is paid.
+func (s *BookingService) checkout(booking *models.Booking, actor *models.BookingActor) (*models.Booking, error) {
+	switch booking.Status {
+	case models.BookingStatusCompleted:
+		return booking, fmt.Errorf("Booking with reference %s is already completed", booking.PaymentReference)
+	case models.BookingStatusOvertimeUnpaid:
+		paymentInvoice, err := s.PaymentProvider.GetInvoice(booking.OvertimeInvoiceID)
+		if err != nil {
+			return booking, err
+		}
+
+		if paymentInvoice.IsPaid() {
+			err = s.completeOvertime(booking, actor)
+			return booking, err
+		}
+
+		if paymentInvoice.Status != paymentgateway.InvoiceStatusExpired {
+			return booking, nil
+		}
+
+		// The vehicle may have stayed while the invoice expired, the overtime is charged again up to its exit
+		minutes, fee, err := s.overtimeUntilExit(booking, actor)
+		if err != nil {
+			return booking, err
+		}
+		if fee > 0 {
+			err = s.chargeOvertime(booking, minutes, fee, actor)
+			return booking, err
+		}
+	case models.BookingStatusPaid:
+		minutes, fee, err := s.overtimeUntilExit(booking, actor)
+		if err != nil {
+			return booking, err
+		}
+		if fee > 0 {
+			err := s.chargeOvertime(booking, minutes, fee, actor)
+			return booking, err
+		}
+	}
+
+	err := s.Lifecycle.Transition(booking, models.BookingStatusCompleted, actor)
+	if err != nil {
+		return booking, err
+	}
+
+	return booking, nil
+}
+
+// SettleEndedBookings completes the paid bookings past their end. A vehicle still parked keeps its booking open until
+// it is checked out, one that already left is checked out here so overtime is invoiced before the booking completes.
+func (s *BookingService) SettleEndedBookings() (int, error) {
+	var bookings []*models.Booking
+	err := s.DB.Preload("User").Preload("Parking").Preload("Slot").
+		Where("status = ? AND end_at < ?", models.BookingStatusPaid, pkg.GetCurrentTime()).
+		Find(&bookings).Error
+	if err != nil {
+		return 0, err
+	}
+
+	actor := models.NewSystemBookingActor(models.BookingEventSourceJob)
+	settled := 0
+	for _, booking := range bookings {
+		var openSessions, sessions int64
+		err = s.DB.Model(&models.ParkingSession{}).Where("booking_id = ? AND status = ?", booking.ID, models.ParkingSessionStatusOpen).Count(&openSessions).Error
+		if err != nil {
+			return settled, err
+		}
+		err = s.DB.Model(&models.ParkingSession{}).Where("booking_id = ?", booking.ID).Count(&sessions).Error
+		if err != nil {
+			return settled, err
+		}
+
+		// The vehicle was seen past its end and has not left yet, overtime is billed at checkout
+		if openSessions > 0 || (booking.IsNotifyOvertimeSent && booking.Slot != nil && booking.Slot.Status == "OCCUPIED") {
+			logrus.Infof("Booking %s vehicle is still parked, waiting for checkout", booking.PaymentReference)
+			continue
+		}
+
+		// A vehicle that was never seen has no exit to price overtime up to
+		if sessions == 0 {
+			err = s.Lifecycle.Transition(booking, models.BookingStatusCompleted, actor)
+		} else {
+			_, err = s.checkout(booking, actor)
+		}
+		if err != nil {
+			logrus.Errorf("Failed to settle booking %s: %v", booking.PaymentReference, err)
+			continue
+		}
+		settled++
+	}
+
+	return settled, nil
+}
+
+// overtimeUntilExit closes the sessions of the booking and prices the overtime up to when its vehicle actually left,
+// which may be before the checkout was recorded.
+func (s *BookingService) overtimeUntilExit(booking *models.Booking, actor *models.BookingActor) (int, float64, error) {
+	rate := booking.Parking.OvertimeRate
+	if rate == 0 {
+		rate = booking.Slot.Fee
+	}
+
+	checkoutAt := pkg.GetCurrentTime()
+	exitedAt, err := s.SessionService.CloseBookingSessions(booking.ID, actor.Source)
+	if err != nil {
+		return 0, 0, err
+	}
+	if exitedAt != nil {
+		checkoutAt = *exitedAt
+	}
+
+	minutes, fee := CalculateOvertime(booking.EndAt, checkoutAt, booking.Parking.OvertimeGraceMinutes, rate)
+	s.recordBookingEvent(booking, models.BookingEventCheckedOut, actor, map[string]interface{}{
+		"checkout_at":      checkoutAt,
+		"overtime_minutes": minutes,
+		"overtime_fee":     fee,
+	})
+
+	return minutes, fee, nil
+}
+
+// chargeOvertime issues the overtime invoice of a booking. The charge is stored before the invoice is created so a
+// payment can always be matched to the booking, and the booking only moves to OVERTIME_UNPAID once the invoice exists.
+func (s *BookingService) chargeOvertime(booking *models.Booking, minutes int, fee float64, actor *models.BookingActor) error {
+	reference := bookingOvertimeReferencePrefix + pkg.RandomString(8)
+
+	err := s.DB.Model(&models.Booking{}).Where("id = ?", booking.ID).Updates(map[string]interface{}{
+		"overtime_minutes":   minutes,
+		"overtime_fee":       fee,
+		"overtime_reference": reference,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	paymentInvoice, err := s.PaymentProvider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{
+		ExternalID:         reference,
+		Amount:             fee,
+		Currency:           "IDR",
+		Description:        fmt.Sprintf("Parking overtime fee for %s", booking.PlateNumber),
+		PayerEmail:         booking.User.Email,
+		CustomerID:         strconv.Itoa(booking.User.ID),
+		CustomerName:       booking.User.FullName,
+		SuccessRedirectURL: fmt.Sprintf("https://parkingo.agil.zip/b/%s", booking.PaymentReference),
+		Duration:           bookingInvoiceDuration,
+		Items: []paymentgateway.InvoiceItem{
+			{
+				Name:        fmt.Sprintf("%s | %s | %s | Overtime %d minutes", booking.Parking.Name, booking.Slot.Name, booking.PlateNumber, minutes),
+				Price:       fee,
+				Quantity:    1,
+				ReferenceID: booking.Parking.Slug,
+			},
+		},
+	})
+	if err != nil {
+		logrus.Error("Payment Error:", err)
+		return err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Updates(map[string]interface{}{
+			"overtime_invoice_id": paymentInvoice.ID,
+			"overtime_link":       paymentInvoice.InvoiceURL,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = recordInvoiceIssuedTx(tx, booking, actor, "OVERTIME", reference, paymentInvoice)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.Lifecycle.TransitionTx(tx, booking, models.BookingStatusOvertimeUnpaid, actor)
+		return err
+	})
+	if err != nil {
+		s.expireInvoice(paymentInvoice.ID)
+		return err
+	}
+
+	booking.OvertimeMinutes = minutes
+	booking.OvertimeFee = fee
+	booking.OvertimeReference = reference
+	booking.OvertimeInvoiceID = paymentInvoice.ID
+	booking.OvertimeLink = paymentInvoice.InvoiceURL
+	s.Lifecycle.Notify(booking)
+
+	return nil
+}
+
+func (s *BookingService) handleOvertimePayment(event *paymentgateway.WebhookEvent, actor *models.BookingActor) (*models.Booking, error) {
+	var booking *models.Booking
+	err := s.DB.Preload("User").Preload("Parking").Preload("Slot").Where("overtime_reference = ?", event.ExternalID).First(&booking).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if event.IsPaid() {
+		err = checkPaidEvent(event, booking.OvertimeFee)
+		if err != nil {
+			return nil, err
+		}
+		err = s.completeOvertime(booking, actor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return booking, nil
+}
+
+// completeOvertime completes a booking whose overtime invoice is paid and credits the overtime fee to the parking.
+func (s *BookingService) completeOvertime(booking *models.Booking, actor *models.BookingActor) error {
+	changed := false
+	paidAt := pkg.GetCurrentTime()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		changed, err = s.Lifecycle.TransitionTx(tx, booking, models.BookingStatusCompleted, actor)
+		if err != nil || !changed {
+			return err
+		}
+
+		err = tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Update("overtime_paid_at", paidAt).Error
+		if err != nil {
+			return err
+		}
+
+		err = s.Lifecycle.Ledger.RecordPaymentTx(tx, models.LedgerEntryOvertimePayment, booking, booking.OvertimeReference, booking.OvertimeFee)
+		if err != nil {
+			return err
+		}
+
+		return s.Lifecycle.ChargeCommissionTx(tx, booking, booking.OvertimeReference, booking.OvertimeFee)
+	})
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		booking.OvertimePaidAt = &paidAt
+		s.Lifecycle.Notify(booking)
+	}
+
+	return nil
+}
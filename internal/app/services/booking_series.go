@@ -0,0 +1,509 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const bookingSeriesReferencePrefix = "PKGO-SER-"
+
+const maxBookingSeriesOccurrences = 62
+
+// ExpandBookingSeries returns the window of every occurrence of the series in loc. Weeks start on Sunday, so a
+// WEEKLY series with an interval of 2 books every other calendar week counted from the week of StartDate.
+func ExpandBookingSeries(req *models.CreateBookingSeriesRequest, loc *time.Location) ([]models.TimeInterval, error) {
+	startDate, err := time.ParseInLocation("2006-01-02", req.StartDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date %s", req.StartDate)
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", req.EndDate, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date %s", req.EndDate)
+	}
+	if endDate.Before(startDate) {
+		return nil, errors.New("end date must not be before start date")
+	}
+
+	interval := max(req.Interval, 1)
+	daysOfWeek := req.DaysOfWeek
+	if req.Frequency == models.BookingSeriesFrequencyWeekly && len(daysOfWeek) == 0 {
+		daysOfWeek = []int{int(startDate.Weekday())}
+	}
+	startMinute := clockMinutes(req.StartTime)
+	endMinute := clockMinutes(req.EndTime)
+
+	var occurrences []models.TimeInterval
+	for i, day := 0, startDate; !day.After(endDate); i, day = i+1, day.AddDate(0, 0, 1) {
+		switch req.Frequency {
+		case models.BookingSeriesFrequencyDaily:
+			if i%interval != 0 {
+				continue
+			}
+		case models.BookingSeriesFrequencyWeekly:
+			week := (i + int(startDate.Weekday())) / 7
+			if week%interval != 0 || !slices.Contains(daysOfWeek, int(day.Weekday())) {
+				continue
+			}
+		}
+
+		startAt := time.Date(day.Year(), day.Month(), day.Day(), startMinute/60, startMinute%60, 0, 0, loc)
+		endAt := time.Date(day.Year(), day.Month(), day.Day(), endMinute/60, endMinute%60, 0, 0, loc)
+		if !endAt.After(startAt) {
+			endAt = endAt.AddDate(0, 0, 1)
+		}
+
+		occurrences = append(occurrences, models.TimeInterval{StartAt: startAt, EndAt: endAt, Source: "SERIES"})
+		if len(occurrences) > maxBookingSeriesOccurrences {
+			return nil, fmt.Errorf("a series can have at most %d occurrences", maxBookingSeriesOccurrences)
+		}
+	}
+
+	if len(occurrences) == 0 {
+		return nil, errors.New("series has no occurrences in the date range")
+	}
+
+	return occurrences, nil
+}
+
+func (s *BookingService) GetBookingSeriesByID(id int) (*models.BookingSeries, error) {
+	var series *models.BookingSeries
+	err := s.DB.Preload("Parking").Preload("Bookings", func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Slot").Order("start_at ASC")
+	}).First(&series, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// CreateBookingSeries books every occurrence of a recurring booking at once. All occurrences are checked before
+// anything is written, the series is only created when every one of them can be booked.
+func (s *BookingService) CreateBookingSeries(userID int, req *models.CreateBookingSeriesRequest) (*models.BookingSeries, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	err = s.DB.First(&user, userID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var parking *models.Parking
+	err = s.DB.First(&parking, req.ParkingID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences, err := ExpandBookingSeries(req, pkg.GetCurrentTime().Location())
+	if err != nil {
+		return nil, err
+	}
+
+	var drafts []*bookingDraft
+	var problems []string
+	for _, occurrence := range occurrences {
+		label := occurrence.StartAt.Format("2006-01-02 15:04")
+
+		slotID := 0
+		if req.SlotID != nil {
+			slotID = *req.SlotID
+		} else {
+			slot, err := assignSlot(s.DB, req.ParkingID, occurrence.StartAt, occurrence.EndAt, nil, nil)
+			if errors.Is(err, ErrNoSlotAvailable) {
+				problems = append(problems, fmt.Sprintf("%s: no slot is free", label))
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			slotID = slot.ID
+		}
+
+		draft, err := s.draftBooking(userID, &models.CreateBookingRequest{
+			ParkingID:   req.ParkingID,
+			SlotID:      slotID,
+			PlateNumber: req.PlateNumber,
+			StartAt:     occurrence.StartAt,
+			EndAt:       occurrence.EndAt,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, problem := range draft.problems {
+			problems = append(problems, fmt.Sprintf("%s: %v", label, problem))
+		}
+		drafts = append(drafts, draft)
+	}
+
+	if len(problems) > 0 {
+		return nil, pkg.NewConflictError(fmt.Sprintf("series cannot be booked: %s", strings.Join(problems, "; ")))
+	}
+
+	series := &models.BookingSeries{
+		UserID:           userID,
+		ParkingID:        req.ParkingID,
+		SlotID:           req.SlotID,
+		PlateNumber:      req.PlateNumber,
+		Frequency:        req.Frequency,
+		Interval:         max(req.Interval, 1),
+		DaysOfWeek:       req.DaysOfWeek,
+		StartTime:        req.StartTime,
+		EndTime:          req.EndTime,
+		StartDate:        req.StartDate,
+		EndDate:          req.EndDate,
+		PaymentReference: bookingSeriesReferencePrefix + pkg.RandomString(8),
+		Status:           models.BookingSeriesStatusUnpaid,
+	}
+	if series.DaysOfWeek == nil {
+		series.DaysOfWeek = []int{}
+	}
+
+	bookings := make([]models.Booking, 0, len(drafts))
+	items := make([]paymentgateway.InvoiceItem, 0, len(drafts))
+	for _, draft := range drafts {
+		booking := draft.newBooking(userID)
+		series.TotalFee += booking.TotalFee
+		series.ServiceFee += booking.ServiceFee
+		bookings = append(bookings, booking)
+
+		items = append(items, paymentgateway.InvoiceItem{
+			Name:        fmt.Sprintf("%s | %s | %s | %s - %s", parking.Name, draft.slot.Name, req.PlateNumber, booking.StartAt.Format("2006-01-02 15:04"), booking.EndAt.Format("15:04")),
+			Price:       booking.TotalFee,
+			Quantity:    1,
+			ReferenceID: parking.Slug,
+		})
+	}
+
+	var paymentInvoice *paymentgateway.Invoice
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(series).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range bookings {
+			bookings[i].SeriesID = &series.ID
+		}
+		err = tx.Create(&bookings).Error
+		if err != nil {
+			if isSlotOverlapError(err) {
+				return ErrSlotAlreadyBooked
+			}
+			return err
+		}
+
+		var fees []paymentgateway.InvoiceFee
+		if series.ServiceFee > 0 {
+			fees = append(fees, paymentgateway.InvoiceFee{
+				Type:  "Service fee",
+				Value: series.ServiceFee,
+			})
+		}
+		paymentInvoice, err = s.PaymentProvider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{
+			ExternalID:         series.PaymentReference,
+			Amount:             series.TotalFee + series.ServiceFee,
+			Currency:           "IDR",
+			Description:        fmt.Sprintf("Recurring parking fee for %s", req.PlateNumber),
+			PayerEmail:         user.Email,
+			CustomerID:         strconv.Itoa(user.ID),
+			CustomerName:       user.FullName,
+			SuccessRedirectURL: fmt.Sprintf("https://parkingo.agil.zip/b/%s", series.PaymentReference),
+			Duration:           bookingInvoiceDuration,
+			Items:              items,
+			Fees:               fees,
+		})
+		if err != nil {
+			logrus.Error("Payment Error:", err)
+			return err
+		}
+
+		series.PaymentInvoiceID = paymentInvoice.ID
+		series.PaymentLink = paymentInvoice.InvoiceURL
+		series.PaymentExpiredAt = paymentInvoice.ExpiryDate
+		payment := map[string]interface{}{
+			"payment_invoice_id": series.PaymentInvoiceID,
+			"payment_link":       series.PaymentLink,
+			"payment_expired_at": series.PaymentExpiredAt,
+		}
+		err = tx.Model(series).Updates(payment).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Booking{}).Where("series_id = ?", series.ID).Updates(payment).Error
+		if err != nil {
+			return err
+		}
+
+		actor := models.NewBookingActor(user, models.BookingEventSourceAPI)
+		for i := range bookings {
+			err = recordBookingCreatedTx(tx, &bookings[i], actor)
+			if err != nil {
+				return err
+			}
+			err = recordInvoiceIssuedTx(tx, &bookings[i], actor, "SERIES", series.PaymentReference, paymentInvoice)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if paymentInvoice != nil {
+			s.expireInvoice(paymentInvoice.ID)
+		}
+		return nil, err
+	}
+
+	go s.MailService.SendMail(user.Email, fmt.Sprintf("Booking Confirmation %s", series.PaymentReference), fmt.Sprintf("Your recurring booking of %d occurrences is confirmed. Please complete the payment: %s", len(bookings), series.PaymentLink))
+
+	return s.GetBookingSeriesByID(series.ID)
+}
+
+// CancelBookingSeries cancels every occurrence that has not started yet. An unpaid series only has its invoice
+// expired, the occurrences of a paid series are refunded one by one according to the cancellation policy.
+func (s *BookingService) CancelBookingSeries(userID int, id int, req *models.CancelBookingRequest) (*models.BookingSeries, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := s.GetBookingSeriesByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if series.UserID != userID {
+		return nil, pkg.NewForbiddenError("You are not allowed to cancel this booking series")
+	}
+
+	switch series.Status {
+	case models.BookingSeriesStatusUnpaid:
+		s.expireInvoice(series.PaymentInvoiceID)
+
+		_, err = s.transitionSeries(series, models.BookingSeriesStatusCanceled, models.BookingStatusCanceled, bookingOwnerActor(userID))
+		if err != nil {
+			return nil, err
+		}
+	case models.BookingSeriesStatusPaid:
+		err = s.cancelPaidSeries(series, req.Reason, bookingOwnerActor(userID))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, pkg.NewConflictError("booking series can no longer be canceled")
+	}
+
+	return s.GetBookingSeriesByID(series.ID)
+}
+
+// cancelPaidSeries cancels a paid series along with its occurrences that have not started, refunding each of them
+// per the cancellation policy. Either every occurrence is canceled or none is.
+func (s *BookingService) cancelPaidSeries(series *models.BookingSeries, reason string, actor *models.BookingActor) error {
+	now := pkg.GetCurrentTime()
+
+	var bookings []*models.Booking
+	err := s.DB.Preload("User").Preload("Parking").Preload("Extensions").
+		Where("series_id = ? AND status = ? AND start_at > ?", series.ID, models.BookingStatusPaid, now).
+		Find(&bookings).Error
+	if err != nil {
+		return err
+	}
+
+	refunds := make([][]*models.Refund, len(bookings))
+	for i, booking := range bookings {
+		refunds[i] = s.paidInvoices(booking)
+	}
+
+	var created []*models.Refund
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.BookingSeries{}).
+			Where("id = ? AND status = ?", series.ID, models.BookingSeriesStatusPaid).
+			Updates(map[string]interface{}{
+				"status":     models.BookingSeriesStatusCanceled,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return pkg.NewConflictError("booking series changed, please try again")
+		}
+
+		for i, booking := range bookings {
+			_, err := s.Lifecycle.TransitionTx(tx, booking, models.BookingStatusCanceled, actor)
+			if err != nil {
+				return err
+			}
+
+			bookingRefunds, err := s.recordRefundsTx(tx, booking, refunds[i], reason, now, func(paid float64) (string, float64) {
+				return CalculateRefund(booking.StartAt, now, booking.Parking.CancelFullRefundHours, booking.Parking.CancelPartialRefundPercent, paid)
+			})
+			if err != nil {
+				return err
+			}
+			created = append(created, bookingRefunds...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, refund := range created {
+		s.issueRefund(refund)
+	}
+
+	for _, booking := range bookings {
+		s.Lifecycle.Notify(booking)
+	}
+
+	return nil
+}
+
+func (s *BookingService) handleSeriesPayment(event *paymentgateway.WebhookEvent, actor *models.BookingActor) (*models.Booking, error) {
+	var series models.BookingSeries
+	err := s.DB.Where("payment_reference = ?", event.ExternalID).First(&series).Error
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case event.IsPaid():
+		err = checkPaidEvent(event, series.TotalFee+series.ServiceFee)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.transitionSeries(&series, models.BookingSeriesStatusPaid, models.BookingStatusPaid, actor)
+	case event.Status == paymentgateway.InvoiceStatusExpired:
+		_, err = s.transitionSeries(&series, models.BookingSeriesStatusExpired, models.BookingStatusExpired, actor)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// transitionSeries moves an unpaid series and its unpaid occurrences together. It reports false when the series
+// was no longer unpaid.
+func (s *BookingService) transitionSeries(series *models.BookingSeries, seriesStatus string, bookingStatus string, actor *models.BookingActor) (bool, error) {
+	changed := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.BookingSeries{}).
+			Where("id = ? AND status = ?", series.ID, models.BookingSeriesStatusUnpaid).
+			Updates(map[string]interface{}{
+				"status":     seriesStatus,
+				"updated_at": pkg.GetCurrentTime(),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		var bookings []*models.Booking
+		err := tx.Where("series_id = ? AND status = ?", series.ID, models.BookingStatusUnpaid).Find(&bookings).Error
+		if err != nil {
+			return err
+		}
+
+		for _, booking := range bookings {
+			_, err = s.Lifecycle.TransitionTx(tx, booking, bookingStatus, actor)
+			if err != nil {
+				return err
+			}
+		}
+
+		changed = true
+		return nil
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+	series.Status = seriesStatus
+
+	var user models.User
+	err = s.DB.First(&user, series.UserID).Error
+	if err != nil {
+		logrus.Errorf("Failed to notify user of series %s: %v", series.PaymentReference, err)
+		return true, nil
+	}
+
+	// One mail for the whole series instead of one per occurrence
+	switch seriesStatus {
+	case models.BookingSeriesStatusPaid:
+		go s.MailService.SendMail(user.Email, fmt.Sprintf("Booking Paid %s", series.PaymentReference), fmt.Sprintf("Your recurring booking is paid. Booking invoice and detail: https://parkingo.agil.zip/b/%s", series.PaymentReference))
+	case models.BookingSeriesStatusExpired:
+		go s.MailService.SendMail(user.Email, fmt.Sprintf("Booking Expired %s", series.PaymentReference), fmt.Sprintf("Your recurring booking has expired because it was not paid in time. Booking invoice and detail: https://parkingo.agil.zip/b/%s", series.PaymentReference))
+	}
+
+	return true, nil
+}
+
+// expireUnpaidSeries expires the series whose invoice was not paid in time, see ExpireUnpaidBookings.
+func (s *BookingService) expireUnpaidSeries(now time.Time) (int, error) {
+	var seriesList []*models.BookingSeries
+	err := s.DB.Where("status = ? AND payment_expired_at < ?", models.BookingSeriesStatusUnpaid, now).Find(&seriesList).Error
+	if err != nil {
+		return 0, err
+	}
+
+	actor := models.NewSystemBookingActor(models.BookingEventSourceJob)
+	reclaimed := 0
+	for _, series := range seriesList {
+		if series.PaymentInvoiceID != "" {
+			paymentInvoice, err := s.PaymentProvider.GetInvoice(series.PaymentInvoiceID)
+			if err != nil {
+				logrus.Errorf("Failed to get invoice for booking series %s: %v", series.PaymentReference, err)
+				continue
+			}
+
+			if paymentInvoice.IsPaid() {
+				if !paidInFull(paymentInvoice, series.TotalFee+series.ServiceFee) {
+					logrus.Errorf("Booking series %s invoice paid %.2f of %.2f, left for review", series.PaymentReference, paymentInvoice.PaidAmount, series.TotalFee+series.ServiceFee)
+					continue
+				}
+
+				logrus.Infof("Booking series %s invoice is already paid, updating status to PAID", series.PaymentReference)
+				_, err = s.transitionSeries(series, models.BookingSeriesStatusPaid, models.BookingStatusPaid, actor)
+				if err != nil {
+					logrus.Errorf("Failed to update booking series %s: %v", series.PaymentReference, err)
+				}
+				continue
+			}
+
+			if paymentInvoice.Status == paymentgateway.InvoiceStatusPending {
+				_, err = s.PaymentProvider.ExpireInvoice(series.PaymentInvoiceID)
+				if err != nil {
+					logrus.Errorf("Failed to expire invoice for booking series %s: %v", series.PaymentReference, err)
+					continue
+				}
+			}
+		}
+
+		expired, err := s.transitionSeries(series, models.BookingSeriesStatusExpired, models.BookingStatusExpired, actor)
+		if err != nil {
+			logrus.Errorf("Failed to expire booking series %s: %v", series.PaymentReference, err)
+			continue
+		}
+		if expired {
+			reclaimed++
+		}
+	}
+
+	return reclaimed, nil
+}
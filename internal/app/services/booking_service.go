@@ -1,34 +1,52 @@
 package services
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/agilistikmal/parkingo-core/internal/app/models"
 	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"github.com/xendit/xendit-go/v6"
-	"github.com/xendit/xendit-go/v6/invoice"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSlotAlreadyBooked = pkg.NewConflictError("slot is already booked")
+
+const (
+	// bookingInvoiceDuration is how long a booking invoice can be paid
+	bookingInvoiceDuration = 600 * time.Second
+	// bookingArrivalTolerance is how early before its start a booking is recognized at its slot
+	bookingArrivalTolerance = 15 * time.Minute
 )
 
 type BookingService struct {
-	DB           *gorm.DB
-	Validate     *validator.Validate
-	XenditClient *xendit.APIClient
-	MailService  *MailService
+	DB              *gorm.DB
+	Validate        *validator.Validate
+	PaymentProvider paymentgateway.PaymentProvider
+	MailService     *MailService
+	Lifecycle       *BookingLifecycle
+	PricingService  *PricingService
+	SessionService  *ParkingSessionService
 }
 
-func NewBookingService(db *gorm.DB, validate *validator.Validate, xenditClient *xendit.APIClient, mailService *MailService) *BookingService {
+func NewBookingService(db *gorm.DB, validate *validator.Validate, paymentProvider paymentgateway.PaymentProvider, mailService *MailService, lifecycle *BookingLifecycle, pricingService *PricingService, sessionService *ParkingSessionService) *BookingService {
 	return &BookingService{
-		DB:           db,
-		Validate:     validate,
-		XenditClient: xenditClient,
-		MailService:  mailService,
+		DB:              db,
+		Validate:        validate,
+		PaymentProvider: paymentProvider,
+		MailService:     mailService,
+		Lifecycle:       lifecycle,
+		PricingService:  pricingService,
+		SessionService:  sessionService,
 	}
 }
 
@@ -41,10 +59,10 @@ func (s *BookingService) GetBookings(filter *models.BookingFilter) ([]*models.Bo
 			query = query.Where("user_id = ?", filter.UserID)
 		}
 		if filter.ParkingID != 0 {
-			query = query.Or("parking_id = ?", filter.ParkingID)
+			query = query.Where("parking_id = ?", filter.ParkingID)
 		}
 		if filter.Status != "" {
-			query = query.Or("status = ?", filter.Status)
+			query = query.Where("status = ?", filter.Status)
 		}
 		if filter.SortBy != "" {
 			query = query.Order(fmt.Sprintf("%s %s", filter.SortBy, filter.SortOrder))
@@ -67,7 +85,7 @@ func (s *BookingService) GetBookings(filter *models.BookingFilter) ([]*models.Bo
 
 func (s *BookingService) GetBookingByID(id int) (*models.Booking, error) {
 	var booking *models.Booking
-	err := s.DB.Preload("User").Preload("Parking").Preload("Slot").First(&booking, id).Error
+	err := s.DB.Preload("User").Preload("Parking").Preload("Slot").Preload("Extensions").Preload("Refunds").First(&booking, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +95,7 @@ func (s *BookingService) GetBookingByID(id int) (*models.Booking, error) {
 
 func (s *BookingService) GetBookingByReference(reference string) (*models.Booking, error) {
 	var booking *models.Booking
-	err := s.DB.Preload("User").Preload("Parking").Preload("Slot").Where("payment_reference = ?", reference).First(&booking).Error
+	err := s.DB.Preload("User").Preload("Parking").Preload("Slot").Preload("Extensions").Preload("Refunds").Where("payment_reference = ?", reference).First(&booking).Error
 	if err != nil {
 		return nil, err
 	}
@@ -85,124 +103,414 @@ func (s *BookingService) GetBookingByReference(reference string) (*models.Bookin
 	return booking, nil
 }
 
-func (s *BookingService) CreateBooking(userID int, req *models.CreateBookingRequest) (*models.Booking, error) {
-	err := s.Validate.Struct(req)
+type bookingDraft struct {
+	slot           *models.ParkingSlot
+	price          *models.PriceBreakdown
+	commissionPlan *models.CommissionPlan
+	voucher        *models.Voucher
+	hold           *models.SlotHold
+	quote          *models.BookingQuote
+	problems       []error
+}
+
+// draftBooking runs every check and price calculation CreateBooking relies on without writing anything,
+// so quotes and real charges always agree. Business rule violations are collected as problems.
+// Without a slot in the request the best free slot of the parking is assigned.
+func (s *BookingService) draftBooking(userID int, req *models.CreateBookingRequest, excludeSlotIDs []int) (*bookingDraft, error) {
+	var hold *models.SlotHold
+	if req.HoldToken != "" {
+		err := s.DB.Where("token = ?", req.HoldToken).First(&hold).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slotID := req.SlotID
+	if slotID == 0 && hold != nil {
+		slotID = hold.SlotID
+	}
+	if slotID == 0 {
+		slot, err := assignSlot(s.DB, req.ParkingID, req.StartAt, req.EndAt, req.SlotAttributes, excludeSlotIDs)
+		if err != nil {
+			return nil, err
+		}
+		slotID = slot.ID
+	}
+
+	var parkingSlot *models.ParkingSlot
+	err := s.DB.Preload("Parking").First(&parkingSlot, slotID).Error
 	if err != nil {
 		return nil, err
 	}
 
+	draft := &bookingDraft{slot: parkingSlot}
+
 	now := pkg.GetCurrentTime()
 	if req.StartAt.Before(now) {
-		return nil, fmt.Errorf("start time must be in the future")
+		draft.problems = append(draft.problems, errors.New("start time must be in the future"))
 	}
 
-	var user *models.User
-	err = s.DB.First(&user, userID).Error
+	// A valid hold of the user does not keep the slot from being booked through it
+	conflictFilter := slotConflictFilter{SlotID: slotID}
+	if hold != nil {
+		holdProblems := checkSlotHold(hold, userID, slotID, req.StartAt, req.EndAt, now)
+		if len(holdProblems) == 0 {
+			draft.hold = hold
+			conflictFilter.ExcludeHoldID = hold.ID
+		}
+		draft.problems = append(draft.problems, holdProblems...)
+	}
+
+	if parkingSlot.ParkingID != req.ParkingID {
+		draft.problems = append(draft.problems, fmt.Errorf("slot %s does not belong to parking %d", parkingSlot.Name, req.ParkingID))
+	}
+
+	minHours := 3
+	if viper.GetString("environment") == "dev" {
+		minHours = 0
+	}
+	totalHours := int(req.EndAt.Sub(req.StartAt).Hours())
+	if totalHours < minHours {
+		draft.problems = append(draft.problems, fmt.Errorf("minimum booking time is %d hours", minHours))
+	}
+
+	// Check if the parking slot is available, the bookings_slot_no_overlap constraint
+	// is the final guard against concurrent requests
+	conflicts, err := findSlotConflicts(s.DB, conflictFilter, req.StartAt, req.EndAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conflicts[slotID]) > 0 {
+		draft.problems = append(draft.problems, ErrSlotAlreadyBooked)
+	}
+
+	draft.price, err = s.PricingService.QuoteSlotPrice(parkingSlot, req.StartAt, req.EndAt)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if the parking slot is available
-	var similarBookings []models.Booking
-	err = s.DB.Where("slot_id = ? AND (start_at BETWEEN ? AND ? OR end_at BETWEEN ? AND ?) AND status NOT IN ('CANCELED', 'EXPIRED', 'COMPLETED')", req.SlotID, req.StartAt, req.EndAt, req.StartAt, req.EndAt).Find(&similarBookings).Error
+	draft.commissionPlan, err = resolveCommissionPlan(s.DB, parkingSlot.Parking)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(similarBookings) > 0 {
-		return nil, fmt.Errorf("slot is already booked")
+	serviceFee := 0.0
+	if draft.commissionPlan != nil {
+		serviceFee = draft.commissionPlan.ServiceFee
+	}
+
+	voucherDiscount := 0.0
+	if req.VoucherCode != "" {
+		voucher, err := findVoucher(s.DB, req.VoucherCode)
+		if err == nil {
+			err = checkVoucher(s.DB, voucher, userID, req.ParkingID, draft.price.Total, now)
+		}
+		if err != nil {
+			draft.problems = append(draft.problems, err)
+		} else {
+			draft.voucher = voucher
+			voucherDiscount = CalculateVoucherDiscount(voucher, draft.price.Total)
+		}
+	}
+	totalFee := draft.price.Total - voucherDiscount
+
+	problems := make([]string, 0, len(draft.problems))
+	for _, problem := range draft.problems {
+		problems = append(problems, problem.Error())
+	}
+
+	draft.quote = &models.BookingQuote{
+		ParkingID:       req.ParkingID,
+		SlotID:          parkingSlot.ID,
+		SlotName:        parkingSlot.Name,
+		PlateNumber:     req.PlateNumber,
+		StartAt:         req.StartAt,
+		EndAt:           req.EndAt,
+		TotalHours:      draft.price.TotalHours,
+		Items:           draft.price.Items,
+		Adjustments:     draft.price.Adjustments,
+		Subtotal:        draft.price.Subtotal,
+		Discount:        draft.price.Discount,
+		VoucherCode:     strings.ToUpper(req.VoucherCode),
+		VoucherDiscount: voucherDiscount,
+		TotalFee:        totalFee,
+		ServiceFee:      serviceFee,
+		AmountDue:       totalFee + serviceFee,
+		IsValid:         len(problems) == 0,
+		Problems:        problems,
+	}
+
+	return draft, nil
+}
+
+// newBooking returns the unpaid booking charged as quoted by the draft.
+func (d *bookingDraft) newBooking(userID int) models.Booking {
+	booking := models.Booking{
+		UserID:           userID,
+		ParkingID:        d.quote.ParkingID,
+		SlotID:           d.quote.SlotID,
+		PlateNumber:      d.quote.PlateNumber,
+		StartAt:          d.quote.StartAt,
+		EndAt:            d.quote.EndAt,
+		PaymentReference: "PKGO-" + pkg.RandomString(8),
+		Status:           models.BookingStatusUnpaid,
+		TotalHours:       d.price.TotalHours,
+		TotalFee:         d.quote.TotalFee,
+		ServiceFee:       d.quote.ServiceFee,
+		CommissionFee:    CalculateCommission(d.commissionPlan, d.quote.TotalFee, true),
+		VoucherDiscount:  d.quote.VoucherDiscount,
 	}
+	if d.commissionPlan != nil {
+		booking.CommissionPlanID = &d.commissionPlan.ID
+	}
+
+	return booking
+}
 
-	// Check if the parking slot is available
-	var parkingSlot models.ParkingSlot
-	err = s.DB.Preload("Parking").First(&parkingSlot, req.SlotID).Error
+func (s *BookingService) QuoteBooking(userID int, req *models.CreateBookingRequest) (*models.BookingQuote, error) {
+	err := s.Validate.Struct(req)
 	if err != nil {
 		return nil, err
 	}
 
-	minHours := 3
-	if viper.GetString("environment") == "dev" {
-		minHours = 0
+	draft, err := s.draftBooking(userID, req, nil)
+	if err != nil {
+		return nil, err
 	}
-	totalHours := int(req.EndAt.Sub(req.StartAt).Hours())
-	if totalHours < minHours {
-		return nil, fmt.Errorf("minimum booking time is %d hours", minHours)
-	}
-
-	totalFee := float64(totalHours) * parkingSlot.Fee
-
-	customer := *invoice.NewCustomerObject()
-	customer.SetEmail(user.Email)
-	customer.SetGivenNames(user.FullName)
-	customer.SetId(string(user.ID))
-
-	items := []invoice.InvoiceItem{
-		{
-			Name:        fmt.Sprintf("%s | %s | %s", parkingSlot.Parking.Name, parkingSlot.Name, req.PlateNumber),
-			Price:       float32(parkingSlot.Fee),
-			Quantity:    float32(totalHours),
-			ReferenceId: &parkingSlot.Parking.Slug,
-		},
-	}
-
-	paymentReference := "PKGO-" + pkg.RandomString(8)
-	invoiceRequest := *invoice.NewCreateInvoiceRequest(paymentReference, totalFee)
-	invoiceRequest.SetPayerEmail(user.Email)
-	invoiceRequest.SetDescription(fmt.Sprintf("Parking fee for %s", req.PlateNumber))
-	invoiceRequest.SetCurrency("IDR")
-	invoiceRequest.SetSuccessRedirectUrl(fmt.Sprintf("https://parkingo.agil.zip/b/%s", paymentReference))
-	invoiceRequest.SetInvoiceDuration("600")
-	invoiceRequest.SetCustomer(customer)
-	invoiceRequest.SetItems(items)
-
-	paymentInvoice, _, err := s.XenditClient.InvoiceApi.CreateInvoice(context.Background()).
-		CreateInvoiceRequest(invoiceRequest).
-		Execute()
-	if paymentInvoice.Id == nil {
-		logrus.Error("Payment Error:", err)
+
+	return draft.quote, nil
+}
+
+func (s *BookingService) CreateBooking(userID int, req *models.CreateBookingRequest) (*models.Booking, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
 		return nil, err
 	}
 
-	booking := models.Booking{
-		UserID:           userID,
-		ParkingID:        req.ParkingID,
-		SlotID:           req.SlotID,
-		PlateNumber:      req.PlateNumber,
-		StartAt:          req.StartAt,
-		EndAt:            req.EndAt,
-		PaymentReference: paymentInvoice.ExternalId,
-		PaymentLink:      paymentInvoice.InvoiceUrl,
-		PaymentExpiredAt: paymentInvoice.ExpiryDate,
-		Status:           "UNPAID",
-		TotalHours:       totalHours,
-		TotalFee:         totalFee,
+	var user *models.User
+	err = s.DB.First(&user, userID).Error
+	if err != nil {
+		return nil, err
 	}
 
+	// An assigned slot can be taken by a concurrent booking before this one is written, the next slot of the
+	// ranking is assigned then. Each attempt passes over one more slot, so the ranking runs out eventually.
+	var taken []int
+	for {
+		booking, slotID, err := s.createBooking(user, req, taken)
+		if (errors.Is(err, ErrSlotAlreadyBooked) || errors.Is(err, ErrSlotHeld)) && req.SlotID == 0 && req.HoldToken == "" {
+			taken = append(taken, slotID)
+			continue
+		}
+		return booking, err
+	}
+}
+
+// abandonBooking expires a booking whose invoice could not be attached, giving back its slot and voucher.
+func (s *BookingService) abandonBooking(booking *models.Booking, actor *models.BookingActor) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := s.Lifecycle.TransitionTx(tx, booking, models.BookingStatusExpired, actor)
+		return err
+	})
+	if err != nil {
+		logrus.Errorf("Failed to expire booking %s without invoice: %v", booking.PaymentReference, err)
+	}
+}
+
+// createBooking books the slot of req, or the best free one not in exclude. It also returns the slot it tried.
+func (s *BookingService) createBooking(user *models.User, req *models.CreateBookingRequest, exclude []int) (*models.Booking, int, error) {
+	userID := user.ID
+	draft, err := s.draftBooking(userID, req, exclude)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(draft.problems) > 0 {
+		return nil, draft.slot.ID, draft.problems[0]
+	}
+
+	parkingSlot := draft.slot
+	price := draft.price
+	totalFee := draft.quote.TotalFee
+
+	booking := draft.newBooking(userID)
+	if draft.voucher != nil {
+		booking.VoucherID = &draft.voucher.ID
+	}
+
+	actor := models.NewBookingActor(user, models.BookingEventSourceAPI)
+	// Reclaimed by ExpireUnpaidBookings should the invoice never be attached
+	booking.PaymentExpiredAt = pkg.GetCurrentTime().Add(bookingInvoiceDuration)
+
+	// The booking is committed before the invoice is created, the slot and voucher locks are not held during the
+	// call to the payment gateway
 	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the voucher so concurrent bookings cannot both take its last use
+		if draft.voucher != nil {
+			var voucher models.Voucher
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&voucher, draft.voucher.ID).Error
+			if err != nil {
+				return err
+			}
+			err = checkVoucher(tx, &voucher, userID, req.ParkingID, price.Total, pkg.GetCurrentTime())
+			if err != nil {
+				return err
+			}
+		}
+
+		err := lockHeldSlot(tx, &booking, draft.hold)
+		if err != nil {
+			return err
+		}
+
 		err = tx.Create(&booking).Error
 		if err != nil {
+			if isSlotOverlapError(err) {
+				return ErrSlotAlreadyBooked
+			}
 			return err
 		}
 
-		parkingSlot.Status = "BOOKED"
-		err = tx.Save(&parkingSlot).Error
+		if draft.hold != nil {
+			err = consumeSlotHold(tx, draft.hold, booking.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if draft.voucher != nil {
+			err = tx.Create(&models.VoucherRedemption{
+				VoucherID: draft.voucher.ID,
+				UserID:    userID,
+				BookingID: booking.ID,
+				Discount:  booking.VoucherDiscount,
+				Status:    models.VoucherRedemptionStatusActive,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err = recordBookingCreatedTx(tx, &booking, actor)
 		if err != nil {
 			return err
 		}
 
-		// Send email to user
-		go s.MailService.SendMail(user.Email, fmt.Sprintf("Booking Confirmation %s", booking.PaymentReference), fmt.Sprintf("Your booking is confirmed. Booking invoice and detail: https://parkingo.agil.zip/b/%s", booking.PaymentReference))
+		return updateBookingSlotTx(tx, &booking, "", booking.Status)
+	})
+	if err != nil {
+		return nil, parkingSlot.ID, err
+	}
 
-		return nil
+	items, fees := invoiceLines(fmt.Sprintf("%s | %s | %s", parkingSlot.Parking.Name, parkingSlot.Name, req.PlateNumber), parkingSlot.Parking.Slug, price)
+	if draft.voucher != nil {
+		fees = append(fees, paymentgateway.InvoiceFee{
+			Type:  fmt.Sprintf("Voucher %s", draft.voucher.Code),
+			Value: -booking.VoucherDiscount,
+		})
+	}
+	if booking.ServiceFee > 0 {
+		fees = append(fees, paymentgateway.InvoiceFee{
+			Type:  "Service fee",
+			Value: booking.ServiceFee,
+		})
+	}
+	paymentInvoice, err := s.PaymentProvider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{
+		ExternalID:         booking.PaymentReference,
+		Amount:             totalFee + booking.ServiceFee,
+		Currency:           "IDR",
+		Description:        fmt.Sprintf("Parking fee for %s", req.PlateNumber),
+		PayerEmail:         user.Email,
+		CustomerID:         strconv.Itoa(user.ID),
+		CustomerName:       user.FullName,
+		SuccessRedirectURL: fmt.Sprintf("https://parkingo.agil.zip/b/%s", booking.PaymentReference),
+		Duration:           bookingInvoiceDuration,
+		Items:              items,
+		Fees:               fees,
+	})
+	if err != nil {
+		logrus.Error("Payment Error:", err)
+		s.abandonBooking(&booking, actor)
+		return nil, parkingSlot.ID, err
+	}
+
+	booking.PaymentInvoiceID = paymentInvoice.ID
+	booking.PaymentLink = paymentInvoice.InvoiceURL
+	booking.PaymentExpiredAt = paymentInvoice.ExpiryDate
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&booking).Updates(map[string]interface{}{
+			"payment_invoice_id": booking.PaymentInvoiceID,
+			"payment_link":       booking.PaymentLink,
+			"payment_expired_at": booking.PaymentExpiredAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return recordInvoiceIssuedTx(tx, &booking, actor, "BOOKING", booking.PaymentReference, paymentInvoice)
 	})
+	if err != nil {
+		s.expireInvoice(paymentInvoice.ID)
+		s.abandonBooking(&booking, actor)
+		return nil, parkingSlot.ID, err
+	}
+
+	booking.Slot = parkingSlot
+
+	// Send email to user
+	go s.MailService.SendMail(user.Email, fmt.Sprintf("Booking Confirmation %s", booking.PaymentReference), fmt.Sprintf("Your booking is confirmed. Booking invoice and detail: https://parkingo.agil.zip/b/%s", booking.PaymentReference))
+
+	return &booking, parkingSlot.ID, nil
+}
+
+// UpdateBooking changes the plate number of a booking for its user. The plate is fixed once the vehicle arrived.
+func (s *BookingService) UpdateBooking(user *models.User, id int, req *models.UpdateBookingRequest) (*models.Booking, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	booking, err := s.GetBookingByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	return &booking, nil
+	if booking.UserID != user.ID {
+		return nil, pkg.NewForbiddenError("You are not allowed to update this booking")
+	}
+
+	if !slices.Contains(models.ActiveBookingStatuses, booking.Status) || booking.ArrivedAt != nil {
+		return nil, pkg.NewConflictError("plate number can no longer be changed")
+	}
+
+	if booking.PlateNumber == req.PlateNumber {
+		return booking, nil
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Updates(map[string]interface{}{
+			"plate_number": req.PlateNumber,
+			"updated_at":   pkg.GetCurrentTime(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return recordBookingEventTx(tx, booking, models.BookingEventUpdated, models.NewBookingActor(user, models.BookingEventSourceAPI), map[string]interface{}{
+			"plate_number": req.PlateNumber,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	booking.PlateNumber = req.PlateNumber
+
+	return booking, nil
 }
 
-func (s *BookingService) UpdateBooking(id int, req *models.UpdateBookingRequest) (*models.Booking, error) {
+// AdminUpdateBooking overrides the fields of a booking, its status still moves through the lifecycle.
+func (s *BookingService) AdminUpdateBooking(user *models.User, id int, req *models.AdminUpdateBookingRequest) (*models.Booking, error) {
 	err := s.Validate.Struct(req)
 	if err != nil {
 		return nil, err
@@ -213,77 +521,178 @@ func (s *BookingService) UpdateBooking(id int, req *models.UpdateBookingRequest)
 		return nil, err
 	}
 
-	parkingSlot := booking.Slot
-	parking := booking.Parking
+	actor := models.NewBookingActor(user, models.BookingEventSourceAPI)
+	updates := map[string]interface{}{}
+
 	if req.PlateNumber != "" {
 		booking.PlateNumber = req.PlateNumber
+		updates["plate_number"] = req.PlateNumber
 	}
 
 	if !req.StartAt.IsZero() {
 		booking.StartAt = req.StartAt
+		updates["start_at"] = req.StartAt
 	}
 
 	if !req.EndAt.IsZero() {
 		booking.EndAt = req.EndAt
+		updates["end_at"] = req.EndAt
 	}
 
 	if req.TotalHours != 0 {
 		booking.TotalHours = req.TotalHours
+		updates["total_hours"] = req.TotalHours
 	}
 
 	if req.TotalFee != 0 {
 		booking.TotalFee = req.TotalFee
+		updates["total_fee"] = req.TotalFee
 	}
 
-	if req.Status != "" {
-		booking.Status = req.Status
-
-		if req.Status == "CANCELED" {
-			parkingSlot.Status = "AVAILABLE"
-		}
-		if req.Status == "EXPIRED" {
-			parkingSlot.Status = "AVAILABLE"
-		}
-		if req.Status == "COMPLETED" {
-			parkingSlot.Status = "AVAILABLE"
+	changed := false
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Omit(clause.Associations, "status").Save(&booking).Error
+		if err != nil {
+			return err
 		}
-		if req.Status == "UNPAID" {
-			parkingSlot.Status = "BOOKED"
+
+		if len(updates) > 0 {
+			err = recordBookingEventTx(tx, booking, models.BookingEventUpdated, actor, updates)
+			if err != nil {
+				return err
+			}
 		}
-		if req.Status == "PAID" {
-			parkingSlot.Status = "OCCUPIED"
+
+		if req.Status != "" {
+			changed, err = s.Lifecycle.TransitionTx(tx, booking, req.Status, actor)
+			if err != nil {
+				return err
+			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	err = s.DB.Transaction(func(tx *gorm.DB) error {
-		err = tx.Save(&booking).Error
+	if changed {
+		s.Lifecycle.Notify(booking)
+	}
+
+	return booking, nil
+}
+
+// ProcessPaymentEvent applies a verified payment gateway event to the booking, extension or overtime it was issued for.
+// The source is how the event reached us, a callback or a reconciliation.
+func (s *BookingService) ProcessPaymentEvent(event *paymentgateway.WebhookEvent, source string) (*models.Booking, error) {
+	actor := models.NewSystemBookingActor(source)
+	if strings.HasPrefix(event.ExternalID, bookingExtensionReferencePrefix) {
+		return s.handleExtensionPayment(event, actor)
+	}
+	if strings.HasPrefix(event.ExternalID, bookingOvertimeReferencePrefix) {
+		return s.handleOvertimePayment(event, actor)
+	}
+	if strings.HasPrefix(event.ExternalID, bookingSeriesReferencePrefix) {
+		return s.handleSeriesPayment(event, actor)
+	}
+
+	booking, err := s.GetBookingByReference(event.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	var status string
+	switch {
+	case event.IsPaid():
+		err = checkPaidEvent(event, booking.TotalFee+booking.ServiceFee)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		status = models.BookingStatusPaid
+	case event.Status == paymentgateway.InvoiceStatusExpired:
+		status = models.BookingStatusExpired
+	default:
+		return booking, nil
+	}
 
-		if booking.Status == "PAID" {
-			parking.TotalEarnings += booking.TotalFee
-			parking.TotalBookings++
-			parking.AvailableEarnings += booking.TotalFee
+	err = s.Lifecycle.Transition(booking, status, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	return booking, nil
+}
+
+func (s *BookingService) ExpireUnpaidBookings() (int, error) {
+	now := pkg.GetCurrentTime()
+
+	var bookings []*models.Booking
+	err := s.DB.Preload("User").Preload("Slot").
+		Where("status = ? AND series_id IS NULL AND payment_expired_at IS NOT NULL AND payment_expired_at < ?", models.BookingStatusUnpaid, now).
+		Find(&bookings).Error
+	if err != nil {
+		return 0, err
+	}
+
+	actor := models.NewSystemBookingActor(models.BookingEventSourceJob)
+	reclaimed := 0
+	for _, booking := range bookings {
+		if booking.PaymentInvoiceID != "" {
+			paymentInvoice, err := s.PaymentProvider.GetInvoice(booking.PaymentInvoiceID)
+			if err != nil {
+				logrus.Errorf("Failed to get invoice for booking %s: %v", booking.PaymentReference, err)
+				continue
+			}
+
+			// The payment went through but the callback never arrived
+			if paymentInvoice.IsPaid() {
+				if !paidInFull(paymentInvoice, booking.TotalFee+booking.ServiceFee) {
+					logrus.Errorf("Booking %s invoice paid %.2f of %.2f, left for review", booking.PaymentReference, paymentInvoice.PaidAmount, booking.TotalFee+booking.ServiceFee)
+					continue
+				}
+
+				logrus.Infof("Booking %s invoice is already paid, updating status to PAID", booking.PaymentReference)
+				err = s.Lifecycle.Transition(booking, models.BookingStatusPaid, actor)
+				if err != nil {
+					logrus.Errorf("Failed to update booking %s: %v", booking.PaymentReference, err)
+				}
+				continue
+			}
+
+			if paymentInvoice.Status == paymentgateway.InvoiceStatusPending {
+				_, err = s.PaymentProvider.ExpireInvoice(booking.PaymentInvoiceID)
+				if err != nil {
+					logrus.Errorf("Failed to expire invoice for booking %s: %v", booking.PaymentReference, err)
+					continue
+				}
+			}
 		}
 
-		err = tx.Save(&parking).Error
-		if err != nil {
+		// Already handled by a payment callback or a previous run when nothing changed
+		expired := false
+		err = s.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			expired, err = s.Lifecycle.TransitionTx(tx, booking, models.BookingStatusExpired, actor)
 			return err
+		})
+		if err != nil {
+			logrus.Errorf("Failed to expire booking %s: %v", booking.PaymentReference, err)
+			continue
 		}
 
-		err = tx.Save(&parkingSlot).Error
-		if err != nil {
-			return err
+		if expired {
+			reclaimed++
+			s.Lifecycle.Notify(booking)
 		}
+	}
 
-		return nil
-	})
+	reclaimedSeries, err := s.expireUnpaidSeries(now)
 	if err != nil {
-		return nil, err
+		return reclaimed, err
 	}
 
-	return booking, nil
+	return reclaimed + reclaimedSeries, nil
 }
 
 func (s *BookingService) DeleteBooking(id int) error {
@@ -342,19 +751,61 @@ func (s *BookingService) ValidateBooking(req *models.ValidateBookingRequest) (*m
 	}
 
 	now := pkg.GetCurrentTime()
-	tolerance := 15 * time.Minute
 
-	// Get booking by slot id where now is after start_at (with tolerance 15 minutes)
+	// The vehicle on the slot left
+	if req.PlateNumber == "" {
+		err = tx.Commit().Error
+		if err != nil {
+			logrus.Error("Failed to commit transaction: ", err)
+			tx.Rollback()
+			return nil, err
+		}
+
+		validateBookingResponse := &models.ValidateBookingResponse{
+			RequestTime: &now,
+			IsValid:     true,
+			Reason:      "Slot emptied",
+		}
+		s.recordSighting(validateBookingResponse, &sessionEntry{Slot: parkingSlot})
+		return validateBookingResponse, nil
+	}
+
+	// Get the booking of the slot started last, allowing an early arrival. One that ended stays valid until it is
+	// checked out, unless the next booking of the slot already started.
 	var booking *models.Booking
-	err = tx.Where("slot_id = ? AND status = ? AND start_at <= ?", parkingSlot.ID, "PAID", now.Add(tolerance)).First(&booking).Error
+	err = tx.Preload("User").Where("slot_id = ? AND status = ? AND start_at <= ?", parkingSlot.ID, models.BookingStatusPaid, now.Add(bookingArrivalTolerance)).
+		Order("start_at DESC").First(&booking).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			err := tx.Commit().Error
+			// Subscribers park without a booking
+			subscription, similarity, err := findValidSubscription(tx, parking.ID, req.PlateNumber, now)
+			if err != nil {
+				logrus.Error("Failed to get subscription: ", err)
+				tx.Rollback()
+				return nil, err
+			}
+
+			err = tx.Commit().Error
 			if err != nil {
 				logrus.Error("Failed to commit transaction: ", err)
 				tx.Rollback()
 				return nil, err
 			}
+			if subscription != nil {
+				entry := &sessionEntry{Slot: parkingSlot, PlateNumber: req.PlateNumber, SubscriptionID: &subscription.ID}
+				validateBookingResponse := &models.ValidateBookingResponse{
+					SubscriptionID:     subscription.ID,
+					Subscription:       subscription,
+					RequestTime:        &now,
+					RequestPlateNumber: req.PlateNumber,
+					BookingPlateNumber: subscription.PlateNumber,
+					Similarity:         similarity,
+					IsValid:            true,
+					Reason:             fmt.Sprintf("Subscription %s (%.2f%%)", subscription.Plan.Name, similarity*100),
+				}
+				s.recordSighting(validateBookingResponse, entry)
+				return validateBookingResponse, nil
+			}
 			validateBookingResponse := &models.ValidateBookingResponse{
 				BookingID:          0,
 				Booking:            nil,
@@ -365,6 +816,9 @@ func (s *BookingService) ValidateBooking(req *models.ValidateBookingRequest) (*m
 				IsValid:            true,
 				Reason:             "Guest",
 			}
+
+			// Walk-ins pay for the time parked when they leave
+			s.recordSighting(validateBookingResponse, &sessionEntry{Slot: parkingSlot, PlateNumber: req.PlateNumber})
 			return validateBookingResponse, nil
 		}
 		logrus.Error("Failed to get booking: ", err)
@@ -379,17 +833,32 @@ func (s *BookingService) ValidateBooking(req *models.ValidateBookingRequest) (*m
 	isValid := similarity >= threshold
 
 	reason := ""
+	overtimeNotified := false
 	if isValid {
+		// The first sighting of the plate is the arrival, it keeps the booking from becoming a no-show
+		if booking.ArrivedAt == nil {
+			err = tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Update("arrived_at", now).Error
+			if err != nil {
+				logrus.Error("Failed to record booking arrival: ", err)
+				tx.Rollback()
+				return nil, err
+			}
+			booking.ArrivedAt = &now
+		}
+
 		// Check overtime
 		if booking.EndAt.Before(now.Add(15 * time.Minute)) {
 			reason = fmt.Sprintf("Valid (%.2f%%) - Overtime", similarity*100)
 			if !booking.IsNotifyOvertimeSent {
-				booking.IsNotifyOvertimeSent = true
-				err = s.DB.Save(&booking).Error
+				// Written through tx, which holds the lock on the booking row since the arrival update
+				err = tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Update("is_notify_overtime_sent", true).Error
 				if err != nil {
+					logrus.Error("Failed to record overtime notification: ", err)
+					tx.Rollback()
 					return nil, err
 				}
-				go s.MailService.SendMail(booking.User.Email, fmt.Sprintf("Booking Overtime %s", booking.PaymentReference), fmt.Sprintf("Your booking is overtime. You might charged extra fee for this booking. Booking invoice and detail: https://parkingo.agil.zip/b/%s", booking.PaymentReference))
+				booking.IsNotifyOvertimeSent = true
+				overtimeNotified = true
 			}
 		} else {
 			reason = fmt.Sprintf("Valid (%.2f%%)", similarity*100)
@@ -416,91 +885,189 @@ func (s *BookingService) ValidateBooking(req *models.ValidateBookingRequest) (*m
 		return nil, err
 	}
 
+	actor := models.NewSystemBookingActor(models.ParkingSessionSourceCamera)
+	s.recordBookingEvent(booking, models.BookingEventValidated, actor, map[string]interface{}{
+		"slot":         parkingSlot.Name,
+		"plate_number": req.PlateNumber,
+		"similarity":   similarity,
+		"is_valid":     isValid,
+	})
+	if overtimeNotified {
+		s.recordBookingEvent(booking, models.BookingEventOvertimeNotified, actor, map[string]interface{}{
+			"end_at": booking.EndAt,
+		})
+		go s.MailService.SendMail(booking.User.Email, fmt.Sprintf("Booking Overtime %s", booking.PaymentReference), fmt.Sprintf("Your booking is overtime. Time past your booking end will be charged at checkout. Booking invoice and detail: https://parkingo.agil.zip/b/%s", booking.PaymentReference))
+	}
+
+	// A plate that does not match the booking is not admitted, it gets no session
+	if isValid {
+		s.recordSighting(validateBookingResponse, &sessionEntry{Slot: parkingSlot, PlateNumber: req.PlateNumber, BookingID: &booking.ID})
+	}
+
 	return validateBookingResponse, nil
 }
 
-func (s *BookingService) Checkout(reference string) (*models.Booking, error) {
+// recordSighting keeps the sessions of a slot in line with what its camera sees, a plate entering or the slot
+// emptied. The vehicle is let in even if the session fails.
+func (s *BookingService) recordSighting(res *models.ValidateBookingResponse, entry *sessionEntry) {
+	entry.Source = models.ParkingSessionSourceCamera
+	if entry.PlateNumber == "" {
+		_, err := s.SessionService.ExitSlot(entry.Slot.ID, entry.Source)
+		if err != nil {
+			logrus.Error("Failed to close parking session: ", err)
+		}
+		return
+	}
+
+	session, err := s.SessionService.RecordEntry(entry)
+	if err != nil {
+		logrus.Error("Failed to record parking session: ", err)
+		return
+	}
+	res.SessionID = session.ID
+	res.Session = session
+}
+
+func (s *BookingService) Checkout(user *models.User, reference string) (*models.Booking, error) {
 	var booking *models.Booking
 	err := s.DB.Preload("Slot").Preload("Parking").Preload("User").Where("payment_reference = ?", reference).First(&booking).Error
 	if err != nil {
 		return nil, fmt.Errorf("Booking with reference %s not found", reference)
 	}
 
-	disallowedStatus := []string{"UNPAID", "CANCELED"}
-	if slices.Contains(disallowedStatus, booking.Status) {
-		return booking, fmt.Errorf("Booking with reference %s (%s) is not allowed to be checked out", booking.PaymentReference, booking.Status)
-	}
+	return s.checkout(booking, models.NewBookingActor(user, models.ParkingSessionSourceManual))
+}
 
-	if booking.Status == "EXPIRED" {
-		return booking, fmt.Errorf("Booking with reference %s is expired", booking.PaymentReference)
+func (s *BookingService) CheckoutWithPlateNumber(user *models.User, plateNumber string) (*models.Booking, error) {
+	// The booking of the plate started last is the one leaving, older ones were never checked out
+	var booking *models.Booking
+	err := s.DB.Preload("Slot").Preload("Parking").Preload("User").
+		Where("plate_number = ? AND status IN ? AND start_at <= ?", plateNumber, []string{models.BookingStatusPaid, models.BookingStatusOvertimeUnpaid}, pkg.GetCurrentTime().Add(bookingArrivalTolerance)).
+		Order("start_at DESC").First(&booking).Error
+	if err != nil {
+		return nil, fmt.Errorf("Booking with plate number %s not found", plateNumber)
 	}
 
-	if booking.Status == "COMPLETED" {
-		return booking, fmt.Errorf("Booking with reference %s is already completed", booking.PaymentReference)
+	return s.checkout(booking, models.NewBookingActor(user, models.ParkingSessionSourceGate))
+}
+
+// overlappingBookings matches active bookings whose time window intersects [startAt, endAt).
+// It mirrors the bookings_slot_no_overlap exclusion constraint.
+func overlappingBookings(startAt time.Time, endAt time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("start_at < ? AND end_at > ? AND status IN ?", endAt, startAt, models.ActiveBookingStatuses)
 	}
+}
 
-	s.DB.Transaction(func(tx *gorm.DB) error {
-		booking.Status = "COMPLETED"
-		err = tx.Save(&booking).Error
-		if err != nil {
-			return err
-		}
+type slotConflictFilter struct {
+	ParkingID        int
+	SlotID           int
+	ExcludeBookingID int
+	ExcludeHoldID    int
+}
 
-		parkingSlot := booking.Slot
-		parkingSlot.Status = "AVAILABLE"
-		err = tx.Save(&parkingSlot).Error
-		if err != nil {
-			return err
-		}
+// findSlotConflicts returns, per slot, every interval that keeps the slot busy within [startAt, endAt):
+// active bookings, booking extensions still waiting for payment and checkouts holding the slot.
+func findSlotConflicts(db *gorm.DB, filter slotConflictFilter, startAt time.Time, endAt time.Time) (map[int][]models.TimeInterval, error) {
+	bookingQuery := db.Model(&models.Booking{}).Scopes(overlappingBookings(startAt, endAt))
+	extensionQuery := db.Model(&models.BookingExtension{}).
+		Select("bookings.slot_id, booking_extensions.previous_end_at, booking_extensions.new_end_at").
+		Joins("JOIN bookings ON bookings.id = booking_extensions.booking_id").
+		Where("booking_extensions.status = ? AND booking_extensions.payment_expired_at > ?", models.BookingExtensionStatusUnpaid, pkg.GetCurrentTime()).
+		Where("booking_extensions.previous_end_at < ? AND booking_extensions.new_end_at > ?", endAt, startAt)
+
+	holdQuery := db.Model(&models.SlotHold{}).Scopes(activeSlotHolds(startAt, endAt))
+
+	if filter.ParkingID != 0 {
+		bookingQuery = bookingQuery.Where("parking_id = ?", filter.ParkingID)
+		extensionQuery = extensionQuery.Where("bookings.parking_id = ?", filter.ParkingID)
+		holdQuery = holdQuery.Where("parking_id = ?", filter.ParkingID)
+	}
+	if filter.SlotID != 0 {
+		bookingQuery = bookingQuery.Where("slot_id = ?", filter.SlotID)
+		extensionQuery = extensionQuery.Where("bookings.slot_id = ?", filter.SlotID)
+		holdQuery = holdQuery.Where("slot_id = ?", filter.SlotID)
+	}
+	if filter.ExcludeHoldID != 0 {
+		holdQuery = holdQuery.Where("id <> ?", filter.ExcludeHoldID)
+	}
+	if filter.ExcludeBookingID != 0 {
+		bookingQuery = bookingQuery.Where("id <> ?", filter.ExcludeBookingID)
+		extensionQuery = extensionQuery.Where("bookings.id <> ?", filter.ExcludeBookingID)
+	}
 
-		return nil
-	})
+	var bookings []models.Booking
+	err := bookingQuery.Order("start_at ASC").Find(&bookings).Error
 	if err != nil {
 		return nil, err
 	}
 
-	return booking, nil
-}
-
-func (s *BookingService) CheckoutWithPlateNumber(plateNumber string) (*models.Booking, error) {
-	var booking *models.Booking
-	err := s.DB.Preload("Slot").Preload("Parking").Preload("User").Where("plate_number = ?", plateNumber).First(&booking).Error
+	var extensions []struct {
+		SlotID        int
+		PreviousEndAt time.Time
+		NewEndAt      time.Time
+	}
+	err = extensionQuery.Order("booking_extensions.previous_end_at ASC").Scan(&extensions).Error
 	if err != nil {
-		return nil, fmt.Errorf("Booking with plate number %s not found", plateNumber)
+		return nil, err
 	}
 
-	disallowedStatus := []string{"UNPAID", "CANCELED"}
-	if slices.Contains(disallowedStatus, booking.Status) {
-		return booking, fmt.Errorf("Booking with reference %s (%s) is not allowed to be checked out", booking.PaymentReference, booking.Status)
+	var holds []models.SlotHold
+	err = holdQuery.Order("start_at ASC").Find(&holds).Error
+	if err != nil {
+		return nil, err
 	}
 
-	if booking.Status == "EXPIRED" {
-		return booking, fmt.Errorf("Booking with reference %s is expired", booking.PaymentReference)
+	conflicts := make(map[int][]models.TimeInterval)
+	for _, booking := range bookings {
+		conflicts[booking.SlotID] = append(conflicts[booking.SlotID], models.TimeInterval{
+			StartAt: booking.StartAt,
+			EndAt:   booking.EndAt,
+			Source:  "BOOKING",
+		})
 	}
-
-	if booking.Status == "COMPLETED" {
-		return booking, fmt.Errorf("Booking with reference %s is already completed", booking.PaymentReference)
+	for _, extension := range extensions {
+		conflicts[extension.SlotID] = append(conflicts[extension.SlotID], models.TimeInterval{
+			StartAt: extension.PreviousEndAt,
+			EndAt:   extension.NewEndAt,
+			Source:  "EXTENSION",
+		})
+	}
+	for _, hold := range holds {
+		conflicts[hold.SlotID] = append(conflicts[hold.SlotID], models.TimeInterval{
+			StartAt: hold.StartAt,
+			EndAt:   hold.EndAt,
+			Source:  "HOLD",
+		})
 	}
 
-	s.DB.Transaction(func(tx *gorm.DB) error {
-		booking.Status = "COMPLETED"
-		err = tx.Save(&booking).Error
-		if err != nil {
-			return err
-		}
+	return conflicts, nil
+}
 
-		parkingSlot := booking.Slot
-		parkingSlot.Status = "AVAILABLE"
-		err = tx.Save(&parkingSlot).Error
-		if err != nil {
-			return err
-		}
+func isSlotOverlapError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == "bookings_slot_no_overlap"
+}
 
-		return nil
-	})
-	if err != nil {
-		return nil, err
+// invoiceLines turns a price breakdown into invoice items and fees, adjustments such as daily caps become negative fees.
+func invoiceLines(title string, referenceID string, price *models.PriceBreakdown) ([]paymentgateway.InvoiceItem, []paymentgateway.InvoiceFee) {
+	items := make([]paymentgateway.InvoiceItem, 0, len(price.Items))
+	for _, item := range price.Items {
+		items = append(items, paymentgateway.InvoiceItem{
+			Name:        fmt.Sprintf("%s | %s", title, item.Name),
+			Price:       item.Rate,
+			Quantity:    float64(item.Quantity),
+			ReferenceID: referenceID,
+		})
 	}
 
-	return booking, nil
+	fees := make([]paymentgateway.InvoiceFee, 0, len(price.Adjustments))
+	for _, adjustment := range price.Adjustments {
+		fees = append(fees, paymentgateway.InvoiceFee{
+			Type:  adjustment.Name,
+			Value: adjustment.Amount,
+		})
+	}
+
+	return items, fees
 }
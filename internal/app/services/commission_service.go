@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"math"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type CommissionService struct {
+	DB       *gorm.DB
+	Validate *validator.Validate
+}
+
+func NewCommissionService(db *gorm.DB, validate *validator.Validate) *CommissionService {
+	return &CommissionService{
+		DB:       db,
+		Validate: validate,
+	}
+}
+
+// CalculateCommission returns the share of amount kept by the platform under plan. The flat fee is only charged
+// once per booking, on its first payment. The commission never exceeds the amount.
+func CalculateCommission(plan *models.CommissionPlan, amount float64, firstPayment bool) float64 {
+	if plan == nil || amount <= 0 {
+		return 0
+	}
+
+	commission := math.Round(amount*plan.Percentage) / 100
+	if firstPayment {
+		commission += plan.FlatFee
+	}
+
+	return math.Min(commission, amount)
+}
+
+// resolveCommissionPlan returns the plan of the parking, or the default plan when the parking has none. It returns
+// nil when no plan applies.
+func resolveCommissionPlan(db *gorm.DB, parking *models.Parking) (*models.CommissionPlan, error) {
+	var plan models.CommissionPlan
+	query := db.Where("is_default = ?", true)
+	if parking.CommissionPlanID != nil {
+		query = db.Where("id = ?", *parking.CommissionPlanID)
+	}
+
+	err := query.First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// bookingCommissionPlan returns the plan a booking was made under, even when the plan was deleted since.
+func bookingCommissionPlan(db *gorm.DB, booking *models.Booking) (*models.CommissionPlan, error) {
+	if booking.CommissionPlanID == nil {
+		return nil, nil
+	}
+
+	var plan models.CommissionPlan
+	err := db.Unscoped().First(&plan, *booking.CommissionPlanID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+func (s *CommissionService) GetCommissionPlans() ([]*models.CommissionPlan, error) {
+	var plans []*models.CommissionPlan
+	err := s.DB.Order("id ASC").Find(&plans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+func (s *CommissionService) GetCommissionPlanByID(id int) (*models.CommissionPlan, error) {
+	var plan *models.CommissionPlan
+	err := s.DB.First(&plan, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (s *CommissionService) CreateCommissionPlan(req *models.CreateCommissionPlanRequest) (*models.CommissionPlan, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.CommissionPlan{
+		Name:       req.Name,
+		Percentage: req.Percentage,
+		FlatFee:    req.FlatFee,
+		ServiceFee: req.ServiceFee,
+		IsDefault:  req.IsDefault,
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if plan.IsDefault {
+			err := tx.Model(&models.CommissionPlan{}).Where("is_default = ?", true).Update("is_default", false).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(plan).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// UpdateCommissionPlan only affects bookings made afterwards, existing bookings keep the amounts they were
+// charged with.
+func (s *CommissionService) UpdateCommissionPlan(id int, req *models.UpdateCommissionPlanRequest) (*models.CommissionPlan, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.GetCommissionPlanByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		plan.Name = req.Name
+	}
+	if req.Percentage != nil {
+		plan.Percentage = *req.Percentage
+	}
+	if req.FlatFee != nil {
+		plan.FlatFee = *req.FlatFee
+	}
+	if req.ServiceFee != nil {
+		plan.ServiceFee = *req.ServiceFee
+	}
+	if req.IsDefault != nil {
+		plan.IsDefault = *req.IsDefault
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if plan.IsDefault {
+			err := tx.Model(&models.CommissionPlan{}).Where("is_default = ? AND id <> ?", true, plan.ID).Update("is_default", false).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Save(plan).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (s *CommissionService) DeleteCommissionPlan(id int) error {
+	var parkingCount int64
+	err := s.DB.Model(&models.Parking{}).Where("commission_plan_id = ? AND deleted_at IS NULL", id).Count(&parkingCount).Error
+	if err != nil {
+		return err
+	}
+
+	if parkingCount > 0 {
+		return errors.New("commission plan is still used by parkings")
+	}
+
+	return s.DB.Delete(&models.CommissionPlan{}, id).Error
+}
+
+// GetRevenueReport sums the ledger entries posted within the period per parking.
+func (s *CommissionService) GetRevenueReport(filter *models.RevenueReportFilter) (*models.RevenueReport, error) {
+	report := &models.RevenueReport{
+		From:     filter.From,
+		To:       filter.To,
+		Parkings: []models.ParkingRevenue{},
+	}
+
+	query := s.DB.Model(&models.LedgerEntry{}).
+		Select("parking_id, account, type, SUM(amount) AS amount").
+		Where("type NOT IN ?", []string{models.LedgerEntryOpeningBalance, models.LedgerEntryPayout, models.LedgerEntryPayoutReversal}).
+		Group("parking_id, account, type").
+		Order("parking_id ASC")
+	if filter.ParkingID != 0 {
+		query = query.Where("parking_id = ?", filter.ParkingID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var rows []struct {
+		ParkingID int
+		Account   string
+		Type      string
+		Amount    float64
+	}
+	err := query.Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[int]int)
+	var parkingIDs []int
+	for _, row := range rows {
+		i, ok := index[row.ParkingID]
+		if !ok {
+			i = len(report.Parkings)
+			index[row.ParkingID] = i
+			parkingIDs = append(parkingIDs, row.ParkingID)
+			report.Parkings = append(report.Parkings, models.ParkingRevenue{ParkingID: row.ParkingID})
+		}
+		revenue := &report.Parkings[i]
+
+		switch row.Account {
+		case models.LedgerAccountGatewayClearing:
+			if row.Type == models.LedgerEntryRefund || row.Type == models.LedgerEntryRefundReversal {
+				revenue.Refunded += row.Amount
+			} else {
+				revenue.GrossRevenue -= row.Amount
+			}
+		case models.LedgerAccountParkingAvailable:
+			revenue.OwnerEarnings += row.Amount
+		case models.LedgerAccountPlatformRevenue:
+			if row.Type == models.LedgerEntryServiceFee {
+				revenue.ServiceFees += row.Amount
+			} else {
+				revenue.Commission += row.Amount
+			}
+			revenue.PlatformIncome += row.Amount
+		}
+	}
+
+	if len(parkingIDs) > 0 {
+		var parkings []struct {
+			ID   int
+			Name string
+		}
+		err = s.DB.Model(&models.Parking{}).Select("id, name").Where("id IN ?", parkingIDs).Scan(&parkings).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, parking := range parkings {
+			report.Parkings[index[parking.ID]].ParkingName = parking.Name
+		}
+	}
+
+	for _, revenue := range report.Parkings {
+		report.GrossRevenue += revenue.GrossRevenue
+		report.OwnerEarnings += revenue.OwnerEarnings
+		report.Commission += revenue.Commission
+		report.ServiceFees += revenue.ServiceFees
+		report.Refunded += revenue.Refunded
+		report.PlatformIncome += revenue.PlatformIncome
+	}
+
+	return report, nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"gorm.io/gorm"
+)
+
+// LedgerService posts the money movements of parkings to the append-only ledger. Entries are never updated,
+// corrections are posted as new transactions.
+type LedgerService struct {
+	DB *gorm.DB
+}
+
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{
+		DB: db,
+	}
+}
+
+func (s *LedgerService) GetLedgerEntries(filter *models.LedgerEntryFilter) ([]*models.LedgerEntry, error) {
+	var entries []*models.LedgerEntry
+	query := s.DB.Order("id DESC")
+
+	if filter != nil {
+		if filter.ParkingID != 0 {
+			query = query.Where("parking_id = ?", filter.ParkingID)
+		}
+		if filter.BookingID != 0 {
+			query = query.Where("booking_id = ?", filter.BookingID)
+		}
+		if filter.Account != "" {
+			query = query.Where("account = ?", filter.Account)
+		}
+		if filter.Type != "" {
+			query = query.Where("type = ?", filter.Type)
+		}
+		if filter.Limit != 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Page != 0 {
+			query = query.Offset((filter.Page - 1) * filter.Limit)
+		}
+	}
+
+	err := query.Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// PostTx writes the entries as a single transaction within tx. The amounts must add up to zero.
+func (s *LedgerService) PostTx(tx *gorm.DB, entries []models.LedgerEntry) error {
+	total := 0.0
+	for _, entry := range entries {
+		total += entry.Amount
+	}
+	if len(entries) < 2 || math.Abs(total) >= 0.005 {
+		return fmt.Errorf("ledger transaction is not balanced (%.2f)", total)
+	}
+
+	transactionID := "LTX-" + pkg.RandomString(16)
+	for i := range entries {
+		entries[i].TransactionID = transactionID
+	}
+
+	return tx.Create(&entries).Error
+}
+
+// RecordPaymentTx credits money received through the gateway to the parking.
+func (s *LedgerService) RecordPaymentTx(tx *gorm.DB, entryType string, booking *models.Booking, reference string, amount float64) error {
+	return s.transferTx(tx, entryType, booking.ParkingID, &booking.ID, reference, models.LedgerAccountGatewayClearing, models.LedgerAccountParkingAvailable, amount)
+}
+
+// RecordCommissionTx moves the share of a payment kept by the platform from the parking to the platform.
+func (s *LedgerService) RecordCommissionTx(tx *gorm.DB, booking *models.Booking, reference string, amount float64) error {
+	return s.transferTx(tx, models.LedgerEntryCommission, booking.ParkingID, &booking.ID, reference, models.LedgerAccountParkingAvailable, models.LedgerAccountPlatformRevenue, amount)
+}
+
+// RecordSubscriptionPaymentTx credits a subscription payment to the parking and keeps the commission on it for
+// the platform. Subscriptions have no booking, their entries are only tied to the parking.
+func (s *LedgerService) RecordSubscriptionPaymentTx(tx *gorm.DB, subscription *models.Subscription, reference string, amount float64, commission float64) error {
+	return s.parkingPaymentTx(tx, models.LedgerEntrySubscriptionPayment, subscription.ParkingID, reference, amount, commission)
+}
+
+// RecordSessionPaymentTx credits the payment of a walk-in session to the parking and keeps the commission on it
+// for the platform.
+func (s *LedgerService) RecordSessionPaymentTx(tx *gorm.DB, session *models.ParkingSession, reference string, amount float64, commission float64) error {
+	return s.parkingPaymentTx(tx, models.LedgerEntrySessionPayment, session.ParkingID, reference, amount, commission)
+}
+
+func (s *LedgerService) parkingPaymentTx(tx *gorm.DB, entryType string, parkingID int, reference string, amount float64, commission float64) error {
+	err := s.transferTx(tx, entryType, parkingID, nil, reference, models.LedgerAccountGatewayClearing, models.LedgerAccountParkingAvailable, amount)
+	if err != nil {
+		return err
+	}
+
+	return s.transferTx(tx, models.LedgerEntryCommission, parkingID, nil, reference, models.LedgerAccountParkingAvailable, models.LedgerAccountPlatformRevenue, commission)
+}
+
+// RecordServiceFeeTx credits the service fee the customer paid on top of the parking fee to the platform.
+func (s *LedgerService) RecordServiceFeeTx(tx *gorm.DB, booking *models.Booking, reference string, amount float64) error {
+	return s.transferTx(tx, models.LedgerEntryServiceFee, booking.ParkingID, &booking.ID, reference, models.LedgerAccountGatewayClearing, models.LedgerAccountPlatformRevenue, amount)
+}
+
+// RecordRefundTx debits a refund returned through the gateway. The platform returns the commission it kept on the
+// refunded amount, the parking the rest.
+func (s *LedgerService) RecordRefundTx(tx *gorm.DB, refund *models.Refund) error {
+	return s.refundTx(tx, models.LedgerEntryRefund, refund, 1)
+}
+
+// RecordRefundReversalTx undoes the entries of a refund the gateway rejected.
+func (s *LedgerService) RecordRefundReversalTx(tx *gorm.DB, refund *models.Refund) error {
+	return s.refundTx(tx, models.LedgerEntryRefundReversal, refund, -1)
+}
+
+func (s *LedgerService) refundTx(tx *gorm.DB, entryType string, refund *models.Refund, sign float64) error {
+	if refund.Amount == 0 {
+		return nil
+	}
+
+	entries := []models.LedgerEntry{
+		{Account: models.LedgerAccountParkingAvailable, Amount: -sign * (refund.Amount - refund.CommissionAmount)},
+		{Account: models.LedgerAccountGatewayClearing, Amount: sign * refund.Amount},
+	}
+	if refund.CommissionAmount != 0 {
+		entries = append(entries, models.LedgerEntry{Account: models.LedgerAccountPlatformRevenue, Amount: -sign * refund.CommissionAmount})
+	}
+	for i := range entries {
+		entries[i].ParkingID = refund.ParkingID
+		entries[i].BookingID = &refund.BookingID
+		entries[i].Type = entryType
+		entries[i].Reference = refund.ReferenceID
+	}
+
+	return s.PostTx(tx, entries)
+}
+
+// RecordPayoutTx moves an approved payout from the available to the withdrawn earnings of the parking.
+func (s *LedgerService) RecordPayoutTx(tx *gorm.DB, payout *models.Payout) error {
+	return s.transferTx(tx, models.LedgerEntryPayout, payout.ParkingID, nil, payout.Reference, models.LedgerAccountParkingAvailable, models.LedgerAccountParkingWithdrawn, payout.Amount)
+}
+
+// RecordPayoutReversalTx returns a failed payout to the available earnings of the parking.
+func (s *LedgerService) RecordPayoutReversalTx(tx *gorm.DB, payout *models.Payout) error {
+	return s.transferTx(tx, models.LedgerEntryPayoutReversal, payout.ParkingID, nil, payout.Reference, models.LedgerAccountParkingWithdrawn, models.LedgerAccountParkingAvailable, payout.Amount)
+}
+
+func (s *LedgerService) transferTx(tx *gorm.DB, entryType string, parkingID int, bookingID *int, reference string, from string, to string, amount float64) error {
+	if amount == 0 {
+		return nil
+	}
+
+	return s.PostTx(tx, []models.LedgerEntry{
+		{ParkingID: parkingID, BookingID: bookingID, Account: from, Type: entryType, Amount: -amount, Reference: reference},
+		{ParkingID: parkingID, BookingID: bookingID, Account: to, Type: entryType, Amount: amount, Reference: reference},
+	})
+}
+
+var paidBookingStatuses = []string{models.BookingStatusPaid, models.BookingStatusOvertimeUnpaid, models.BookingStatusCompleted, models.BookingStatusNoShow}
+
+// CheckParking compares the payments and refunds the bookings of a parking should have posted with its ledger.
+// Bookings older than the opening balance of the parking are covered by that balance and skipped.
+func (s *LedgerService) CheckParking(parkingID int) (*models.LedgerConsistencyReport, error) {
+	balance, err := models.LoadParkingBalance(s.DB, parkingID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.LedgerConsistencyReport{
+		ParkingID:              parkingID,
+		Balance:                balance,
+		UnbalancedTransactions: []string{},
+		Issues:                 []models.LedgerConsistencyIssue{},
+	}
+
+	err = s.DB.Model(&models.LedgerEntry{}).
+		Where("parking_id = ?", parkingID).
+		Group("transaction_id").
+		Having("ABS(SUM(amount)) >= 0.005").
+		Pluck("transaction_id", &report.UnbalancedTransactions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var opening models.LedgerEntry
+	query := s.DB.Preload("Refunds").Preload("Extensions").Where("parking_id = ?", parkingID)
+	err = s.DB.Where("parking_id = ? AND type = ?", parkingID, models.LedgerEntryOpeningBalance).Order("id ASC").Limit(1).Find(&opening).Error
+	if err != nil {
+		return nil, err
+	}
+	if opening.ID != 0 {
+		query = query.Where("created_at > ?", opening.CreatedAt)
+	}
+
+	var bookings []models.Booking
+	err = query.Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var recorded []struct {
+		BookingID int
+		Type      string
+		Amount    float64
+	}
+	err = s.DB.Model(&models.LedgerEntry{}).
+		Select("booking_id, type, SUM(amount) AS amount").
+		Where("parking_id = ? AND account = ? AND booking_id IS NOT NULL", parkingID, models.LedgerAccountParkingAvailable).
+		Group("booking_id, type").
+		Scan(&recorded).Error
+	if err != nil {
+		return nil, err
+	}
+
+	recordedPayments := make(map[int]float64)
+	recordedRefunds := make(map[int]float64)
+	for _, row := range recorded {
+		switch row.Type {
+		case models.LedgerEntryBookingPayment, models.LedgerEntryExtensionPayment, models.LedgerEntryOvertimePayment:
+			recordedPayments[row.BookingID] += row.Amount
+		case models.LedgerEntryRefund, models.LedgerEntryRefundReversal:
+			recordedRefunds[row.BookingID] -= row.Amount
+		}
+	}
+
+	for _, booking := range bookings {
+		expectedRefunds := 0.0
+		for _, refund := range booking.Refunds {
+			if refund.Status != models.RefundStatusFailed {
+				expectedRefunds += refund.Amount - refund.CommissionAmount
+			}
+		}
+
+		// A canceled booking was paid when something was refunded or posted for it
+		wasPaid := slices.Contains(paidBookingStatuses, booking.Status) ||
+			(booking.Status == models.BookingStatusCanceled && (len(booking.Refunds) > 0 || recordedPayments[booking.ID] != 0))
+		if !wasPaid && recordedPayments[booking.ID] == 0 && recordedRefunds[booking.ID] == 0 {
+			continue
+		}
+		report.CheckedBookings++
+
+		expectedPayments := 0.0
+		if wasPaid {
+			expectedPayments = booking.TotalFee
+		}
+		if booking.OvertimePaidAt != nil {
+			expectedPayments += booking.OvertimeFee
+		}
+		// A failed extension was paid and refunded without moving the booking
+		for _, extension := range booking.Extensions {
+			if extension.Status == models.BookingExtensionStatusFailed && extension.Fee > 0 && extension.PaymentInvoiceID != "" {
+				expectedPayments += extension.Fee
+			}
+		}
+
+		if math.Abs(expectedPayments-recordedPayments[booking.ID]) >= 0.005 {
+			report.Issues = append(report.Issues, models.LedgerConsistencyIssue{
+				BookingID: booking.ID,
+				Reference: booking.PaymentReference,
+				Type:      "PAYMENT",
+				Expected:  expectedPayments,
+				Recorded:  recordedPayments[booking.ID],
+			})
+		}
+		if math.Abs(expectedRefunds-recordedRefunds[booking.ID]) >= 0.005 {
+			report.Issues = append(report.Issues, models.LedgerConsistencyIssue{
+				BookingID: booking.ID,
+				Reference: booking.PaymentReference,
+				Type:      "REFUND",
+				Expected:  expectedRefunds,
+				Recorded:  recordedRefunds[booking.ID],
+			})
+		}
+	}
+
+	report.IsConsistent = len(report.UnbalancedTransactions) == 0 && len(report.Issues) == 0
+
+	return report, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+)
+
+// OverlapMinutes returns how many whole minutes of [startAt, endAt) fall within [from, to).
+func OverlapMinutes(startAt time.Time, endAt time.Time, from time.Time, to time.Time) int {
+	if startAt.Before(from) {
+		startAt = from
+	}
+	if endAt.After(to) {
+		endAt = to
+	}
+	if !endAt.After(startAt) {
+		return 0
+	}
+	return int(endAt.Sub(startAt) / time.Minute)
+}
+
+// BuildOccupancyReport sums the reserved and the occupied time of each slot within [from, to). Sessions still open
+// are counted until now.
+func BuildOccupancyReport(slots []models.ParkingSlot, bookings []*models.Booking, sessions []*models.ParkingSession, from time.Time, to time.Time, now time.Time) *models.OccupancyReport {
+	report := &models.OccupancyReport{
+		From:  from,
+		To:    to,
+		Slots: make([]models.SlotOccupancy, 0, len(slots)),
+	}
+
+	index := make(map[int]int)
+	for _, slot := range slots {
+		report.ParkingID = slot.ParkingID
+		index[slot.ID] = len(report.Slots)
+		report.Slots = append(report.Slots, models.SlotOccupancy{SlotID: slot.ID, SlotName: slot.Name})
+	}
+
+	for _, booking := range bookings {
+		i, ok := index[booking.SlotID]
+		if !ok {
+			continue
+		}
+		minutes := OverlapMinutes(booking.StartAt, booking.EndAt, from, to)
+		report.Slots[i].BookedMinutes += minutes
+		report.BookedMinutes += minutes
+	}
+
+	for _, session := range sessions {
+		i, ok := index[session.SlotID]
+		if !ok {
+			continue
+		}
+		exitedAt := now
+		if session.ExitedAt != nil {
+			exitedAt = *session.ExitedAt
+		}
+		minutes := OverlapMinutes(session.EnteredAt, exitedAt, from, to)
+
+		slot := &report.Slots[i]
+		slot.OccupiedMinutes += minutes
+		slot.Sessions++
+		report.OccupiedMinutes += minutes
+		report.Sessions++
+		if session.IsGuest() {
+			slot.GuestMinutes += minutes
+			report.GuestMinutes += minutes
+		}
+	}
+
+	return report
+}
+
+// GetOccupancyReport compares the time the slots of a parking were booked with the time they were actually
+// occupied, a day back from now by default.
+func (s *ParkingSessionService) GetOccupancyReport(user *models.User, filter *models.OccupancyReportFilter) (*models.OccupancyReport, error) {
+	var parking models.Parking
+	err := s.DB.First(&parking, filter.ParkingID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if parking.AuthorID != user.ID && !user.IsAdmin() {
+		return nil, pkg.NewForbiddenError("You are not allowed to view the occupancy of this parking")
+	}
+
+	now := pkg.GetCurrentTime()
+	from, to := filter.From, filter.To
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	if !to.After(from) {
+		return nil, errors.New("to must be after from")
+	}
+
+	var slots []models.ParkingSlot
+	err = s.DB.Where("parking_id = ?", parking.ID).Order("name ASC").Find(&slots).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var bookings []*models.Booking
+	err = s.DB.Select("id, slot_id, start_at, end_at").
+		Where("parking_id = ? AND status IN ?", parking.ID, paidBookingStatuses).
+		Where("start_at < ? AND end_at > ?", to, from).
+		Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*models.ParkingSession
+	err = s.DB.Where("parking_id = ? AND entered_at < ?", parking.ID, to).
+		Where("exited_at IS NULL OR exited_at > ?", from).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := BuildOccupancyReport(slots, bookings, sessions, from, to, now)
+	report.ParkingID = parking.ID
+	return report, nil
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"slices"
 	"strings"
+	"time"
 
 	"github.com/agilistikmal/parkingo-core/internal/app/models"
 	"github.com/go-playground/validator/v10"
@@ -13,14 +13,18 @@ import (
 )
 
 type ParkingService struct {
-	DB       *gorm.DB
-	Validate *validator.Validate
+	DB             *gorm.DB
+	Validate       *validator.Validate
+	Ledger         *LedgerService
+	SessionService *ParkingSessionService
 }
 
-func NewParkingService(db *gorm.DB, validate *validator.Validate) *ParkingService {
+func NewParkingService(db *gorm.DB, validate *validator.Validate, ledger *LedgerService, sessionService *ParkingSessionService) *ParkingService {
 	return &ParkingService{
-		DB:       db,
-		Validate: validate,
+		DB:             db,
+		Validate:       validate,
+		Ledger:         ledger,
+		SessionService: sessionService,
 	}
 }
 
@@ -62,6 +66,11 @@ func (s *ParkingService) GetParkings(filter *models.ParkingFilter) ([]models.Par
 		return nil, err
 	}
 
+	err = s.loadBalances(parkings)
+	if err != nil {
+		return nil, err
+	}
+
 	return parkings, nil
 }
 
@@ -72,6 +81,11 @@ func (s *ParkingService) GetMyParkings(authorID int) ([]models.Parking, error) {
 		return nil, err
 	}
 
+	err = s.loadBalances(parkings)
+	if err != nil {
+		return nil, err
+	}
+
 	return parkings, nil
 }
 
@@ -82,6 +96,12 @@ func (s *ParkingService) GetParkingByID(id int) (*models.Parking, error) {
 		return nil, err
 	}
 
+	balance, err := models.LoadParkingBalance(s.DB, parking.ID)
+	if err != nil {
+		return nil, err
+	}
+	parking.SetBalance(balance)
+
 	return parking, nil
 }
 
@@ -92,9 +112,38 @@ func (s *ParkingService) GetParkingBySlug(slug string) (*models.Parking, error)
 		return nil, err
 	}
 
+	balance, err := models.LoadParkingBalance(s.DB, parking.ID)
+	if err != nil {
+		return nil, err
+	}
+	parking.SetBalance(balance)
+
 	return parking, nil
 }
 
+// loadBalances fills in the earnings of the parkings with a single ledger query.
+func (s *ParkingService) loadBalances(parkings []models.Parking) error {
+	if len(parkings) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(parkings))
+	for i := range parkings {
+		ids[i] = parkings[i].ID
+	}
+
+	balances, err := models.LoadParkingBalances(s.DB, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range parkings {
+		parkings[i].SetBalance(balances[parkings[i].ID])
+	}
+
+	return nil
+}
+
 func (s *ParkingService) GetParkingSlotsByParkingID(parkingID int) ([]models.ParkingSlot, error) {
 	var slots []models.ParkingSlot
 	err := s.DB.Preload("Parking").Where("parking_id = ?", parkingID).Find(&slots).Error
@@ -115,6 +164,67 @@ func (s *ParkingService) GetParkingSlotByID(id int) (*models.ParkingSlot, error)
 	return slot, nil
 }
 
+// GetParkingSlotByName finds a slot by the slug of its parking and its name, the way devices address their slot.
+func (s *ParkingService) GetParkingSlotByName(parkingSlug string, slotName string) (*models.ParkingSlot, error) {
+	parking, err := s.GetParkingBySlug(parkingSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	var slot *models.ParkingSlot
+	err = s.DB.Where("parking_id = ? AND name = ?", parking.ID, slotName).First(&slot).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return slot, nil
+}
+
+func (s *ParkingService) GetParkingAvailability(id int, startAt time.Time, endAt time.Time) (*models.ParkingAvailability, error) {
+	if !endAt.After(startAt) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	slots, err := s.GetParkingSlotsByParkingID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(slots) == 0 {
+		_, err = s.GetParkingByID(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conflicts, err := findSlotConflicts(s.DB, slotConflictFilter{ParkingID: id}, startAt, endAt)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := &models.ParkingAvailability{
+		ParkingID: id,
+		StartAt:   startAt,
+		EndAt:     endAt,
+		Slots:     make([]models.SlotAvailability, 0, len(slots)),
+	}
+	for _, slot := range slots {
+		slot.Parking = nil
+		slotConflicts := conflicts[slot.ID]
+		if slotConflicts == nil {
+			slotConflicts = []models.TimeInterval{}
+		}
+
+		availability.Slots = append(availability.Slots, models.SlotAvailability{
+			Slot:        slot,
+			IsAvailable: len(slotConflicts) == 0,
+			Conflicts:   slotConflicts,
+		})
+	}
+
+	return availability, nil
+}
+
 func (s *ParkingService) CreateParking(authorID int, req *models.CreateParkingRequest) (*models.Parking, error) {
 	err := s.Validate.Struct(req)
 	if err != nil {
@@ -128,14 +238,40 @@ func (s *ParkingService) CreateParking(authorID int, req *models.CreateParkingRe
 	}
 
 	parking := models.Parking{
-		AuthorID:   authorID,
-		Slug:       req.Slug,
-		Name:       req.Name,
-		Address:    req.Address,
-		DefaultFee: req.DefaultFee,
-		Latitude:   req.Latitude,
-		Longitude:  req.Longitude,
-		Layout:     req.Layout,
+		AuthorID:                   authorID,
+		Slug:                       req.Slug,
+		Name:                       req.Name,
+		Address:                    req.Address,
+		DefaultFee:                 req.DefaultFee,
+		Latitude:                   req.Latitude,
+		Longitude:                  req.Longitude,
+		Layout:                     req.Layout,
+		OvertimeRate:               req.OvertimeRate,
+		OvertimeGraceMinutes:       15,
+		CancelFullRefundHours:      24,
+		CancelPartialRefundPercent: 50,
+		SlotAssignment:             models.SlotAssignmentClosest,
+	}
+	if req.OvertimeGraceMinutes != nil {
+		parking.OvertimeGraceMinutes = *req.OvertimeGraceMinutes
+	}
+	if req.CancelFullRefundHours != nil {
+		parking.CancelFullRefundHours = *req.CancelFullRefundHours
+	}
+	if req.CancelPartialRefundPercent != nil {
+		parking.CancelPartialRefundPercent = *req.CancelPartialRefundPercent
+	}
+	if req.CommissionPlanID != nil && *req.CommissionPlanID != 0 {
+		parking.CommissionPlanID = req.CommissionPlanID
+	}
+	if req.NoShowMinutes != nil {
+		parking.NoShowMinutes = *req.NoShowMinutes
+	}
+	if req.NoShowRefundPercent != nil {
+		parking.NoShowRefundPercent = *req.NoShowRefundPercent
+	}
+	if req.SlotAssignment != "" {
+		parking.SlotAssignment = req.SlotAssignment
 	}
 
 	err = s.DB.Transaction(func(tx *gorm.DB) error {
@@ -213,6 +349,34 @@ func (s *ParkingService) UpdateParking(id int, req *models.UpdateParkingRequest)
 	if req.Layout != nil {
 		parking.Layout = req.Layout
 	}
+	if req.OvertimeRate != nil {
+		parking.OvertimeRate = *req.OvertimeRate
+	}
+	if req.OvertimeGraceMinutes != nil {
+		parking.OvertimeGraceMinutes = *req.OvertimeGraceMinutes
+	}
+	if req.CancelFullRefundHours != nil {
+		parking.CancelFullRefundHours = *req.CancelFullRefundHours
+	}
+	if req.CancelPartialRefundPercent != nil {
+		parking.CancelPartialRefundPercent = *req.CancelPartialRefundPercent
+	}
+	// A commission plan of 0 goes back to the default plan
+	if req.CommissionPlanID != nil {
+		parking.CommissionPlanID = req.CommissionPlanID
+		if *req.CommissionPlanID == 0 {
+			parking.CommissionPlanID = nil
+		}
+	}
+	if req.NoShowMinutes != nil {
+		parking.NoShowMinutes = *req.NoShowMinutes
+	}
+	if req.NoShowRefundPercent != nil {
+		parking.NoShowRefundPercent = *req.NoShowRefundPercent
+	}
+	if req.SlotAssignment != "" {
+		parking.SlotAssignment = req.SlotAssignment
+	}
 
 	err = s.DB.Save(&parking).Error
 	if err != nil {
@@ -223,23 +387,33 @@ func (s *ParkingService) UpdateParking(id int, req *models.UpdateParkingRequest)
 }
 
 func (s *ParkingService) UpdateParkingSlotStatus(parkingSlug string, slotName string, status string) error {
-	parking, err := s.GetParkingBySlug(parkingSlug)
+	slot, err := s.GetParkingSlotByName(parkingSlug, slotName)
 	if err != nil {
 		return err
 	}
 
-	var slot *models.ParkingSlot
-	err = s.DB.Where("parking_id = ? AND name = ?", parking.ID, slotName).First(&slot).Error
+	if status != "AVAILABLE" && status != "BOOKED" && status != "OCCUPIED" {
+		return errors.New("invalid status")
+	}
+
+	slot.Status = status
+	err = s.DB.Save(&slot).Error
 	if err != nil {
 		return err
 	}
 
-	if status != "AVAILABLE" && status != "BOOKED" && status != "OCCUPIED" {
-		return errors.New("invalid status")
+	// The sensor of the slot saw a vehicle enter or leave
+	switch status {
+	case "AVAILABLE":
+		_, err = s.SessionService.ExitSlot(slot.ID, models.ParkingSessionSourceSensor)
+	case "OCCUPIED":
+		_, err = s.SessionService.RecordSensorEntry(slot)
+	}
+	if err != nil {
+		return err
 	}
 
-	slot.Status = status
-	return s.DB.Save(&slot).Error
+	return nil
 }
 
 func (s *ParkingService) DeleteParking(id int) error {
@@ -272,13 +446,15 @@ func (s *ParkingService) CreateParkingSlot(req *models.CreateParkingSlotRequest)
 	}
 
 	slot := models.ParkingSlot{
-		ParkingID: req.ParkingID,
-		Name:      req.Name,
-		Status:    req.Status,
-		Fee:       req.Fee,
-		Row:       req.Col,
-		Col:       req.Row,
-		ESPHmac:   req.ESPHmac,
+		ParkingID:    req.ParkingID,
+		Name:         req.Name,
+		Status:       req.Status,
+		Fee:          req.Fee,
+		Row:          req.Col,
+		Col:          req.Row,
+		ESPHmac:      req.ESPHmac,
+		DeviceSecret: req.DeviceSecret,
+		Attributes:   normalizeSlotAttributes(req.Attributes),
 	}
 
 	err = s.DB.Create(&slot).Error
@@ -318,6 +494,13 @@ func (s *ParkingService) UpdateParkingSlot(id int, req *models.UpdateParkingSlot
 	if req.ESPHmac != "" {
 		slot.ESPHmac = req.ESPHmac
 	}
+	if req.DeviceSecret != "" {
+		slot.DeviceSecret = req.DeviceSecret
+	}
+	// An empty list clears the attributes of the slot
+	if req.Attributes != nil {
+		slot.Attributes = normalizeSlotAttributes(req.Attributes)
+	}
 
 	err = s.DB.Save(&slot).Error
 	if err != nil {
@@ -341,36 +524,29 @@ func (s *ParkingService) DeleteParkingSlot(id int) error {
 	return nil
 }
 
-func (s *ParkingService) SyncParking(id int) error {
+// SyncParking recounts the paid bookings of the parking and checks its ledger against the bookings.
+func (s *ParkingService) SyncParking(id int) (*models.LedgerConsistencyReport, error) {
 	var parking *models.Parking
-	err := s.DB.Session(&gorm.Session{PrepareStmt: false}).Preload("Slots").First(&parking, id).Error
+	err := s.DB.First(&parking, id).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var bookings []models.Booking
-	err = s.DB.Session(&gorm.Session{PrepareStmt: false}).Preload("Parking").Where("parking_id = ?", id).Find(&bookings).Error
+	var totalBookings int64
+	err = s.DB.Model(&models.Booking{}).Where("parking_id = ? AND status IN ?", id, paidBookingStatuses).Count(&totalBookings).Error
 	if err != nil {
-		return err
-	}
-
-	// Reset parking earnings and total bookings
-	parking.TotalEarnings = 0
-	parking.AvailableEarnings = 0
-	parking.TotalBookings = 0
-
-	for _, booking := range bookings {
-		disallowedStatuses := []string{"UNPAID", "CANCELLED"}
-		if !slices.Contains(disallowedStatuses, booking.Status) {
-			parking.TotalEarnings += booking.TotalFee
-			parking.TotalBookings++
-		}
+		return nil, err
 	}
 
-	err = s.DB.Save(&parking).Error
+	err = s.DB.Model(&parking).Update("total_bookings", totalBookings).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return s.Ledger.CheckParking(id)
+}
+
+func (s *ParkingService) GetParkingLedger(id int, filter *models.LedgerEntryFilter) ([]*models.LedgerEntry, error) {
+	filter.ParkingID = id
+	return s.Ledger.GetLedgerEntries(filter)
 }
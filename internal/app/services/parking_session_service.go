@@ -0,0 +1,517 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	sessionReferencePrefix = "PKGO-GST-"
+	// sessionInvoiceDuration is how long a walk-in has to pay at the exit
+	sessionInvoiceDuration = 15 * time.Minute
+)
+
+type ParkingSessionService struct {
+	DB              *gorm.DB
+	Validate        *validator.Validate
+	PaymentProvider paymentgateway.PaymentProvider
+	PricingService  *PricingService
+	Ledger          *LedgerService
+}
+
+func NewParkingSessionService(db *gorm.DB, validate *validator.Validate, paymentProvider paymentgateway.PaymentProvider, pricingService *PricingService, ledger *LedgerService) *ParkingSessionService {
+	return &ParkingSessionService{
+		DB:              db,
+		Validate:        validate,
+		PaymentProvider: paymentProvider,
+		PricingService:  pricingService,
+		Ledger:          ledger,
+	}
+}
+
+// GuestSessionBillingEnd returns the end of the hours a walk-in is charged for, every started hour counts and at
+// least one hour is charged.
+func GuestSessionBillingEnd(enteredAt time.Time, exitedAt time.Time) time.Time {
+	hours := int((exitedAt.Sub(enteredAt) + time.Hour - 1) / time.Hour)
+	return enteredAt.Add(time.Duration(max(hours, 1)) * time.Hour)
+}
+
+// sessionEntry is a vehicle seen entering a slot. A sensor cannot read the plate, it is left empty until a camera
+// does unless a booking is due at the slot.
+type sessionEntry struct {
+	Slot           *models.ParkingSlot
+	PlateNumber    string
+	Source         string
+	BookingID      *int
+	SubscriptionID *int
+}
+
+// GetParkingSessions lists the sessions of a parking for its owner.
+func (s *ParkingSessionService) GetParkingSessions(user *models.User, filter *models.ParkingSessionFilter) ([]*models.ParkingSession, error) {
+	var parking models.Parking
+	err := s.DB.First(&parking, filter.ParkingID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if parking.AuthorID != user.ID && !user.IsAdmin() {
+		return nil, pkg.NewForbiddenError("You are not allowed to view the sessions of this parking")
+	}
+
+	var sessions []*models.ParkingSession
+	query := s.DB.Preload("Slot").Where("parking_id = ?", filter.ParkingID).Order("entered_at DESC")
+
+	if filter.BookingID != 0 {
+		query = query.Where("booking_id = ?", filter.BookingID)
+	}
+	if filter.PlateNumber != "" {
+		query = query.Where("plate_number = ?", filter.PlateNumber)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Limit != 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Page != 0 {
+		query = query.Offset((filter.Page - 1) * filter.Limit)
+	}
+
+	err = query.Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *ParkingSessionService) GetParkingSessionByReference(reference string) (*models.ParkingSession, error) {
+	var session *models.ParkingSession
+	err := s.DB.Preload("Parking").Preload("Slot").Where("payment_reference = ?", reference).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// RecordEntry opens a session for a vehicle seen at a slot. The vehicle already parked there keeps its session,
+// another plate closes it since the previous vehicle left unnoticed.
+func (s *ParkingSessionService) RecordEntry(entry *sessionEntry) (*models.ParkingSession, error) {
+	now := pkg.GetCurrentTime()
+
+	var open *models.ParkingSession
+	err := s.DB.Where("slot_id = ? AND status = ?", entry.Slot.ID, models.ParkingSessionStatusOpen).First(&open).Error
+	if err == nil {
+		// A sensor cannot tell vehicles apart, the one parked there is assumed to stay
+		if entry.Source == models.ParkingSessionSourceSensor {
+			return open, nil
+		}
+
+		if open.PlateNumber == "" || pkg.CalculateSimilarity(open.PlateNumber, entry.PlateNumber) >= 0.7 {
+			return s.identifySession(open, entry)
+		}
+
+		_, err = s.closeSession(open, now, entry.Source)
+		if err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	session := &models.ParkingSession{
+		ParkingID:      entry.Slot.ParkingID,
+		SlotID:         entry.Slot.ID,
+		BookingID:      entry.BookingID,
+		SubscriptionID: entry.SubscriptionID,
+		PlateNumber:    entry.PlateNumber,
+		Source:         entry.Source,
+		EnteredAt:      now,
+		Status:         models.ParkingSessionStatusOpen,
+	}
+	err = s.DB.Create(session).Error
+	if err != nil {
+		// A concurrent sighting opened the session of the slot first
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			err = s.DB.Where("slot_id = ? AND status = ?", entry.Slot.ID, models.ParkingSessionStatusOpen).First(session).Error
+			if err != nil {
+				return nil, err
+			}
+			return session, nil
+		}
+		return nil, err
+	}
+
+	err = s.recordArrival(session.BookingID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// recordArrival marks the booking of a session as arrived, which keeps it from becoming a no-show.
+func (s *ParkingSessionService) recordArrival(bookingID *int, arrivedAt time.Time) error {
+	if bookingID == nil {
+		return nil
+	}
+
+	return s.DB.Model(&models.Booking{}).Where("id = ? AND arrived_at IS NULL", *bookingID).Update("arrived_at", arrivedAt).Error
+}
+
+// RecordSensorEntry opens a session for a vehicle detected by the sensor of a slot. The vehicle is assumed to be
+// the one of the booking due at the slot, if any.
+func (s *ParkingSessionService) RecordSensorEntry(slot *models.ParkingSlot) (*models.ParkingSession, error) {
+	now := pkg.GetCurrentTime()
+	entry := &sessionEntry{Slot: slot, Source: models.ParkingSessionSourceSensor}
+
+	var booking models.Booking
+	err := s.DB.Where("slot_id = ? AND status = ? AND start_at <= ? AND end_at > ?", slot.ID, models.BookingStatusPaid, now.Add(bookingArrivalTolerance), now).
+		Order("start_at ASC").First(&booking).Error
+	if err == nil {
+		entry.PlateNumber = booking.PlateNumber
+		entry.BookingID = &booking.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return s.RecordEntry(entry)
+}
+
+// identifySession fills in what a camera learned about the vehicle of an open session.
+func (s *ParkingSessionService) identifySession(session *models.ParkingSession, entry *sessionEntry) (*models.ParkingSession, error) {
+	updates := map[string]interface{}{}
+	if session.PlateNumber == "" {
+		updates["plate_number"] = entry.PlateNumber
+		session.PlateNumber = entry.PlateNumber
+	}
+	if session.IsGuest() && (entry.BookingID != nil || entry.SubscriptionID != nil) {
+		updates["booking_id"] = entry.BookingID
+		updates["subscription_id"] = entry.SubscriptionID
+		session.BookingID = entry.BookingID
+		session.SubscriptionID = entry.SubscriptionID
+	}
+	if len(updates) == 0 {
+		return session, nil
+	}
+
+	err := s.DB.Model(&models.ParkingSession{}).Where("id = ?", session.ID).Updates(updates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.recordArrival(session.BookingID, session.EnteredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// ExitSlot ends the open session of a slot that was freed, it returns nil when the slot had none.
+func (s *ParkingSessionService) ExitSlot(slotID int, source string) (*models.ParkingSession, error) {
+	var session *models.ParkingSession
+	err := s.DB.Where("slot_id = ? AND status = ?", slotID, models.ParkingSessionStatusOpen).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return s.closeSession(session, pkg.GetCurrentTime(), source)
+}
+
+// CheckoutParkingSession ends the open session of a plate leaving the parking.
+func (s *ParkingSessionService) CheckoutParkingSession(plateNumber string) (*models.ParkingSession, error) {
+	var session *models.ParkingSession
+	err := s.DB.Where("plate_number = ? AND status = ?", plateNumber, models.ParkingSessionStatusOpen).Order("entered_at DESC").First(&session).Error
+	if err != nil {
+		return nil, fmt.Errorf("Parking session with plate number %s not found", plateNumber)
+	}
+
+	return s.closeSession(session, pkg.GetCurrentTime(), models.ParkingSessionSourceGate)
+}
+
+// CloseBookingSessions ends the sessions of a booking still open and returns when its vehicle actually left, nil
+// when the vehicle was never seen.
+func (s *ParkingSessionService) CloseBookingSessions(bookingID int, source string) (*time.Time, error) {
+	var sessions []*models.ParkingSession
+	err := s.DB.Where("booking_id = ?", bookingID).Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var exitedAt *time.Time
+	for _, session := range sessions {
+		if session.Status == models.ParkingSessionStatusOpen {
+			session, err = s.closeSession(session, pkg.GetCurrentTime(), source)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if session.ExitedAt != nil && (exitedAt == nil || session.ExitedAt.After(*exitedAt)) {
+			exitedAt = session.ExitedAt
+		}
+	}
+
+	return exitedAt, nil
+}
+
+// ReissueInvoice issues a new invoice for an unpaid session whose invoice expired before the guest paid. Nothing is
+// reissued unless the old invoice is expired at the gateway.
+func (s *ParkingSessionService) ReissueInvoice(user *models.User, id int) (*models.ParkingSession, error) {
+	var session *models.ParkingSession
+	err := s.DB.Preload("Parking").Preload("Slot").First(&session, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Parking.AuthorID != user.ID && !user.IsAdmin() {
+		return nil, pkg.NewForbiddenError("You are not allowed to bill this session")
+	}
+
+	if session.Status != models.ParkingSessionStatusUnpaid {
+		return nil, pkg.NewConflictError("parking session is not waiting for payment")
+	}
+	if session.PaymentExpiredAt != nil && session.PaymentExpiredAt.After(pkg.GetCurrentTime()) {
+		return nil, pkg.NewConflictError("parking session invoice is still payable")
+	}
+
+	// The old reference is replaced, a payment of the old invoice could no longer be matched to the session. The
+	// invoice must be expired for good first, it may have been paid after all.
+	if session.PaymentInvoiceID != "" {
+		err = s.expireOldInvoice(session)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var paymentInvoice *paymentgateway.Invoice
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		paymentInvoice, err = s.issueInvoiceTx(tx, session, session.Parking, session.Slot)
+		return err
+	})
+	if err != nil {
+		s.expireInvoice(paymentInvoice)
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// expireOldInvoice makes sure the invoice of a session can no longer be paid. An invoice the gateway already expired
+// is fine, any other failure keeps the session on its current invoice.
+func (s *ParkingSessionService) expireOldInvoice(session *models.ParkingSession) error {
+	_, err := s.PaymentProvider.ExpireInvoice(session.PaymentInvoiceID)
+	if err == nil {
+		return nil
+	}
+
+	paymentInvoice, getErr := s.PaymentProvider.GetInvoice(session.PaymentInvoiceID)
+	if getErr == nil && paymentInvoice.Status == paymentgateway.InvoiceStatusExpired {
+		return nil
+	}
+
+	logrus.Errorf("Failed to expire invoice of %s: %v", session.PaymentReference, err)
+	if getErr == nil && paymentInvoice.IsPaid() {
+		return pkg.NewConflictError("parking session invoice is already paid")
+	}
+	return pkg.NewConflictError("parking session invoice could not be expired")
+}
+
+func (s *ParkingSessionService) ProcessPaymentEvent(event *paymentgateway.WebhookEvent) error {
+	var session models.ParkingSession
+	err := s.DB.Preload("Parking").Where("payment_reference = ?", event.ExternalID).First(&session).Error
+	if err != nil {
+		return err
+	}
+
+	// An expired invoice leaves the session unpaid until the owner issues it again
+	if !event.IsPaid() {
+		return nil
+	}
+
+	err = checkPaidEvent(event, session.Fee)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := pkg.GetCurrentTime()
+		result := tx.Model(&models.ParkingSession{}).
+			Where("id = ? AND status = ?", session.ID, models.ParkingSessionStatusUnpaid).
+			Updates(map[string]interface{}{
+				"status":     models.ParkingSessionStatusPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		commissionPlan, err := resolveCommissionPlan(tx, session.Parking)
+		if err != nil {
+			return err
+		}
+
+		commission := CalculateCommission(commissionPlan, session.Fee, false)
+		err = tx.Model(&models.ParkingSession{}).Where("id = ?", session.ID).Update("commission_fee", commission).Error
+		if err != nil {
+			return err
+		}
+
+		return s.Ledger.RecordSessionPaymentTx(tx, &session, session.PaymentReference, session.Fee, commission)
+	})
+}
+
+// closeSession ends an open session. A walk-in is priced with the tariff of its slot and issued the invoice paid at
+// the exit, one whose plate was never read cannot be billed and is only closed.
+func (s *ParkingSessionService) closeSession(session *models.ParkingSession, exitedAt time.Time, source string) (*models.ParkingSession, error) {
+	if !session.IsGuest() || session.PlateNumber == "" {
+		result := s.DB.Model(&models.ParkingSession{}).
+			Where("id = ? AND status = ?", session.ID, models.ParkingSessionStatusOpen).
+			Updates(map[string]interface{}{
+				"exited_at":   exitedAt,
+				"exit_source": source,
+				"status":      models.ParkingSessionStatusClosed,
+				"updated_at":  exitedAt,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, pkg.NewConflictError("parking session is already closed")
+		}
+
+		session.ExitedAt = &exitedAt
+		session.ExitSource = source
+		session.Status = models.ParkingSessionStatusClosed
+		return session, nil
+	}
+
+	var slot models.ParkingSlot
+	err := s.DB.Preload("Parking").First(&slot, session.SlotID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := s.PricingService.QuoteSlotPrice(&slot, session.EnteredAt, GuestSessionBillingEnd(session.EnteredAt, exitedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	var paymentInvoice *paymentgateway.Invoice
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"exited_at":   exitedAt,
+			"exit_source": source,
+			"total_hours": price.TotalHours,
+			"fee":         price.Total,
+			"status":      models.ParkingSessionStatusUnpaid,
+			"updated_at":  exitedAt,
+		}
+		// Free parking has nothing to pay
+		if price.Total <= 0 {
+			updates["status"] = models.ParkingSessionStatusPaid
+			updates["paid_at"] = exitedAt
+		}
+
+		result := tx.Model(&models.ParkingSession{}).
+			Where("id = ? AND status = ?", session.ID, models.ParkingSessionStatusOpen).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return pkg.NewConflictError("parking session is already closed")
+		}
+
+		session.ExitedAt = &exitedAt
+		session.ExitSource = source
+		session.TotalHours = price.TotalHours
+		session.Fee = price.Total
+		session.Status = updates["status"].(string)
+		if session.Status == models.ParkingSessionStatusPaid {
+			session.PaidAt = &exitedAt
+			return nil
+		}
+
+		var err error
+		paymentInvoice, err = s.issueInvoiceTx(tx, session, slot.Parking, &slot)
+		return err
+	})
+	if err != nil {
+		s.expireInvoice(paymentInvoice)
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *ParkingSessionService) issueInvoiceTx(tx *gorm.DB, session *models.ParkingSession, parking *models.Parking, slot *models.ParkingSlot) (*paymentgateway.Invoice, error) {
+	reference := sessionReferencePrefix + pkg.RandomString(8)
+	paymentInvoice, err := s.PaymentProvider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{
+		ExternalID:         reference,
+		Amount:             session.Fee,
+		Currency:           "IDR",
+		Description:        fmt.Sprintf("Parking fee for %s", session.PlateNumber),
+		CustomerName:       "Guest",
+		SuccessRedirectURL: fmt.Sprintf("https://parkingo.agil.zip/g/%s", reference),
+		Duration:           sessionInvoiceDuration,
+		Items: []paymentgateway.InvoiceItem{
+			{
+				Name:        fmt.Sprintf("%s | %s | %s | %d hours", parking.Name, slot.Name, session.PlateNumber, session.TotalHours),
+				Price:       session.Fee,
+				Quantity:    1,
+				ReferenceID: parking.Slug,
+			},
+		},
+	})
+	if err != nil {
+		logrus.Error("Payment Error:", err)
+		return nil, err
+	}
+
+	session.PaymentReference = reference
+	session.PaymentInvoiceID = paymentInvoice.ID
+	session.PaymentLink = paymentInvoice.InvoiceURL
+	session.PaymentExpiredAt = &paymentInvoice.ExpiryDate
+	err = tx.Model(&models.ParkingSession{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+		"payment_reference":  session.PaymentReference,
+		"payment_invoice_id": session.PaymentInvoiceID,
+		"payment_link":       session.PaymentLink,
+		"payment_expired_at": session.PaymentExpiredAt,
+	}).Error
+	if err != nil {
+		return paymentInvoice, err
+	}
+
+	return paymentInvoice, nil
+}
+
+func (s *ParkingSessionService) expireInvoice(paymentInvoice *paymentgateway.Invoice) {
+	if paymentInvoice == nil {
+		return
+	}
+
+	_, err := s.PaymentProvider.ExpireInvoice(paymentInvoice.ID)
+	if err != nil {
+		logrus.Error("Failed to expire orphaned invoice: ", err)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"gorm.io/gorm"
+)
+
+// FakeProvider returns the configured provider when it is the fake one, the fake payment pages only exist then.
+func (s *PaymentService) FakeProvider() (*paymentgateway.FakeProvider, bool) {
+	fakeProvider, ok := s.PaymentProvider.(*paymentgateway.FakeProvider)
+	return fakeProvider, ok
+}
+
+func (s *PaymentService) GetFakeInvoice(invoiceID string) (*paymentgateway.Invoice, error) {
+	fakeProvider, ok := s.FakeProvider()
+	if !ok {
+		return nil, pkg.NewForbiddenError("fake payments are disabled")
+	}
+
+	paymentInvoice, err := fakeProvider.GetInvoice(invoiceID)
+	if errors.Is(err, paymentgateway.ErrInvoiceNotFound) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return paymentInvoice, err
+}
+
+// PayFakeInvoice pays a pending invoice of the fake provider and delivers its callback the way Xendit would, so
+// the invoice links of the fake provider can be followed like real ones.
+func (s *PaymentService) PayFakeInvoice(invoiceID string) (*models.PaymentEvent, error) {
+	paymentInvoice, err := s.GetFakeInvoice(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if paymentInvoice.Status != paymentgateway.InvoiceStatusPending {
+		return nil, pkg.NewConflictError("invoice is no longer pending")
+	}
+
+	fakeProvider, _ := s.FakeProvider()
+	payload, err := fakeProvider.Pay(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("X-Callback-Token", fakeProvider.CallbackToken)
+
+	return s.HandleWebhook(header, payload)
+}
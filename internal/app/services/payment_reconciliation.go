@@ -0,0 +1,224 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+)
+
+type reconciliationTarget struct {
+	kind        string
+	reference   string
+	invoiceID   string
+	localStatus string
+	expected    float64
+}
+
+// paidInFull reports whether a paid invoice covers the amount expected for it. A payment that does not is left for
+// review instead of being applied.
+func paidInFull(paymentInvoice *paymentgateway.Invoice, expected float64) bool {
+	return paymentInvoice.Amount == expected && (paymentInvoice.PaidAmount == 0 || paymentInvoice.PaidAmount >= paymentInvoice.Amount)
+}
+
+// checkPaidEvent rejects a paid webhook event that does not cover the amount expected for its reference. The event is
+// stored as FAILED and left for review instead of being applied.
+func checkPaidEvent(event *paymentgateway.WebhookEvent, expected float64) error {
+	if event.Amount == expected && (event.PaidAmount == 0 || event.PaidAmount >= event.Amount) {
+		return nil
+	}
+
+	return fmt.Errorf("payment of %s does not match, paid %.2f of %.2f expected", event.ExternalID, event.PaidAmount, expected)
+}
+
+func (s *PaymentService) GetLatestReconciliationReport() (*models.ReconciliationReport, error) {
+	var report *models.ReconciliationReport
+	err := s.DB.Order("id DESC").First(&report).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Reconcile compares the payments still waiting at our side, created within window, with their invoices at the
+// gateway. Paid and expired invoices whose callback never arrived are applied as payment events, payments whose
+// amount differs are only reported.
+func (s *PaymentService) Reconcile(window time.Duration) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		Provider:  s.PaymentProvider.Name(),
+		StartedAt: pkg.GetCurrentTime(),
+		Items:     []models.ReconciliationItem{},
+	}
+	since := report.StartedAt.Add(-window)
+
+	targets, err := s.reconciliationTargets(since)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, target := range targets {
+		report.Checked++
+
+		item := s.reconcileTarget(target)
+		if item == nil {
+			continue
+		}
+
+		switch item.Result {
+		case models.ReconciliationResultFixed:
+			report.Fixed++
+		case models.ReconciliationResultAmountMismatch:
+			report.AmountMismatches++
+		case models.ReconciliationResultUnknownInvoice:
+			report.UnknownInvoices++
+		default:
+			report.Errors++
+		}
+		report.Items = append(report.Items, *item)
+	}
+
+	report.FinishedAt = pkg.GetCurrentTime()
+	err = s.DB.Create(report).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (s *PaymentService) reconciliationTargets(since time.Time) ([]reconciliationTarget, error) {
+	var targets []reconciliationTarget
+
+	var bookings []models.Booking
+	err := s.DB.Where("status = ? AND series_id IS NULL AND created_at >= ?", models.BookingStatusUnpaid, since).Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, booking := range bookings {
+		targets = append(targets, reconciliationTarget{"BOOKING", booking.PaymentReference, booking.PaymentInvoiceID, booking.Status, booking.TotalFee + booking.ServiceFee})
+	}
+
+	var seriesList []models.BookingSeries
+	err = s.DB.Where("status = ? AND created_at >= ?", models.BookingSeriesStatusUnpaid, since).Find(&seriesList).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, series := range seriesList {
+		targets = append(targets, reconciliationTarget{"SERIES", series.PaymentReference, series.PaymentInvoiceID, series.Status, series.TotalFee + series.ServiceFee})
+	}
+
+	var overtimeBookings []models.Booking
+	err = s.DB.Where("status = ? AND updated_at >= ?", models.BookingStatusOvertimeUnpaid, since).Find(&overtimeBookings).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, booking := range overtimeBookings {
+		targets = append(targets, reconciliationTarget{"OVERTIME", booking.OvertimeReference, booking.OvertimeInvoiceID, booking.Status, booking.OvertimeFee})
+	}
+
+	var extensions []models.BookingExtension
+	err = s.DB.Where("status = ? AND created_at >= ?", models.BookingExtensionStatusUnpaid, since).Find(&extensions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, extension := range extensions {
+		targets = append(targets, reconciliationTarget{"EXTENSION", extension.PaymentReference, extension.PaymentInvoiceID, extension.Status, extension.Fee})
+	}
+
+	var subscriptionInvoices []models.SubscriptionInvoice
+	err = s.DB.Where("status = ? AND created_at >= ?", models.SubscriptionInvoiceStatusUnpaid, since).Find(&subscriptionInvoices).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, invoice := range subscriptionInvoices {
+		targets = append(targets, reconciliationTarget{"SUBSCRIPTION", invoice.PaymentReference, invoice.PaymentInvoiceID, invoice.Status, invoice.Amount})
+	}
+
+	var sessions []models.ParkingSession
+	err = s.DB.Where("status = ? AND updated_at >= ?", models.ParkingSessionStatusUnpaid, since).Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		targets = append(targets, reconciliationTarget{"SESSION", session.PaymentReference, session.PaymentInvoiceID, session.Status, session.Fee})
+	}
+
+	return targets, nil
+}
+
+// reconcileTarget returns nil when the local payment and the gateway invoice agree.
+func (s *PaymentService) reconcileTarget(target reconciliationTarget) *models.ReconciliationItem {
+	item := &models.ReconciliationItem{
+		Kind:           target.kind,
+		Reference:      target.reference,
+		InvoiceID:      target.invoiceID,
+		LocalStatus:    target.localStatus,
+		ExpectedAmount: target.expected,
+	}
+
+	if target.invoiceID == "" {
+		item.Result = models.ReconciliationResultUnknownInvoice
+		item.Message = "no invoice was recorded"
+		return item
+	}
+
+	paymentInvoice, err := s.PaymentProvider.GetInvoice(target.invoiceID)
+	if err != nil {
+		item.Result = models.ReconciliationResultError
+		if errors.Is(err, paymentgateway.ErrInvoiceNotFound) {
+			item.Result = models.ReconciliationResultUnknownInvoice
+		}
+		item.Message = err.Error()
+		return item
+	}
+
+	item.GatewayStatus = paymentInvoice.Status
+	item.GatewayAmount = paymentInvoice.Amount
+
+	if paymentInvoice.ExternalID != target.reference {
+		item.Result = models.ReconciliationResultUnknownInvoice
+		item.Message = fmt.Sprintf("invoice belongs to %s", paymentInvoice.ExternalID)
+		return item
+	}
+
+	switch {
+	case paymentInvoice.IsPaid():
+		if !paidInFull(paymentInvoice, target.expected) {
+			item.Result = models.ReconciliationResultAmountMismatch
+			item.Message = fmt.Sprintf("paid %.2f of %.2f", paymentInvoice.PaidAmount, paymentInvoice.Amount)
+			return item
+		}
+	case paymentInvoice.Status == paymentgateway.InvoiceStatusExpired:
+		// An expired overtime invoice is issued again at checkout, an expired walk-in invoice by the owner
+		if target.kind == "OVERTIME" || target.kind == "SESSION" {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	payload, _ := json.Marshal(paymentInvoice)
+	paymentEvent, err := s.ingest(&paymentgateway.WebhookEvent{
+		ID:         fmt.Sprintf("%s:%s", paymentInvoice.ID, paymentInvoice.Status),
+		InvoiceID:  paymentInvoice.ID,
+		ExternalID: paymentInvoice.ExternalID,
+		Status:     paymentInvoice.Status,
+		Amount:     paymentInvoice.Amount,
+		PaidAmount: paymentInvoice.PaidAmount,
+		Payload:    payload,
+	}, models.PaymentEventSourceReconciliation)
+	if err != nil {
+		item.Result = models.ReconciliationResultError
+		item.Message = err.Error()
+		return item
+	}
+
+	item.Result = models.ReconciliationResultFixed
+	item.Message = fmt.Sprintf("applied payment event %d", paymentEvent.ID)
+	return item
+}
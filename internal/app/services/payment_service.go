@@ -0,0 +1,207 @@
+package services
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// paymentEventProcessingTimeout is how long an event can be processing before it is assumed to have been abandoned,
+// e.g. by a crash, and may be processed again
+const paymentEventProcessingTimeout = 5 * time.Minute
+
+// PaymentService records every verified payment gateway callback before applying it, so repeated deliveries are
+// only processed once and stored events can be inspected and replayed.
+type PaymentService struct {
+	DB                  *gorm.DB
+	PaymentProvider     paymentgateway.PaymentProvider
+	BookingService      *BookingService
+	SubscriptionService *SubscriptionService
+	SessionService      *ParkingSessionService
+}
+
+func NewPaymentService(db *gorm.DB, paymentProvider paymentgateway.PaymentProvider, bookingService *BookingService, subscriptionService *SubscriptionService, sessionService *ParkingSessionService) *PaymentService {
+	return &PaymentService{
+		DB:                  db,
+		PaymentProvider:     paymentProvider,
+		BookingService:      bookingService,
+		SubscriptionService: subscriptionService,
+		SessionService:      sessionService,
+	}
+}
+
+func (s *PaymentService) GetPaymentEvents(filter *models.PaymentEventFilter) ([]*models.PaymentEvent, error) {
+	var paymentEvents []*models.PaymentEvent
+	query := s.DB.Order("created_at DESC")
+
+	if filter != nil {
+		if filter.ProcessingStatus != "" {
+			query = query.Where("processing_status = ?", filter.ProcessingStatus)
+		}
+		if filter.InvoiceID != "" {
+			query = query.Where("invoice_id = ?", filter.InvoiceID)
+		}
+		if filter.ExternalID != "" {
+			query = query.Where("external_id = ?", filter.ExternalID)
+		}
+		if filter.Limit != 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Page != 0 {
+			query = query.Offset((filter.Page - 1) * filter.Limit)
+		}
+	}
+
+	err := query.Find(&paymentEvents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return paymentEvents, nil
+}
+
+func (s *PaymentService) GetPaymentEventByID(id int) (*models.PaymentEvent, error) {
+	var paymentEvent *models.PaymentEvent
+	err := s.DB.First(&paymentEvent, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return paymentEvent, nil
+}
+
+// HandleWebhook verifies and stores a gateway callback, then processes it unless an earlier delivery of the same
+// event already was.
+func (s *PaymentService) HandleWebhook(header http.Header, body []byte) (*models.PaymentEvent, error) {
+	event, err := s.PaymentProvider.ParseWebhook(header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ingest(event, models.PaymentEventSourceWebhook)
+}
+
+func (s *PaymentService) ingest(event *paymentgateway.WebhookEvent, source string) (*models.PaymentEvent, error) {
+	now := pkg.GetCurrentTime()
+	paymentEvent := &models.PaymentEvent{
+		EventID:          event.ID,
+		Provider:         s.PaymentProvider.Name(),
+		Source:           source,
+		InvoiceID:        event.InvoiceID,
+		ExternalID:       event.ExternalID,
+		Status:           event.Status,
+		Amount:           event.Amount,
+		PaidAmount:       event.PaidAmount,
+		Payload:          event.Payload,
+		ProcessingStatus: models.PaymentEventStatusReceived,
+		DeliveryCount:    1,
+		LastDeliveredAt:  now,
+	}
+
+	result := s.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "event_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"delivery_count":    gorm.Expr("payment_events.delivery_count + 1"),
+			"last_delivered_at": now,
+		}),
+	}).Create(paymentEvent)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	err := s.DB.Where("event_id = ?", event.ID).First(paymentEvent).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s.process(paymentEvent, []string{models.PaymentEventStatusReceived, models.PaymentEventStatusFailed})
+}
+
+// ReplayPaymentEvent processes a stored event again. Applying an event is idempotent, so replaying one that was
+// already processed does not count the payment twice. An event still processing can only be replayed once it
+// timed out.
+func (s *PaymentService) ReplayPaymentEvent(id int) (*models.PaymentEvent, error) {
+	paymentEvent, err := s.GetPaymentEventByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.process(paymentEvent, []string{models.PaymentEventStatusReceived, models.PaymentEventStatusFailed, models.PaymentEventStatusProcessed})
+}
+
+func (s *PaymentService) process(paymentEvent *models.PaymentEvent, from []string) (*models.PaymentEvent, error) {
+	now := pkg.GetCurrentTime()
+	result := s.DB.Model(&models.PaymentEvent{}).
+		Where("id = ?", paymentEvent.ID).
+		Where(s.DB.Where("processing_status IN ?", from).
+			Or("processing_status = ? AND updated_at < ?", models.PaymentEventStatusProcessing, now.Add(-paymentEventProcessingTimeout))).
+		Updates(map[string]interface{}{
+			"processing_status": models.PaymentEventStatusProcessing,
+			"attempts":          gorm.Expr("attempts + 1"),
+			"updated_at":        now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	// Processed already, or being processed by a concurrent delivery that has not timed out
+	if result.RowsAffected == 0 {
+		logrus.Infof("Skipping duplicate payment event %s", paymentEvent.EventID)
+		return paymentEvent, nil
+	}
+
+	event := &paymentgateway.WebhookEvent{
+		ID:         paymentEvent.EventID,
+		InvoiceID:  paymentEvent.InvoiceID,
+		ExternalID: paymentEvent.ExternalID,
+		Status:     paymentEvent.Status,
+		Amount:     paymentEvent.Amount,
+		PaidAmount: paymentEvent.PaidAmount,
+		Payload:    paymentEvent.Payload,
+	}
+
+	var booking *models.Booking
+	var processErr error
+	switch {
+	case strings.HasPrefix(event.ExternalID, subscriptionReferencePrefix):
+		processErr = s.SubscriptionService.ProcessPaymentEvent(event)
+	case strings.HasPrefix(event.ExternalID, sessionReferencePrefix):
+		processErr = s.SessionService.ProcessPaymentEvent(event)
+	default:
+		booking, processErr = s.BookingService.ProcessPaymentEvent(event, paymentEvent.Source)
+	}
+
+	updates := map[string]interface{}{
+		"processing_status": models.PaymentEventStatusProcessed,
+		"error":             "",
+		"processed_at":      pkg.GetCurrentTime(),
+		"updated_at":        pkg.GetCurrentTime(),
+	}
+	if processErr != nil {
+		updates["processing_status"] = models.PaymentEventStatusFailed
+		updates["error"] = processErr.Error()
+		updates["processed_at"] = nil
+	}
+	if booking != nil {
+		updates["booking_id"] = booking.ID
+	}
+
+	err := s.DB.Model(paymentEvent).Updates(updates).Error
+	if err != nil {
+		logrus.Errorf("Failed to update payment event %s: %v", paymentEvent.EventID, err)
+	}
+
+	err = s.DB.First(paymentEvent, paymentEvent.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return paymentEvent, processErr
+}
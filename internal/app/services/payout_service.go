@@ -0,0 +1,472 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/disbursement"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const payoutReferencePrefix = "PKGO-PO-"
+
+// payoutRetryDelay is how long an approved payout is left to its approval before SyncPayouts sends it again
+const payoutRetryDelay = time.Minute
+
+var ErrInsufficientEarnings = errors.New("amount exceeds the available earnings of the parking")
+
+// PayoutService pays the available earnings of parkings out to their owners. Owners request a payout to one
+// of their bank accounts, and an admin approves it before it is sent through the disbursement provider.
+type PayoutService struct {
+	DB                   *gorm.DB
+	Validate             *validator.Validate
+	DisbursementProvider disbursement.DisbursementProvider
+	Ledger               *LedgerService
+	MailService          *MailService
+}
+
+func NewPayoutService(db *gorm.DB, validate *validator.Validate, disbursementProvider disbursement.DisbursementProvider, ledger *LedgerService, mailService *MailService) *PayoutService {
+	return &PayoutService{
+		DB:                   db,
+		Validate:             validate,
+		DisbursementProvider: disbursementProvider,
+		Ledger:               ledger,
+		MailService:          mailService,
+	}
+}
+
+func (s *PayoutService) GetBankAccounts(userID int) ([]*models.BankAccount, error) {
+	var bankAccounts []*models.BankAccount
+	err := s.DB.Where("user_id = ?", userID).Order("id DESC").Find(&bankAccounts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return bankAccounts, nil
+}
+
+func (s *PayoutService) CreateBankAccount(userID int, req *models.CreateBankAccountRequest) (*models.BankAccount, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	bankAccount := &models.BankAccount{
+		UserID:            userID,
+		BankCode:          req.BankCode,
+		AccountNumber:     req.AccountNumber,
+		AccountHolderName: req.AccountHolderName,
+	}
+
+	err = s.DB.Create(bankAccount).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return bankAccount, nil
+}
+
+func (s *PayoutService) DeleteBankAccount(userID int, id int) error {
+	result := s.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.BankAccount{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (s *PayoutService) GetPayouts(filter *models.PayoutFilter) ([]*models.Payout, error) {
+	var payouts []*models.Payout
+	query := s.DB.Preload("BankAccount", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Order("id DESC")
+
+	if filter != nil {
+		if filter.ParkingID != 0 {
+			query = query.Where("parking_id = ?", filter.ParkingID)
+		}
+		if filter.UserID != 0 {
+			query = query.Where("user_id = ?", filter.UserID)
+		}
+		if filter.Status != "" {
+			query = query.Where("status = ?", filter.Status)
+		}
+		if filter.Limit != 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Page != 0 {
+			query = query.Offset((filter.Page - 1) * filter.Limit)
+		}
+	}
+
+	err := query.Find(&payouts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return payouts, nil
+}
+
+func (s *PayoutService) GetPayoutByID(id int) (*models.Payout, error) {
+	var payout *models.Payout
+	err := s.DB.Preload("Parking").Preload("Parking.Author").Preload("BankAccount", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Preload("Histories", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&payout, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+// RequestPayout asks for a withdrawal from a parking of the user. Amounts of payouts still waiting for review
+// count as spent, so owners cannot request more than they have in total.
+func (s *PayoutService) RequestPayout(user *models.User, parkingID int, req *models.CreatePayoutRequest) (*models.Payout, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var bankAccount models.BankAccount
+	err = s.DB.Where("id = ? AND user_id = ?", req.BankAccountID, user.ID).First(&bankAccount).Error
+	if err != nil {
+		return nil, err
+	}
+
+	payout := &models.Payout{
+		ParkingID:     parkingID,
+		UserID:        user.ID,
+		BankAccountID: bankAccount.ID,
+		Reference:     payoutReferencePrefix + pkg.RandomString(8),
+		Amount:        req.Amount,
+		Status:        models.PayoutStatusRequested,
+		Provider:      s.DisbursementProvider.Name(),
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var parking models.Parking
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parking, parkingID).Error
+		if err != nil {
+			return err
+		}
+
+		if parking.AuthorID != user.ID {
+			return pkg.NewForbiddenError("You are not allowed to withdraw from this parking")
+		}
+
+		var requested float64
+		err = tx.Model(&models.Payout{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("parking_id = ? AND status = ?", parkingID, models.PayoutStatusRequested).
+			Scan(&requested).Error
+		if err != nil {
+			return err
+		}
+
+		balance, err := models.LoadParkingBalance(tx, parkingID)
+		if err != nil {
+			return err
+		}
+
+		if req.Amount > balance.AvailableEarnings-requested {
+			return ErrInsufficientEarnings
+		}
+
+		err = tx.Create(payout).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PayoutStatusHistory{
+			PayoutID: payout.ID,
+			ToStatus: payout.Status,
+			ActorID:  &user.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPayoutByID(payout.ID)
+}
+
+// ApprovePayout withdraws the amount from the available earnings of the parking and sends it through the
+// disbursement provider.
+func (s *PayoutService) ApprovePayout(adminID int, id int, req *models.ReviewPayoutRequest) (*models.Payout, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payout, err := s.GetPayoutByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var parking models.Parking
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parking, payout.ParkingID).Error
+		if err != nil {
+			return err
+		}
+
+		err = s.transitionTx(tx, payout, models.PayoutStatusApproved, &adminID, req.Note, map[string]interface{}{
+			"reviewed_by": adminID,
+			"reviewed_at": pkg.GetCurrentTime(),
+		})
+		if err != nil {
+			return err
+		}
+
+		balance, err := models.LoadParkingBalance(tx, payout.ParkingID)
+		if err != nil {
+			return err
+		}
+
+		if payout.Amount > balance.AvailableEarnings {
+			return ErrInsufficientEarnings
+		}
+
+		return s.Ledger.RecordPayoutTx(tx, payout)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The amount is already withdrawn, a payout that could not be sent now stays approved for SyncPayouts
+	err = s.executePayout(payout)
+	if err != nil {
+		logrus.Errorf("Failed to execute payout %s: %v", payout.Reference, err)
+	}
+
+	return s.GetPayoutByID(payout.ID)
+}
+
+func (s *PayoutService) RejectPayout(adminID int, id int, req *models.ReviewPayoutRequest) (*models.Payout, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payout, err := s.GetPayoutByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.transitionTx(tx, payout, models.PayoutStatusRejected, &adminID, req.Note, map[string]interface{}{
+			"note":        req.Note,
+			"reviewed_by": adminID,
+			"reviewed_at": pkg.GetCurrentTime(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	payout.Note = req.Note
+
+	s.notify(payout)
+
+	return s.GetPayoutByID(payout.ID)
+}
+
+// SyncPayouts sends the approved payouts that never reached the disbursement provider again, and completes or
+// fails the payouts still being transferred by it.
+func (s *PayoutService) SyncPayouts() (int, error) {
+	var approved []*models.Payout
+	err := s.DB.Preload("Parking").Preload("Parking.Author").Preload("BankAccount", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Where("status = ? AND updated_at < ?", models.PayoutStatusApproved, pkg.GetCurrentTime().Add(-payoutRetryDelay)).Find(&approved).Error
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, payout := range approved {
+		err = s.executePayout(payout)
+		if err != nil {
+			logrus.Errorf("Failed to execute payout %s: %v", payout.Reference, err)
+			continue
+		}
+
+		if payout.Status != models.PayoutStatusApproved && payout.Status != models.PayoutStatusProcessing {
+			settled++
+		}
+	}
+
+	var payouts []*models.Payout
+	err = s.DB.Preload("Parking").Preload("Parking.Author").Where("status = ?", models.PayoutStatusProcessing).Find(&payouts).Error
+	if err != nil {
+		return settled, err
+	}
+
+	for _, payout := range payouts {
+		providerPayout, err := s.DisbursementProvider.GetPayout(payout.ProviderPayoutID)
+		if err != nil {
+			logrus.Errorf("Failed to get payout %s: %v", payout.Reference, err)
+			continue
+		}
+
+		err = s.settlePayout(payout, providerPayout)
+		if err != nil {
+			logrus.Errorf("Failed to settle payout %s: %v", payout.Reference, err)
+			continue
+		}
+
+		if payout.Status != models.PayoutStatusProcessing {
+			settled++
+		}
+	}
+
+	return settled, nil
+}
+
+// executePayout sends an approved payout to the disbursement provider. The reference is used as idempotency
+// key, so executing a payout again does not transfer it twice.
+func (s *PayoutService) executePayout(payout *models.Payout) error {
+	providerPayout, err := s.DisbursementProvider.CreatePayout(&disbursement.CreatePayoutRequest{
+		ReferenceID:       payout.Reference,
+		ChannelCode:       payout.BankAccount.BankCode,
+		AccountNumber:     payout.BankAccount.AccountNumber,
+		AccountHolderName: payout.BankAccount.AccountHolderName,
+		Amount:            payout.Amount,
+		Currency:          "IDR",
+		Description:       fmt.Sprintf("ParkinGo payout %s", payout.Reference),
+	})
+	if err != nil {
+		logrus.Error("Disbursement Error:", err)
+		// Only a refused payout is returned to the earnings, any other error may still have created it
+		if disbursement.IsRejected(err) {
+			return s.failPayout(payout, err.Error())
+		}
+		return err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.transitionTx(tx, payout, models.PayoutStatusProcessing, nil, "", map[string]interface{}{
+			"provider_payout_id": providerPayout.ID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	payout.ProviderPayoutID = providerPayout.ID
+
+	return s.settlePayout(payout, providerPayout)
+}
+
+func (s *PayoutService) settlePayout(payout *models.Payout, providerPayout *disbursement.Payout) error {
+	switch {
+	case providerPayout.IsSucceeded():
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			return s.transitionTx(tx, payout, models.PayoutStatusCompleted, nil, "", map[string]interface{}{
+				"completed_at": pkg.GetCurrentTime(),
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		s.notify(payout)
+	case providerPayout.IsFailed():
+		return s.failPayout(payout, providerPayout.FailureCode)
+	}
+
+	return nil
+}
+
+// failPayout returns the amount of a payout the provider could not transfer to the available earnings.
+func (s *PayoutService) failPayout(payout *models.Payout, reason string) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		err := s.transitionTx(tx, payout, models.PayoutStatusFailed, nil, reason, map[string]interface{}{
+			"note": reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.Ledger.RecordPayoutReversalTx(tx, payout)
+	})
+	if err != nil {
+		return err
+	}
+	payout.Note = reason
+
+	s.notify(payout)
+
+	return nil
+}
+
+// transitionTx moves the payout to the given status within tx and records the change in its history.
+func (s *PayoutService) transitionTx(tx *gorm.DB, payout *models.Payout, to string, actorID *int, note string, updates map[string]interface{}) error {
+	from := payout.Status
+	if !models.CanTransitionPayout(from, to) {
+		return &models.PayoutTransitionError{Reference: payout.Reference, From: from, To: to}
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to
+	updates["updated_at"] = pkg.GetCurrentTime()
+
+	result := tx.Model(&models.Payout{}).Where("id = ? AND status = ?", payout.ID, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Someone else changed the payout since it was loaded
+	if result.RowsAffected == 0 {
+		var current models.Payout
+		err := tx.Select("status").First(&current, payout.ID).Error
+		if err != nil {
+			return err
+		}
+		return &models.PayoutTransitionError{Reference: payout.Reference, From: current.Status, To: to}
+	}
+	payout.Status = to
+
+	history := &models.PayoutStatusHistory{
+		PayoutID:   payout.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Note:       note,
+	}
+	return tx.Create(history).Error
+}
+
+func (s *PayoutService) notify(payout *models.Payout) {
+	if payout.Parking == nil || payout.Parking.Author == nil {
+		return
+	}
+
+	var subject, content string
+	switch payout.Status {
+	case models.PayoutStatusRejected:
+		subject = fmt.Sprintf("Payout Rejected %s", payout.Reference)
+		content = fmt.Sprintf("Your payout of IDR %.0f from %s has been rejected. %s", payout.Amount, payout.Parking.Name, payout.Note)
+	case models.PayoutStatusCompleted:
+		subject = fmt.Sprintf("Payout Completed %s", payout.Reference)
+		content = fmt.Sprintf("Your payout of IDR %.0f from %s has been transferred to your bank account.", payout.Amount, payout.Parking.Name)
+	case models.PayoutStatusFailed:
+		subject = fmt.Sprintf("Payout Failed %s", payout.Reference)
+		content = fmt.Sprintf("Your payout of IDR %.0f from %s could not be transferred and was returned to your available earnings.", payout.Amount, payout.Parking.Name)
+	default:
+		return
+	}
+
+	go s.MailService.SendMail(payout.Parking.Author.Email, subject, content)
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type PricingService struct {
+	DB       *gorm.DB
+	Validate *validator.Validate
+}
+
+func NewPricingService(db *gorm.DB, validate *validator.Validate) *PricingService {
+	return &PricingService{
+		DB:       db,
+		Validate: validate,
+	}
+}
+
+func (s *PricingService) GetPricingRules(parkingID int) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	err := s.DB.Where("parking_id = ?", parkingID).Order("priority DESC, id ASC").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (s *PricingService) GetPricingRuleByID(parkingID int, id int) (*models.PricingRule, error) {
+	var rule *models.PricingRule
+	err := s.DB.Where("parking_id = ?", parkingID).First(&rule, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (s *PricingService) CreatePricingRule(parkingID int, req *models.CreatePricingRuleRequest) (*models.PricingRule, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var parking models.Parking
+	err = s.DB.First(&parking, parkingID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SlotID != nil {
+		var slot models.ParkingSlot
+		err = s.DB.Where("parking_id = ?", parkingID).First(&slot, *req.SlotID).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	rule := models.PricingRule{
+		ParkingID:  parkingID,
+		SlotID:     req.SlotID,
+		Name:       req.Name,
+		Type:       req.Type,
+		DaysOfWeek: req.DaysOfWeek,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+		StartsAt:   req.StartsAt,
+		EndsAt:     req.EndsAt,
+		Rate:       req.Rate,
+		MaxFee:     req.MaxFee,
+		Priority:   req.Priority,
+		IsActive:   isActive,
+	}
+
+	err = validatePricingRule(&rule)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Create(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (s *PricingService) UpdatePricingRule(parkingID int, id int, req *models.UpdatePricingRuleRequest) (*models.PricingRule, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := s.GetPricingRuleByID(parkingID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	if req.DaysOfWeek != nil {
+		rule.DaysOfWeek = req.DaysOfWeek
+	}
+	if req.StartTime != "" {
+		rule.StartTime = req.StartTime
+	}
+	if req.EndTime != "" {
+		rule.EndTime = req.EndTime
+	}
+	if req.StartsAt != nil {
+		rule.StartsAt = req.StartsAt
+	}
+	if req.EndsAt != nil {
+		rule.EndsAt = req.EndsAt
+	}
+	if req.Rate != nil {
+		rule.Rate = *req.Rate
+	}
+	if req.MaxFee != nil {
+		rule.MaxFee = *req.MaxFee
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+
+	err = validatePricingRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Save(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (s *PricingService) DeletePricingRule(parkingID int, id int) error {
+	rule, err := s.GetPricingRuleByID(parkingID, id)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.Delete(&rule).Error
+}
+
+// QuoteSlotPrice prices a booking of the slot between startAt and endAt using the active rules of its parking.
+func (s *PricingService) QuoteSlotPrice(slot *models.ParkingSlot, startAt time.Time, endAt time.Time) (*models.PriceBreakdown, error) {
+	var rules []models.PricingRule
+	err := s.DB.Where("parking_id = ? AND is_active = ? AND (slot_id IS NULL OR slot_id = ?)", slot.ParkingID, true, slot.ID).Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return CalculatePrice(slot.Fee, rules, startAt, endAt), nil
+}
+
+// CalculatePrice charges every started booked hour at the rate of the most specific matching rule,
+// falling back to baseRate. Precedence is EVENT, FIRST_HOUR, TIME_BAND, DAY_RATE, then higher Priority
+// and slot specific rules first. DAILY_CAP rules are applied afterwards as adjustments per calendar day.
+func CalculatePrice(baseRate float64, rules []models.PricingRule, startAt time.Time, endAt time.Time) *models.PriceBreakdown {
+	loc := pkg.GetCurrentTime().Location()
+
+	sorted := slices.Clone(rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].SlotID != nil && sorted[j].SlotID == nil
+	})
+
+	breakdown := &models.PriceBreakdown{
+		TotalHours:  max(int((endAt.Sub(startAt)+time.Hour-1)/time.Hour), 0),
+		Items:       []models.PriceLineItem{},
+		Adjustments: []models.PriceAdjustment{},
+	}
+
+	var days []time.Time
+	dailyTotals := make(map[string]float64)
+	for i := 0; i < breakdown.TotalHours; i++ {
+		hourStart := startAt.Add(time.Duration(i) * time.Hour).In(loc)
+		name, rate := hourlyRate(baseRate, sorted, hourStart, i == 0)
+
+		last := len(breakdown.Items) - 1
+		if last >= 0 && breakdown.Items[last].Name == name && breakdown.Items[last].Rate == rate {
+			breakdown.Items[last].Quantity++
+			breakdown.Items[last].Amount += rate
+		} else {
+			breakdown.Items = append(breakdown.Items, models.PriceLineItem{
+				Name:     name,
+				StartAt:  hourStart,
+				Rate:     rate,
+				Quantity: 1,
+				Amount:   rate,
+			})
+		}
+
+		day := hourStart.Format(time.DateOnly)
+		if _, ok := dailyTotals[day]; !ok {
+			days = append(days, hourStart)
+		}
+		dailyTotals[day] += rate
+		breakdown.Subtotal += rate
+	}
+
+	for _, day := range days {
+		dayTotal := dailyTotals[day.Format(time.DateOnly)]
+		for _, rule := range sorted {
+			if rule.Type != models.PricingRuleDailyCap || !matchesDay(rule, day) {
+				continue
+			}
+
+			if dayTotal > rule.MaxFee {
+				breakdown.Adjustments = append(breakdown.Adjustments, models.PriceAdjustment{
+					Name:   fmt.Sprintf("%s (%s)", rule.Name, day.Format(time.DateOnly)),
+					Amount: rule.MaxFee - dayTotal,
+				})
+				breakdown.Discount += dayTotal - rule.MaxFee
+			}
+			break
+		}
+	}
+
+	breakdown.Total = breakdown.Subtotal - breakdown.Discount
+
+	return breakdown
+}
+
+var pricingRulePrecedence = []string{
+	models.PricingRuleEvent,
+	models.PricingRuleFirstHour,
+	models.PricingRuleTimeBand,
+	models.PricingRuleDayRate,
+}
+
+func hourlyRate(baseRate float64, rules []models.PricingRule, hourStart time.Time, isFirstHour bool) (string, float64) {
+	for _, ruleType := range pricingRulePrecedence {
+		for _, rule := range rules {
+			if rule.Type != ruleType {
+				continue
+			}
+
+			matched := false
+			switch rule.Type {
+			case models.PricingRuleEvent:
+				matched = rule.StartsAt != nil && rule.EndsAt != nil && !hourStart.Before(*rule.StartsAt) && hourStart.Before(*rule.EndsAt)
+			case models.PricingRuleFirstHour:
+				matched = isFirstHour && matchesDay(rule, hourStart)
+			case models.PricingRuleTimeBand:
+				matched = matchesDay(rule, hourStart) && matchesTimeBand(rule, hourStart)
+			case models.PricingRuleDayRate:
+				matched = matchesDay(rule, hourStart)
+			}
+
+			if matched {
+				return rule.Name, rule.Rate
+			}
+		}
+	}
+
+	return "Standard rate", baseRate
+}
+
+func matchesDay(rule models.PricingRule, t time.Time) bool {
+	return len(rule.DaysOfWeek) == 0 || slices.Contains(rule.DaysOfWeek, int(t.Weekday()))
+}
+
+// matchesTimeBand reports whether t falls in [StartTime, EndTime), bands ending before they start wrap past midnight.
+func matchesTimeBand(rule models.PricingRule, t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	start := clockMinutes(rule.StartTime)
+	end := clockMinutes(rule.EndTime)
+
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+func clockMinutes(clock string) int {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+func validatePricingRule(rule *models.PricingRule) error {
+	switch rule.Type {
+	case models.PricingRuleTimeBand:
+		if rule.StartTime == "" || rule.EndTime == "" {
+			return errors.New("time band rule requires start_time and end_time")
+		}
+		if rule.StartTime == rule.EndTime {
+			return errors.New("start_time and end_time must be different")
+		}
+	case models.PricingRuleDayRate:
+		if len(rule.DaysOfWeek) == 0 {
+			return errors.New("day rate rule requires days_of_week")
+		}
+	case models.PricingRuleDailyCap:
+		if rule.MaxFee <= 0 {
+			return errors.New("daily cap rule requires max_fee")
+		}
+	case models.PricingRuleEvent:
+		if rule.StartsAt == nil || rule.EndsAt == nil || !rule.EndsAt.After(*rule.StartsAt) {
+			return errors.New("event rule requires starts_at before ends_at")
+		}
+	}
+
+	return nil
+}
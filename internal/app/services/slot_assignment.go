@@ -0,0 +1,233 @@
+package services
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"gorm.io/gorm"
+)
+
+var ErrNoSlotAvailable = pkg.NewConflictError("no slot is free for the requested time")
+
+// slotUsageDays is how far back bookings count toward the wear of a slot
+const slotUsageDays = 30
+
+// SlotCandidate is a free slot considered for a booking made without one.
+type SlotCandidate struct {
+	Slot *models.ParkingSlot
+	// Distance is the number of layout cells between the slot and the nearest entrance
+	Distance int
+	// Usage is the number of bookings the slot had recently
+	Usage int
+	// ExtraAttributes is the number of slot attributes the booking did not ask for
+	ExtraAttributes int
+}
+
+// SlotAssignmentStrategy orders free slots, the first one is assigned to the booking.
+type SlotAssignmentStrategy interface {
+	Name() string
+	Less(a *SlotCandidate, b *SlotCandidate) bool
+}
+
+type closestSlotStrategy struct{}
+
+func (closestSlotStrategy) Name() string {
+	return models.SlotAssignmentClosest
+}
+
+func (closestSlotStrategy) Less(a *SlotCandidate, b *SlotCandidate) bool {
+	return a.Distance < b.Distance
+}
+
+type balancedSlotStrategy struct{}
+
+func (balancedSlotStrategy) Name() string {
+	return models.SlotAssignmentBalanced
+}
+
+func (balancedSlotStrategy) Less(a *SlotCandidate, b *SlotCandidate) bool {
+	if a.Usage != b.Usage {
+		return a.Usage < b.Usage
+	}
+	return a.Distance < b.Distance
+}
+
+type bestFitSlotStrategy struct{}
+
+func (bestFitSlotStrategy) Name() string {
+	return models.SlotAssignmentBestFit
+}
+
+func (bestFitSlotStrategy) Less(a *SlotCandidate, b *SlotCandidate) bool {
+	if a.ExtraAttributes != b.ExtraAttributes {
+		return a.ExtraAttributes < b.ExtraAttributes
+	}
+	return a.Distance < b.Distance
+}
+
+// NewSlotAssignmentStrategy returns the strategy of a parking, parkings without one assign the closest slot.
+func NewSlotAssignmentStrategy(name string) SlotAssignmentStrategy {
+	switch strings.ToUpper(name) {
+	case models.SlotAssignmentBalanced:
+		return balancedSlotStrategy{}
+	case models.SlotAssignmentBestFit:
+		return bestFitSlotStrategy{}
+	default:
+		return closestSlotStrategy{}
+	}
+}
+
+// RankSlots sorts the candidates by the strategy, ties go to the slot name so the order is stable.
+func RankSlots(candidates []*SlotCandidate, strategy SlotAssignmentStrategy) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if strategy.Less(a, b) {
+			return true
+		}
+		if strategy.Less(b, a) {
+			return false
+		}
+		return a.Slot.Name < b.Slot.Name
+	})
+}
+
+// LayoutEntrances returns the row and column of every "E" cell of a parking layout. A layout without one is
+// entered from its top left corner.
+func LayoutEntrances(layout []byte) [][2]int {
+	var grid [][]string
+	_ = json.Unmarshal(layout, &grid)
+
+	var entrances [][2]int
+	for rowIndex, row := range grid {
+		for colIndex, val := range row {
+			if strings.EqualFold(val, "E") {
+				entrances = append(entrances, [2]int{rowIndex, colIndex})
+			}
+		}
+	}
+	if len(entrances) == 0 {
+		entrances = append(entrances, [2]int{0, 0})
+	}
+
+	return entrances
+}
+
+// NewSlotCandidate describes a slot for ranking, it returns nil when the slot lacks a required attribute.
+func NewSlotCandidate(slot *models.ParkingSlot, entrances [][2]int, required []string, usage int) *SlotCandidate {
+	attributes := normalizeSlotAttributes(slot.Attributes)
+	required = normalizeSlotAttributes(required)
+	for _, attribute := range required {
+		if !slices.Contains(attributes, attribute) {
+			return nil
+		}
+	}
+
+	distance := -1
+	for _, entrance := range entrances {
+		d := abs(slot.Row-entrance[0]) + abs(slot.Col-entrance[1])
+		if distance < 0 || d < distance {
+			distance = d
+		}
+	}
+
+	return &SlotCandidate{
+		Slot:            slot,
+		Distance:        distance,
+		Usage:           usage,
+		ExtraAttributes: len(attributes) - len(required),
+	}
+}
+
+// findSlotCandidates returns the slots of a parking free within [startAt, endAt) that have every required
+// attribute, best first.
+func findSlotCandidates(db *gorm.DB, parkingID int, startAt time.Time, endAt time.Time, required []string) ([]*SlotCandidate, error) {
+	var parking models.Parking
+	err := db.First(&parking, parkingID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []*models.ParkingSlot
+	err = db.Where("parking_id = ?", parkingID).Find(&slots).Error
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := findSlotConflicts(db, slotConflictFilter{ParkingID: parkingID}, startAt, endAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []struct {
+		SlotID int
+		Total  int
+	}
+	err = db.Model(&models.Booking{}).
+		Select("slot_id, COUNT(*) AS total").
+		Where("parking_id = ? AND start_at >= ? AND status IN ?", parkingID, pkg.GetCurrentTime().AddDate(0, 0, -slotUsageDays), []string{models.BookingStatusPaid, models.BookingStatusCompleted, models.BookingStatusOvertimeUnpaid}).
+		Group("slot_id").
+		Scan(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[int]int, len(usages))
+	for _, u := range usages {
+		usage[u.SlotID] = u.Total
+	}
+
+	entrances := LayoutEntrances(parking.Layout)
+	var candidates []*SlotCandidate
+	for _, slot := range slots {
+		if len(conflicts[slot.ID]) > 0 {
+			continue
+		}
+		candidate := NewSlotCandidate(slot, entrances, required, usage[slot.ID])
+		if candidate != nil {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	RankSlots(candidates, NewSlotAssignmentStrategy(parking.SlotAssignment))
+
+	return candidates, nil
+}
+
+// assignSlot picks the best free slot of a parking for a booking made without one, passing over the excluded slots
+// a concurrent booking already took.
+func assignSlot(db *gorm.DB, parkingID int, startAt time.Time, endAt time.Time, required []string, exclude []int) (*models.ParkingSlot, error) {
+	candidates, err := findSlotCandidates(db, parkingID, startAt, endAt, required)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		if !slices.Contains(exclude, candidate.Slot.ID) {
+			return candidate.Slot, nil
+		}
+	}
+
+	return nil, ErrNoSlotAvailable
+}
+
+func normalizeSlotAttributes(attributes []string) []string {
+	normalized := make([]string, 0, len(attributes))
+	for _, attribute := range attributes {
+		attribute = strings.ToUpper(strings.TrimSpace(attribute))
+		if attribute != "" && !slices.Contains(normalized, attribute) {
+			normalized = append(normalized, attribute)
+		}
+	}
+	return normalized
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSlotHeld = pkg.NewConflictError("slot is held by another checkout")
+
+const (
+	// slotHoldTTL is how long a slot stays held for a checkout
+	slotHoldTTL = 5 * time.Minute
+	// maxSlotHoldsPerUser is how many slots a user can hold at the same time
+	maxSlotHoldsPerUser = 2
+)
+
+// activeSlotHolds matches holds still holding their slot for a window intersecting [startAt, endAt).
+func activeSlotHolds(startAt time.Time, endAt time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("slot_holds.status = ? AND slot_holds.expires_at > ?", models.SlotHoldStatusActive, pkg.GetCurrentTime()).
+			Where("slot_holds.start_at < ? AND slot_holds.end_at > ?", endAt, startAt)
+	}
+}
+
+// HoldSlot keeps a slot free for the user while they check out. The token of the hold is passed to
+// CreateBooking, which consumes it.
+func (s *BookingService) HoldSlot(userID int, parkingID int, slotID int, req *models.CreateSlotHoldRequest) (*models.SlotHold, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := pkg.GetCurrentTime()
+	if req.StartAt.Before(now) {
+		return nil, errors.New("start time must be in the future")
+	}
+
+	var slot *models.ParkingSlot
+	err = s.DB.First(&slot, slotID).Error
+	if err != nil {
+		return nil, err
+	}
+	if slot.ParkingID != parkingID {
+		return nil, fmt.Errorf("slot %s does not belong to parking %d", slot.Name, parkingID)
+	}
+
+	hold := &models.SlotHold{
+		Token:     "HOLD-" + pkg.RandomString(24),
+		UserID:    userID,
+		ParkingID: parkingID,
+		SlotID:    slotID,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+		ExpiresAt: now.Add(slotHoldTTL),
+		Status:    models.SlotHoldStatusActive,
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the user and the slot so concurrent holds are counted and checked one at a time
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.User{}, userID).Error
+		if err != nil {
+			return err
+		}
+
+		var holds int64
+		err = tx.Model(&models.SlotHold{}).
+			Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.SlotHoldStatusActive, now).
+			Count(&holds).Error
+		if err != nil {
+			return err
+		}
+		if holds >= maxSlotHoldsPerUser {
+			return pkg.NewConflictError(fmt.Sprintf("you can hold at most %d slots at a time", maxSlotHoldsPerUser))
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.ParkingSlot{}, slotID).Error
+		if err != nil {
+			return err
+		}
+
+		conflicts, err := findSlotConflicts(tx, slotConflictFilter{SlotID: slotID}, req.StartAt, req.EndAt)
+		if err != nil {
+			return err
+		}
+		if len(conflicts[slotID]) > 0 {
+			return ErrSlotAlreadyBooked
+		}
+
+		return tx.Create(hold).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// ReleaseSlotHold gives a held slot back before the hold expires.
+func (s *BookingService) ReleaseSlotHold(userID int, slotID int, token string) error {
+	result := s.DB.Model(&models.SlotHold{}).
+		Where("token = ? AND slot_id = ? AND user_id = ? AND status = ?", token, slotID, userID, models.SlotHoldStatusActive).
+		Update("status", models.SlotHoldStatusReleased)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// checkSlotHold collects the problems keeping a booking from consuming a hold.
+func checkSlotHold(hold *models.SlotHold, userID int, slotID int, startAt time.Time, endAt time.Time, now time.Time) []error {
+	var problems []error
+	if hold.UserID != userID {
+		problems = append(problems, ErrSlotHeld)
+	}
+	if !hold.IsActive(now) {
+		problems = append(problems, errors.New("slot hold has expired"))
+	}
+	if hold.SlotID != slotID {
+		problems = append(problems, errors.New("slot hold is for another slot"))
+	}
+	if startAt.Before(hold.StartAt) || endAt.After(hold.EndAt) {
+		problems = append(problems, errors.New("booking time is outside the slot hold"))
+	}
+	return problems
+}
+
+// lockHeldSlot locks the slot of a booking about to be written and makes sure no other checkout holds it.
+// It has to run before the booking is inserted, the foreign key check of the insert would otherwise share the
+// lock with concurrent bookings of the slot.
+func lockHeldSlot(tx *gorm.DB, booking *models.Booking, hold *models.SlotHold) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.ParkingSlot{}, booking.SlotID).Error
+	if err != nil {
+		return err
+	}
+
+	query := tx.Model(&models.SlotHold{}).Scopes(activeSlotHolds(booking.StartAt, booking.EndAt)).Where("slot_id = ?", booking.SlotID)
+	if hold != nil {
+		query = query.Where("id <> ?", hold.ID)
+	}
+	var holds int64
+	err = query.Count(&holds).Error
+	if err != nil {
+		return err
+	}
+	if holds > 0 {
+		return ErrSlotHeld
+	}
+
+	return nil
+}
+
+// consumeSlotHold marks the hold as used by the booking, failing when it expired in the meantime.
+func consumeSlotHold(tx *gorm.DB, hold *models.SlotHold, bookingID int) error {
+	result := tx.Model(&models.SlotHold{}).
+		Where("id = ? AND status = ? AND expires_at > ?", hold.ID, models.SlotHoldStatusActive, pkg.GetCurrentTime()).
+		Updates(map[string]interface{}{
+			"status":     models.SlotHoldStatusConsumed,
+			"booking_id": bookingID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return pkg.NewConflictError("slot hold has expired")
+	}
+
+	return nil
+}
@@ -0,0 +1,890 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const subscriptionReferencePrefix = "PKGO-SUB-"
+
+// subscriptionReminderWindow is how long before the end of a period the renewal invoice is issued and the
+// subscriber reminded.
+const subscriptionReminderWindow = 3 * 24 * time.Hour
+
+type SubscriptionService struct {
+	DB              *gorm.DB
+	Validate        *validator.Validate
+	PaymentProvider paymentgateway.PaymentProvider
+	Ledger          *LedgerService
+	MailService     *MailService
+}
+
+func NewSubscriptionService(db *gorm.DB, validate *validator.Validate, paymentProvider paymentgateway.PaymentProvider, ledger *LedgerService, mailService *MailService) *SubscriptionService {
+	return &SubscriptionService{
+		DB:              db,
+		Validate:        validate,
+		PaymentProvider: paymentProvider,
+		Ledger:          ledger,
+		MailService:     mailService,
+	}
+}
+
+// SubscriptionPeriodEnd returns the end of a period of the given length starting at start.
+func SubscriptionPeriodEnd(period string, start time.Time) time.Time {
+	if period == models.SubscriptionPeriodWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// SubscriptionPlanCovers reports whether the day and time restrictions of the plan allow parking at t.
+func SubscriptionPlanCovers(plan *models.SubscriptionPlan, t time.Time) bool {
+	rule := models.PricingRule{DaysOfWeek: plan.DaysOfWeek, StartTime: plan.StartTime, EndTime: plan.EndTime}
+	if !matchesDay(rule, t) {
+		return false
+	}
+	if plan.StartTime == "" || plan.EndTime == "" {
+		return true
+	}
+	return matchesTimeBand(rule, t)
+}
+
+// findValidSubscription returns the subscription of the parking whose plate matches plateNumber best and that
+// allows parking at now, or nil when there is none.
+func findValidSubscription(db *gorm.DB, parkingID int, plateNumber string, now time.Time) (*models.Subscription, float64, error) {
+	var subscriptions []*models.Subscription
+	err := db.Preload("Plan").
+		Where("parking_id = ? AND status = ? AND current_period_start <= ? AND current_period_end > ?", parkingID, models.SubscriptionStatusActive, now, now).
+		Find(&subscriptions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var best *models.Subscription
+	bestSimilarity := 0.0
+	for _, subscription := range subscriptions {
+		if subscription.Plan == nil || !SubscriptionPlanCovers(subscription.Plan, now) {
+			continue
+		}
+
+		similarity := pkg.CalculateSimilarity(subscription.PlateNumber, plateNumber)
+		if similarity >= 0.7 && similarity > bestSimilarity {
+			best = subscription
+			bestSimilarity = similarity
+		}
+	}
+
+	return best, bestSimilarity, nil
+}
+
+func (s *SubscriptionService) GetSubscriptionPlans(parkingID int) ([]models.SubscriptionPlan, error) {
+	var plans []models.SubscriptionPlan
+	err := s.DB.Where("parking_id = ?", parkingID).Order("id ASC").Find(&plans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+func (s *SubscriptionService) GetSubscriptionPlanByID(parkingID int, id int) (*models.SubscriptionPlan, error) {
+	var plan *models.SubscriptionPlan
+	err := s.DB.Where("parking_id = ?", parkingID).First(&plan, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (s *SubscriptionService) CreateSubscriptionPlan(parkingID int, req *models.CreateSubscriptionPlanRequest) (*models.SubscriptionPlan, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var parking models.Parking
+	err = s.DB.First(&parking, parkingID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.SubscriptionPlan{
+		ParkingID:   parkingID,
+		Name:        req.Name,
+		Description: req.Description,
+		Period:      req.Period,
+		Price:       req.Price,
+		DaysOfWeek:  req.DaysOfWeek,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Capacity:    req.Capacity,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		plan.IsActive = *req.IsActive
+	}
+	if plan.DaysOfWeek == nil {
+		plan.DaysOfWeek = []int{}
+	}
+
+	err = validateSubscriptionPlan(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Create(plan).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// UpdateSubscriptionPlan only affects periods paid afterwards. Lowering the capacity does not end subscriptions
+// that are already held.
+func (s *SubscriptionService) UpdateSubscriptionPlan(parkingID int, id int, req *models.UpdateSubscriptionPlanRequest) (*models.SubscriptionPlan, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.GetSubscriptionPlanByID(parkingID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		plan.Name = req.Name
+	}
+	if req.Description != "" {
+		plan.Description = req.Description
+	}
+	if req.Price != nil {
+		plan.Price = *req.Price
+	}
+	if req.DaysOfWeek != nil {
+		plan.DaysOfWeek = req.DaysOfWeek
+	}
+	if req.StartTime != nil {
+		plan.StartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		plan.EndTime = *req.EndTime
+	}
+	if req.Capacity != nil {
+		plan.Capacity = *req.Capacity
+	}
+	if req.IsActive != nil {
+		plan.IsActive = *req.IsActive
+	}
+
+	err = validateSubscriptionPlan(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Save(plan).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (s *SubscriptionService) DeleteSubscriptionPlan(parkingID int, id int) error {
+	var heldCount int64
+	err := s.DB.Model(&models.Subscription{}).
+		Where("plan_id = ? AND status IN ?", id, []string{models.SubscriptionStatusPending, models.SubscriptionStatusActive}).
+		Count(&heldCount).Error
+	if err != nil {
+		return err
+	}
+
+	if heldCount > 0 {
+		return pkg.NewConflictError("subscription plan still has subscribers")
+	}
+
+	return s.DB.Where("parking_id = ?", parkingID).Delete(&models.SubscriptionPlan{}, id).Error
+}
+
+func (s *SubscriptionService) GetSubscriptions(filter *models.SubscriptionFilter) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	query := s.DB.Preload("Plan").Order("id DESC")
+
+	if filter != nil {
+		if filter.UserID != 0 {
+			query = query.Where("user_id = ?", filter.UserID)
+		}
+		if filter.ParkingID != 0 {
+			query = query.Where("parking_id = ?", filter.ParkingID)
+		}
+		if filter.Status != "" {
+			query = query.Where("status = ?", filter.Status)
+		}
+		if filter.Limit != 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Page != 0 {
+			query = query.Offset((filter.Page - 1) * filter.Limit)
+		}
+	}
+
+	err := query.Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (s *SubscriptionService) GetSubscriptionByID(id int) (*models.Subscription, error) {
+	var subscription *models.Subscription
+	err := s.DB.Preload("Plan.Parking").Preload("User").Preload("Invoices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&subscription, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// Subscribe reserves a place on the plan for the plate of the user and issues the invoice of the first period,
+// which starts once it is paid.
+func (s *SubscriptionService) Subscribe(user *models.User, req *models.CreateSubscriptionRequest) (*models.Subscription, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := &models.Subscription{
+		PlanID:      req.PlanID,
+		UserID:      user.ID,
+		PlateNumber: req.PlateNumber,
+		Status:      models.SubscriptionStatusPending,
+		AutoRenew:   true,
+	}
+	if req.AutoRenew != nil {
+		subscription.AutoRenew = *req.AutoRenew
+	}
+
+	var paymentInvoice *paymentgateway.Invoice
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		err := checkPlanCapacityTx(tx, req.PlanID)
+		if err != nil {
+			return err
+		}
+
+		var plan models.SubscriptionPlan
+		err = tx.Preload("Parking").First(&plan, req.PlanID).Error
+		if err != nil {
+			return err
+		}
+
+		var duplicateCount int64
+		err = tx.Model(&models.Subscription{}).
+			Where("plan_id = ? AND plate_number = ? AND status IN ?", plan.ID, req.PlateNumber, []string{models.SubscriptionStatusPending, models.SubscriptionStatusActive}).
+			Count(&duplicateCount).Error
+		if err != nil {
+			return err
+		}
+		if duplicateCount > 0 {
+			return pkg.NewConflictError("plate number is already subscribed to this plan")
+		}
+
+		subscription.ParkingID = plan.ParkingID
+		subscription.Plan = &plan
+		err = tx.Create(subscription).Error
+		if err != nil {
+			return err
+		}
+
+		_, paymentInvoice, err = s.issueInvoiceTx(tx, user, subscription, pkg.GetCurrentTime(), 600*time.Second)
+		return err
+	})
+	if err != nil {
+		if paymentInvoice != nil {
+			s.expireInvoice(paymentInvoice.ID)
+		}
+		return nil, err
+	}
+
+	go s.MailService.SendMail(user.Email, fmt.Sprintf("Subscription %s", subscription.Plan.Name), fmt.Sprintf("Your subscription for %s has been created. Please complete the payment: %s", subscription.PlateNumber, paymentInvoice.InvoiceURL))
+
+	return s.GetSubscriptionByID(subscription.ID)
+}
+
+// RenewSubscription issues the invoice of the next period of an active subscription, unless one is still
+// waiting for payment.
+func (s *SubscriptionService) RenewSubscription(user *models.User, id int) (*models.SubscriptionInvoice, error) {
+	subscription, err := s.GetSubscriptionByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.UserID != user.ID && !user.IsAdmin() {
+		return nil, pkg.NewForbiddenError("You are not allowed to renew this subscription")
+	}
+
+	return s.issueRenewal(subscription)
+}
+
+// CancelSubscription stops the renewal of a subscription. An active subscription stays valid until the end of
+// the paid period, a pending one is canceled right away.
+func (s *SubscriptionService) CancelSubscription(user *models.User, id int) (*models.Subscription, error) {
+	subscription, err := s.GetSubscriptionByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.UserID != user.ID && !user.IsAdmin() {
+		return nil, pkg.NewForbiddenError("You are not allowed to cancel this subscription")
+	}
+
+	updates := map[string]interface{}{
+		"auto_renew": false,
+		"updated_at": pkg.GetCurrentTime(),
+	}
+	switch subscription.Status {
+	case models.SubscriptionStatusPending:
+		updates["status"] = models.SubscriptionStatusCanceled
+	case models.SubscriptionStatusActive:
+	default:
+		return nil, pkg.NewConflictError("subscription has already ended")
+	}
+
+	result := s.DB.Model(&models.Subscription{}).Where("id = ? AND status = ?", subscription.ID, subscription.Status).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, pkg.NewConflictError("subscription changed, please try again")
+	}
+
+	err = s.expireUnpaidInvoices(subscription.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSubscriptionByID(subscription.ID)
+}
+
+// ProcessPaymentEvent applies a gateway callback for a subscription invoice.
+func (s *SubscriptionService) ProcessPaymentEvent(event *paymentgateway.WebhookEvent) error {
+	var invoice models.SubscriptionInvoice
+	err := s.DB.Where("payment_reference = ?", event.ExternalID).First(&invoice).Error
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case event.IsPaid():
+		err = checkPaidEvent(event, invoice.Amount)
+		if err != nil {
+			return err
+		}
+		return s.applyPayment(&invoice)
+	case event.Status == paymentgateway.InvoiceStatusExpired:
+		return s.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.SubscriptionInvoice{}).
+				Where("id = ? AND status = ?", invoice.ID, models.SubscriptionInvoiceStatusUnpaid).
+				Updates(map[string]interface{}{
+					"status":     models.SubscriptionInvoiceStatusExpired,
+					"updated_at": pkg.GetCurrentTime(),
+				})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			// A subscription whose first period was never paid gives its place back
+			return tx.Model(&models.Subscription{}).
+				Where("id = ? AND status = ?", invoice.SubscriptionID, models.SubscriptionStatusPending).
+				Updates(map[string]interface{}{
+					"status":     models.SubscriptionStatusExpired,
+					"updated_at": pkg.GetCurrentTime(),
+				}).Error
+		})
+	}
+
+	return nil
+}
+
+// applyPayment starts the period of a paid invoice. A renewal paid before the current period ends continues it,
+// a payment arriving after the subscription ended starts a new period from now if the plan still has room. When it
+// has none the invoice is kept paid and its payment refunded.
+func (s *SubscriptionService) applyPayment(invoice *models.SubscriptionInvoice) error {
+	var subscription models.Subscription
+	applied := false
+	rejected := ""
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := pkg.GetCurrentTime()
+		result := tx.Model(&models.SubscriptionInvoice{}).
+			Where("id = ? AND status IN ?", invoice.ID, []string{models.SubscriptionInvoiceStatusUnpaid, models.SubscriptionInvoiceStatusExpired}).
+			Updates(map[string]interface{}{
+				"status":     models.SubscriptionInvoiceStatusPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Plan.Parking").First(&subscription, invoice.SubscriptionID).Error
+		if err != nil {
+			return err
+		}
+
+		// An ended subscription gave its place back, it only comes back if the plan still has room for it
+		if subscription.Status != models.SubscriptionStatusPending && subscription.Status != models.SubscriptionStatusActive {
+			err = checkPlanCapacityTx(tx, subscription.PlanID)
+			var appErr *pkg.AppError
+			if errors.As(err, &appErr) {
+				rejected = appErr.Message
+				return tx.Model(&models.SubscriptionInvoice{}).Where("id = ?", invoice.ID).Update("note", rejected).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{
+			"status":           models.SubscriptionStatusActive,
+			"is_reminder_sent": false,
+			"updated_at":       now,
+		}
+
+		periodStart := invoice.PeriodStart
+		isRunning := subscription.Status == models.SubscriptionStatusActive && subscription.CurrentPeriodEnd != nil && subscription.CurrentPeriodEnd.After(now)
+		if !isRunning {
+			if periodStart.Before(now) {
+				periodStart = now
+			}
+			updates["current_period_start"] = periodStart
+		}
+		periodEnd := SubscriptionPeriodEnd(subscription.Plan.Period, periodStart)
+		updates["current_period_end"] = periodEnd
+
+		err = tx.Model(&models.Subscription{}).Where("id = ?", subscription.ID).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+
+		commissionPlan, err := resolveCommissionPlan(tx, subscription.Plan.Parking)
+		if err != nil {
+			return err
+		}
+
+		err = s.Ledger.RecordSubscriptionPaymentTx(tx, &subscription, invoice.PaymentReference, invoice.Amount, CalculateCommission(commissionPlan, invoice.Amount, false))
+		if err != nil {
+			return err
+		}
+
+		subscription.CurrentPeriodEnd = &periodEnd
+		applied = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if rejected != "" {
+		s.refundInvoice(invoice, &subscription, rejected)
+		return nil
+	}
+	if !applied {
+		return nil
+	}
+
+	var user models.User
+	err = s.DB.First(&user, subscription.UserID).Error
+	if err != nil {
+		logrus.Errorf("Failed to notify subscriber of %s: %v", invoice.PaymentReference, err)
+		return nil
+	}
+
+	go s.MailService.SendMail(user.Email, fmt.Sprintf("Subscription Paid %s", invoice.PaymentReference), fmt.Sprintf("Your %s subscription for %s is active until %s.", subscription.Plan.Name, subscription.PlateNumber, subscription.CurrentPeriodEnd.Format("2006-01-02 15:04")))
+
+	return nil
+}
+
+// refundInvoice returns the payment of an invoice that could not start a period. The payment never reached the
+// parking, so nothing is posted to the ledger. A refund the gateway rejects leaves the invoice PAID with the error
+// in its note for review.
+func (s *SubscriptionService) refundInvoice(invoice *models.SubscriptionInvoice, subscription *models.Subscription, reason string) {
+	paymentRefund, err := s.PaymentProvider.Refund(&paymentgateway.RefundRequest{
+		InvoiceID:   invoice.PaymentInvoiceID,
+		ReferenceID: invoice.PaymentReference + "-RFD",
+		Amount:      invoice.Amount,
+		Reason:      "CANCELLATION",
+	})
+	if err != nil {
+		logrus.Errorf("Failed to refund %s: %v", invoice.PaymentReference, err)
+		err = s.DB.Model(&models.SubscriptionInvoice{}).Where("id = ?", invoice.ID).Update("note", fmt.Sprintf("%s, refund failed: %v", reason, err)).Error
+		if err != nil {
+			logrus.Errorf("Failed to update invoice %s: %v", invoice.PaymentReference, err)
+		}
+		return
+	}
+
+	err = s.DB.Model(&models.SubscriptionInvoice{}).Where("id = ? AND status = ?", invoice.ID, models.SubscriptionInvoiceStatusPaid).Updates(map[string]interface{}{
+		"status":             models.SubscriptionInvoiceStatusRefunded,
+		"provider_refund_id": paymentRefund.ID,
+		"updated_at":         pkg.GetCurrentTime(),
+	}).Error
+	if err != nil {
+		logrus.Errorf("Failed to update invoice %s: %v", invoice.PaymentReference, err)
+	}
+
+	var user models.User
+	err = s.DB.First(&user, subscription.UserID).Error
+	if err != nil {
+		logrus.Errorf("Failed to notify subscriber of %s: %v", invoice.PaymentReference, err)
+		return
+	}
+
+	go s.MailService.SendMail(user.Email, fmt.Sprintf("Subscription Refunded %s", invoice.PaymentReference), fmt.Sprintf("Your payment for the %s subscription of %s arrived after it ended and the plan is no longer available (%s). The payment will be refunded.", subscription.Plan.Name, subscription.PlateNumber, reason))
+}
+
+// RenewSubscriptions issues the renewal invoices of subscriptions ending soon and reminds their subscribers.
+// It returns the number of subscribers reminded.
+func (s *SubscriptionService) RenewSubscriptions() (int, error) {
+	now := pkg.GetCurrentTime()
+
+	var subscriptions []*models.Subscription
+	err := s.DB.Preload("Plan").Preload("User").
+		Where("status = ? AND is_reminder_sent = ? AND current_period_end <= ?", models.SubscriptionStatusActive, false, now.Add(subscriptionReminderWindow)).
+		Find(&subscriptions).Error
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, subscription := range subscriptions {
+		content := fmt.Sprintf("Your %s subscription for %s expires on %s.", subscription.Plan.Name, subscription.PlateNumber, subscription.CurrentPeriodEnd.Format("2006-01-02 15:04"))
+		if subscription.AutoRenew {
+			invoice, err := s.issueRenewal(subscription)
+			if err != nil {
+				logrus.Errorf("Failed to renew subscription %d: %v", subscription.ID, err)
+				continue
+			}
+			content += fmt.Sprintf(" Renew it for the next period: %s", invoice.PaymentLink)
+		}
+
+		err = s.DB.Model(&models.Subscription{}).Where("id = ?", subscription.ID).Update("is_reminder_sent", true).Error
+		if err != nil {
+			logrus.Errorf("Failed to update subscription %d: %v", subscription.ID, err)
+			continue
+		}
+
+		go s.MailService.SendMail(subscription.User.Email, fmt.Sprintf("Subscription Expiring %s", subscription.Plan.Name), content)
+		reminded++
+	}
+
+	return reminded, nil
+}
+
+// ExpireSubscriptions ends the subscriptions whose period is over and that were not renewed in time, and the
+// pending ones whose first invoice expired.
+func (s *SubscriptionService) ExpireSubscriptions() (int, error) {
+	now := pkg.GetCurrentTime()
+
+	var subscriptions []*models.Subscription
+	err := s.DB.Preload("Plan").Preload("User").
+		Where("status = ? AND current_period_end <= ?", models.SubscriptionStatusActive, now).
+		Find(&subscriptions).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, subscription := range subscriptions {
+		result := s.DB.Model(&models.Subscription{}).
+			Where("id = ? AND status = ? AND current_period_end <= ?", subscription.ID, models.SubscriptionStatusActive, now).
+			Updates(map[string]interface{}{
+				"status":     models.SubscriptionStatusExpired,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			logrus.Errorf("Failed to expire subscription %d: %v", subscription.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		err = s.expireUnpaidInvoices(subscription.ID)
+		if err != nil {
+			logrus.Errorf("Failed to expire invoices of subscription %d: %v", subscription.ID, err)
+		}
+
+		go s.MailService.SendMail(subscription.User.Email, fmt.Sprintf("Subscription Expired %s", subscription.Plan.Name), fmt.Sprintf("Your %s subscription for %s has expired.", subscription.Plan.Name, subscription.PlateNumber))
+		expired++
+	}
+
+	pending, err := s.expirePendingSubscriptions(now)
+	if err != nil {
+		return expired, err
+	}
+
+	return expired + pending, nil
+}
+
+// expirePendingSubscriptions gives back the places of subscriptions whose first invoice can no longer be paid, in
+// case the expiry callback of the gateway never arrived. A first invoice that was paid after all is applied instead.
+func (s *SubscriptionService) expirePendingSubscriptions(now time.Time) (int, error) {
+	var subscriptions []*models.Subscription
+	err := s.DB.Where("status = ?", models.SubscriptionStatusPending).
+		Where("NOT EXISTS (?)", s.DB.Model(&models.SubscriptionInvoice{}).
+			Select("1").
+			Where("subscription_invoices.subscription_id = subscriptions.id AND status = ? AND payment_expired_at > ?", models.SubscriptionInvoiceStatusUnpaid, now)).
+		Find(&subscriptions).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, subscription := range subscriptions {
+		var invoice models.SubscriptionInvoice
+		err = s.DB.Where("subscription_id = ? AND status = ?", subscription.ID, models.SubscriptionInvoiceStatusUnpaid).Order("id DESC").Limit(1).Find(&invoice).Error
+		if err != nil {
+			logrus.Errorf("Failed to get invoice of subscription %d: %v", subscription.ID, err)
+			continue
+		}
+
+		if invoice.PaymentInvoiceID != "" {
+			paymentInvoice, err := s.PaymentProvider.GetInvoice(invoice.PaymentInvoiceID)
+			if err != nil {
+				logrus.Errorf("Failed to get invoice %s: %v", invoice.PaymentReference, err)
+				continue
+			}
+
+			if paymentInvoice.IsPaid() {
+				err = s.applyPayment(&invoice)
+				if err != nil {
+					logrus.Errorf("Failed to apply payment of %s: %v", invoice.PaymentReference, err)
+				}
+				continue
+			}
+		}
+
+		result := s.DB.Model(&models.Subscription{}).
+			Where("id = ? AND status = ?", subscription.ID, models.SubscriptionStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.SubscriptionStatusExpired,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			logrus.Errorf("Failed to expire subscription %d: %v", subscription.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		err = s.expireUnpaidInvoices(subscription.ID)
+		if err != nil {
+			logrus.Errorf("Failed to expire invoices of subscription %d: %v", subscription.ID, err)
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// checkPlanCapacityTx locks the plan, so concurrent subscribers cannot both take its last place, and checks it
+// can take one more subscription.
+func checkPlanCapacityTx(tx *gorm.DB, planID int) error {
+	var plan models.SubscriptionPlan
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&plan, planID).Error
+	if err != nil {
+		return err
+	}
+
+	if !plan.IsActive {
+		return pkg.NewConflictError("subscription plan is not available")
+	}
+
+	if plan.Capacity > 0 {
+		var heldCount int64
+		err = tx.Model(&models.Subscription{}).
+			Where("plan_id = ? AND status IN ?", plan.ID, []string{models.SubscriptionStatusPending, models.SubscriptionStatusActive}).
+			Count(&heldCount).Error
+		if err != nil {
+			return err
+		}
+		if heldCount >= int64(plan.Capacity) {
+			return pkg.NewConflictError("subscription plan is sold out")
+		}
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) issueRenewal(subscription *models.Subscription) (*models.SubscriptionInvoice, error) {
+	if subscription.Status != models.SubscriptionStatusActive || subscription.CurrentPeriodEnd == nil {
+		return nil, pkg.NewConflictError("only active subscriptions can be renewed")
+	}
+
+	now := pkg.GetCurrentTime()
+	var pending models.SubscriptionInvoice
+	err := s.DB.Where("subscription_id = ? AND status = ? AND payment_expired_at > ?", subscription.ID, models.SubscriptionInvoiceStatusUnpaid, now).
+		Order("id DESC").Limit(1).Find(&pending).Error
+	if err != nil {
+		return nil, err
+	}
+	if pending.ID != 0 {
+		return &pending, nil
+	}
+
+	user := subscription.User
+	if user == nil {
+		user = &models.User{}
+		err = s.DB.First(user, subscription.UserID).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The renewal can be paid until the current period ends
+	duration := max(subscription.CurrentPeriodEnd.Sub(now), time.Hour)
+
+	var invoice *models.SubscriptionInvoice
+	var paymentInvoice *paymentgateway.Invoice
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, paymentInvoice, err = s.issueInvoiceTx(tx, user, subscription, *subscription.CurrentPeriodEnd, duration)
+		return err
+	})
+	if err != nil {
+		if paymentInvoice != nil {
+			s.expireInvoice(paymentInvoice.ID)
+		}
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// issueInvoiceTx creates the invoice of the period of subscription starting at periodStart.
+func (s *SubscriptionService) issueInvoiceTx(tx *gorm.DB, user *models.User, subscription *models.Subscription, periodStart time.Time, duration time.Duration) (*models.SubscriptionInvoice, *paymentgateway.Invoice, error) {
+	plan := subscription.Plan
+	if plan == nil || plan.Parking == nil {
+		plan = &models.SubscriptionPlan{}
+		err := tx.Unscoped().Preload("Parking").First(plan, subscription.PlanID).Error
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	invoice := &models.SubscriptionInvoice{
+		SubscriptionID:   subscription.ID,
+		PeriodStart:      periodStart,
+		PeriodEnd:        SubscriptionPeriodEnd(plan.Period, periodStart),
+		Amount:           plan.Price,
+		PaymentReference: subscriptionReferencePrefix + pkg.RandomString(8),
+		Status:           models.SubscriptionInvoiceStatusUnpaid,
+	}
+	err := tx.Create(invoice).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paymentInvoice, err := s.PaymentProvider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{
+		ExternalID:         invoice.PaymentReference,
+		Amount:             invoice.Amount,
+		Currency:           "IDR",
+		Description:        fmt.Sprintf("Parking subscription for %s", subscription.PlateNumber),
+		PayerEmail:         user.Email,
+		CustomerID:         strconv.Itoa(user.ID),
+		CustomerName:       user.FullName,
+		SuccessRedirectURL: fmt.Sprintf("https://parkingo.agil.zip/s/%s", invoice.PaymentReference),
+		Duration:           duration,
+		Items: []paymentgateway.InvoiceItem{
+			{
+				Name:        fmt.Sprintf("%s | %s | %s | %s - %s", plan.Parking.Name, plan.Name, subscription.PlateNumber, invoice.PeriodStart.Format("2006-01-02"), invoice.PeriodEnd.Format("2006-01-02")),
+				Price:       invoice.Amount,
+				Quantity:    1,
+				ReferenceID: plan.Parking.Slug,
+			},
+		},
+	})
+	if err != nil {
+		logrus.Error("Payment Error:", err)
+		return nil, nil, err
+	}
+
+	invoice.PaymentInvoiceID = paymentInvoice.ID
+	invoice.PaymentLink = paymentInvoice.InvoiceURL
+	invoice.PaymentExpiredAt = paymentInvoice.ExpiryDate
+	err = tx.Model(invoice).Updates(map[string]interface{}{
+		"payment_invoice_id": invoice.PaymentInvoiceID,
+		"payment_link":       invoice.PaymentLink,
+		"payment_expired_at": invoice.PaymentExpiredAt,
+	}).Error
+	if err != nil {
+		return nil, paymentInvoice, err
+	}
+
+	return invoice, paymentInvoice, nil
+}
+
+func (s *SubscriptionService) expireUnpaidInvoices(subscriptionID int) error {
+	var invoices []models.SubscriptionInvoice
+	err := s.DB.Where("subscription_id = ? AND status = ?", subscriptionID, models.SubscriptionInvoiceStatusUnpaid).Find(&invoices).Error
+	if err != nil {
+		return err
+	}
+
+	for _, invoice := range invoices {
+		s.expireInvoice(invoice.PaymentInvoiceID)
+
+		err = s.DB.Model(&models.SubscriptionInvoice{}).
+			Where("id = ? AND status = ?", invoice.ID, models.SubscriptionInvoiceStatusUnpaid).
+			Updates(map[string]interface{}{
+				"status":     models.SubscriptionInvoiceStatusExpired,
+				"updated_at": pkg.GetCurrentTime(),
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) expireInvoice(invoiceID string) {
+	if invoiceID == "" {
+		return
+	}
+
+	_, err := s.PaymentProvider.ExpireInvoice(invoiceID)
+	if err != nil {
+		logrus.Errorf("Failed to expire invoice %s: %v", invoiceID, err)
+	}
+}
+
+func validateSubscriptionPlan(plan *models.SubscriptionPlan) error {
+	for _, clock := range []string{plan.StartTime, plan.EndTime} {
+		if _, err := time.Parse("15:04", clock); clock != "" && err != nil {
+			return fmt.Errorf("invalid time %s, expected HH:MM", clock)
+		}
+	}
+	if (plan.StartTime == "") != (plan.EndTime == "") {
+		return errors.New("start_time and end_time must be set together")
+	}
+	if plan.StartTime != "" && plan.StartTime == plan.EndTime {
+		return errors.New("start_time and end_time must be different")
+	}
+
+	return nil
+}
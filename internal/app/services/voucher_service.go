@@ -0,0 +1,315 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type VoucherService struct {
+	DB       *gorm.DB
+	Validate *validator.Validate
+}
+
+func NewVoucherService(db *gorm.DB, validate *validator.Validate) *VoucherService {
+	return &VoucherService{
+		DB:       db,
+		Validate: validate,
+	}
+}
+
+// CalculateVoucherDiscount returns how much the voucher takes off amount. The discount never exceeds the amount.
+func CalculateVoucherDiscount(voucher *models.Voucher, amount float64) float64 {
+	if voucher == nil || amount <= 0 {
+		return 0
+	}
+
+	discount := voucher.DiscountValue
+	if voucher.DiscountType == models.VoucherDiscountPercentage {
+		discount = math.Floor(amount * voucher.DiscountValue / 100)
+		if voucher.MaxDiscount > 0 {
+			discount = math.Min(discount, voucher.MaxDiscount)
+		}
+	}
+
+	return math.Min(discount, amount)
+}
+
+func findVoucher(db *gorm.DB, code string) (*models.Voucher, error) {
+	var voucher models.Voucher
+	err := db.Where("code = ?", strings.ToUpper(code)).First(&voucher).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("voucher %s does not exist", code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &voucher, nil
+}
+
+// checkVoucher reports why the voucher cannot be applied to a booking of the user at the parking for amount,
+// or nil when it can. Usage limits only count redemptions of bookings that were not abandoned.
+func checkVoucher(db *gorm.DB, voucher *models.Voucher, userID int, parkingID int, amount float64, now time.Time) error {
+	if !voucher.IsActive {
+		return fmt.Errorf("voucher %s is not active", voucher.Code)
+	}
+	if voucher.StartsAt != nil && now.Before(*voucher.StartsAt) {
+		return fmt.Errorf("voucher %s is not valid yet", voucher.Code)
+	}
+	if voucher.EndsAt != nil && !now.Before(*voucher.EndsAt) {
+		return fmt.Errorf("voucher %s has expired", voucher.Code)
+	}
+	if len(voucher.ParkingIDs) > 0 && !slices.Contains(voucher.ParkingIDs, parkingID) {
+		return fmt.Errorf("voucher %s cannot be used at this parking", voucher.Code)
+	}
+	if amount < voucher.MinSpend {
+		return fmt.Errorf("voucher %s requires a minimum spend of %.0f", voucher.Code, voucher.MinSpend)
+	}
+
+	if voucher.UsageLimit > 0 {
+		var used int64
+		err := db.Model(&models.VoucherRedemption{}).
+			Where("voucher_id = ? AND status = ?", voucher.ID, models.VoucherRedemptionStatusActive).
+			Count(&used).Error
+		if err != nil {
+			return err
+		}
+		if used >= int64(voucher.UsageLimit) {
+			return fmt.Errorf("voucher %s has been fully redeemed", voucher.Code)
+		}
+	}
+
+	if voucher.UsageLimitPerUser > 0 {
+		var used int64
+		err := db.Model(&models.VoucherRedemption{}).
+			Where("voucher_id = ? AND user_id = ? AND status = ?", voucher.ID, userID, models.VoucherRedemptionStatusActive).
+			Count(&used).Error
+		if err != nil {
+			return err
+		}
+		if used >= int64(voucher.UsageLimitPerUser) {
+			return fmt.Errorf("voucher %s has already been used the maximum number of times", voucher.Code)
+		}
+	}
+
+	return nil
+}
+
+func (s *VoucherService) GetVouchers(filter *models.VoucherFilter) ([]*models.Voucher, error) {
+	var vouchers []*models.Voucher
+	query := s.DB.Order("id DESC")
+
+	if filter != nil {
+		if filter.Search != "" {
+			query = query.Where("code LIKE ?", "%"+strings.ToUpper(filter.Search)+"%")
+		}
+		if filter.Limit != 0 {
+			query = query.Limit(filter.Limit)
+		}
+		if filter.Page != 0 {
+			query = query.Offset((filter.Page - 1) * filter.Limit)
+		}
+	}
+
+	err := query.Find(&vouchers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return vouchers, nil
+}
+
+func (s *VoucherService) GetVoucherByID(id int) (*models.Voucher, error) {
+	var voucher *models.Voucher
+	err := s.DB.First(&voucher, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return voucher, nil
+}
+
+func (s *VoucherService) CreateVoucher(req *models.CreateVoucherRequest) (*models.Voucher, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	voucher := &models.Voucher{
+		Code:              strings.ToUpper(req.Code),
+		Description:       req.Description,
+		DiscountType:      req.DiscountType,
+		DiscountValue:     req.DiscountValue,
+		MaxDiscount:       req.MaxDiscount,
+		MinSpend:          req.MinSpend,
+		StartsAt:          req.StartsAt,
+		EndsAt:            req.EndsAt,
+		UsageLimit:        req.UsageLimit,
+		UsageLimitPerUser: req.UsageLimitPerUser,
+		ParkingIDs:        req.ParkingIDs,
+		IsActive:          true,
+	}
+	if req.IsActive != nil {
+		voucher.IsActive = *req.IsActive
+	}
+	if voucher.ParkingIDs == nil {
+		voucher.ParkingIDs = []int{}
+	}
+
+	err = validateVoucher(voucher)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	err = s.DB.Unscoped().Model(&models.Voucher{}).Where("code = ?", voucher.Code).Count(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, errors.New("voucher code already exists")
+	}
+
+	err = s.DB.Create(voucher).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return voucher, nil
+}
+
+func (s *VoucherService) UpdateVoucher(id int, req *models.UpdateVoucherRequest) (*models.Voucher, error) {
+	err := s.Validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+
+	voucher, err := s.GetVoucherByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Description != "" {
+		voucher.Description = req.Description
+	}
+	if req.DiscountValue != nil {
+		voucher.DiscountValue = *req.DiscountValue
+	}
+	if req.MaxDiscount != nil {
+		voucher.MaxDiscount = *req.MaxDiscount
+	}
+	if req.MinSpend != nil {
+		voucher.MinSpend = *req.MinSpend
+	}
+	if req.StartsAt != nil {
+		voucher.StartsAt = req.StartsAt
+	}
+	if req.EndsAt != nil {
+		voucher.EndsAt = req.EndsAt
+	}
+	if req.UsageLimit != nil {
+		voucher.UsageLimit = *req.UsageLimit
+	}
+	if req.UsageLimitPerUser != nil {
+		voucher.UsageLimitPerUser = *req.UsageLimitPerUser
+	}
+	if req.ParkingIDs != nil {
+		voucher.ParkingIDs = req.ParkingIDs
+	}
+	if req.IsActive != nil {
+		voucher.IsActive = *req.IsActive
+	}
+
+	err = validateVoucher(voucher)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Save(voucher).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return voucher, nil
+}
+
+func (s *VoucherService) DeleteVoucher(id int) error {
+	return s.DB.Delete(&models.Voucher{}, id).Error
+}
+
+func (s *VoucherService) GetVoucherStats(id int) (*models.VoucherStats, error) {
+	voucher, err := s.GetVoucherByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.VoucherStats{
+		VoucherID: voucher.ID,
+		Code:      voucher.Code,
+	}
+
+	var redemptions []struct {
+		Status      string
+		Count       int64
+		UniqueUsers int64
+		Discount    float64
+	}
+	err = s.DB.Model(&models.VoucherRedemption{}).
+		Select("status, COUNT(*) AS count, COUNT(DISTINCT user_id) AS unique_users, COALESCE(SUM(discount), 0) AS discount").
+		Where("voucher_id = ?", voucher.ID).
+		Group("status").
+		Scan(&redemptions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range redemptions {
+		switch row.Status {
+		case models.VoucherRedemptionStatusActive:
+			stats.Redemptions = row.Count
+			stats.UniqueUsers = row.UniqueUsers
+			stats.TotalDiscount = row.Discount
+		case models.VoucherRedemptionStatusReleased:
+			stats.ReleasedCount = row.Count
+		}
+	}
+
+	var paid struct {
+		Count   int64
+		Revenue float64
+	}
+	err = s.DB.Model(&models.Booking{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(total_fee), 0) AS revenue").
+		Where("voucher_id = ? AND status IN ?", voucher.ID, paidBookingStatuses).
+		Scan(&paid).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.PaidBookings = paid.Count
+	stats.RevenueGenerated = paid.Revenue
+
+	if voucher.UsageLimit > 0 {
+		remaining := max(int64(voucher.UsageLimit)-stats.Redemptions, 0)
+		stats.RemainingUsage = &remaining
+	}
+
+	return stats, nil
+}
+
+func validateVoucher(voucher *models.Voucher) error {
+	if voucher.DiscountType == models.VoucherDiscountPercentage && voucher.DiscountValue > 100 {
+		return errors.New("percentage discount cannot exceed 100")
+	}
+	if voucher.StartsAt != nil && voucher.EndsAt != nil && !voucher.EndsAt.After(*voucher.StartsAt) {
+		return errors.New("voucher must end after it starts")
+	}
+
+	return nil
+}
@@ -4,3 +4,6 @@ import "embed"
 
 //go:embed email.html
 var EmailTemplateFS embed.FS
+
+//go:embed fake_payment.html
+var FakePaymentTemplateFS embed.FS
@@ -0,0 +1,95 @@
+package disbursement
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+)
+
+// FakeProvider keeps payouts in memory so the withdrawal flow can run without Xendit credentials. A payout is
+// accepted as pending and succeeds the first time it is fetched, unless Fail was called for it before.
+type FakeProvider struct {
+	mu      sync.Mutex
+	payouts map[string]*Payout
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		payouts: make(map[string]*Payout),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreatePayout(req *CreatePayoutRequest) (*Payout, error) {
+	if req.ReferenceID == "" {
+		return nil, &RejectedError{Code: "INVALID_REQUEST", Message: "fake: reference id is required"}
+	}
+	if req.AccountNumber == "" {
+		return nil, &RejectedError{Code: "INVALID_DESTINATION", Message: "fake: account number is required"}
+	}
+	if req.Amount <= 0 {
+		return nil, &RejectedError{Code: "INVALID_AMOUNT", Message: "fake: amount must be positive"}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The reference doubles as idempotency key, like at Xendit
+	for _, payout := range p.payouts {
+		if payout.ReferenceID == req.ReferenceID {
+			result := *payout
+			return &result, nil
+		}
+	}
+
+	payout := &Payout{
+		ID:          "fake-disb-" + pkg.RandomString(12),
+		ReferenceID: req.ReferenceID,
+		Amount:      req.Amount,
+		Status:      PayoutStatusPending,
+	}
+	p.payouts[payout.ID] = payout
+
+	result := *payout
+	return &result, nil
+}
+
+func (p *FakeProvider) GetPayout(payoutID string) (*Payout, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payout, ok := p.payouts[payoutID]
+	if !ok {
+		return nil, fmt.Errorf("fake: payout %s not found", payoutID)
+	}
+
+	if payout.Status == PayoutStatusPending {
+		payout.Status = PayoutStatusSucceeded
+	}
+
+	result := *payout
+	return &result, nil
+}
+
+// Fail makes a pending payout fail with the given failure code.
+func (p *FakeProvider) Fail(payoutID string, failureCode string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payout, ok := p.payouts[payoutID]
+	if !ok {
+		return fmt.Errorf("fake: payout %s not found", payoutID)
+	}
+
+	if payout.Status != PayoutStatusPending {
+		return fmt.Errorf("fake: payout %s is already %s", payoutID, payout.Status)
+	}
+
+	payout.Status = PayoutStatusFailed
+	payout.FailureCode = failureCode
+	return nil
+}
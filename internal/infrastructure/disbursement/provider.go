@@ -0,0 +1,79 @@
+package disbursement
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	PayoutStatusPending   = "PENDING"
+	PayoutStatusSucceeded = "SUCCEEDED"
+	PayoutStatusFailed    = "FAILED"
+)
+
+// DisbursementProvider is the contract every provider used to pay parking owners out must satisfy.
+type DisbursementProvider interface {
+	Name() string
+	CreatePayout(req *CreatePayoutRequest) (*Payout, error)
+	GetPayout(payoutID string) (*Payout, error)
+}
+
+type CreatePayoutRequest struct {
+	ReferenceID       string
+	ChannelCode       string
+	AccountNumber     string
+	AccountHolderName string
+	Amount            float64
+	Currency          string
+	Description       string
+}
+
+type Payout struct {
+	ID          string  `json:"id"`
+	ReferenceID string  `json:"reference_id"`
+	Amount      float64 `json:"amount"`
+	Status      string  `json:"status"`
+	FailureCode string  `json:"failure_code"`
+}
+
+func (p *Payout) IsSucceeded() bool {
+	return p.Status == PayoutStatusSucceeded
+}
+
+func (p *Payout) IsFailed() bool {
+	return p.Status == PayoutStatusFailed
+}
+
+// RejectedError is returned when the provider refused to create a payout. Sending the same request again cannot
+// succeed, unlike any other error, which leaves it unknown whether the payout was created.
+type RejectedError struct {
+	Code    string
+	Message string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Message
+}
+
+// IsRejected reports whether the provider definitively refused the payout.
+func IsRejected(err error) bool {
+	var rejected *RejectedError
+	return errors.As(err, &rejected)
+}
+
+func NewDisbursementProvider() DisbursementProvider {
+	provider := strings.ToLower(viper.GetString("disbursement.provider"))
+	switch provider {
+	case "fake":
+		logrus.Warn("Using fake disbursement provider, payouts are not transferred")
+		return NewFakeProvider()
+	case "", "xendit":
+		return NewXenditProvider()
+	default:
+		logrus.Fatalf("Unknown disbursement provider: %s", provider)
+		return nil
+	}
+}
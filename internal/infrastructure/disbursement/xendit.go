@@ -0,0 +1,97 @@
+package disbursement
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/spf13/viper"
+	"github.com/xendit/xendit-go/v6"
+	"github.com/xendit/xendit-go/v6/common"
+	"github.com/xendit/xendit-go/v6/payout"
+)
+
+type XenditProvider struct {
+	Client *xendit.APIClient
+}
+
+func NewXenditProvider() *XenditProvider {
+	return &XenditProvider{
+		Client: xendit.NewClient(viper.GetString("xendit.secret_key")),
+	}
+}
+
+func (p *XenditProvider) Name() string {
+	return "xendit"
+}
+
+func (p *XenditProvider) CreatePayout(req *CreatePayoutRequest) (*Payout, error) {
+	channelProperties := *payout.NewDigitalPayoutChannelProperties(req.AccountNumber)
+	channelProperties.SetAccountHolderName(req.AccountHolderName)
+
+	payoutRequest := *payout.NewCreatePayoutRequest(req.ReferenceID, req.ChannelCode, channelProperties, float32(req.Amount), req.Currency)
+	if req.Description != "" {
+		payoutRequest.SetDescription(req.Description)
+	}
+
+	result, _, sdkErr := p.Client.PayoutApi.CreatePayout(context.Background()).
+		IdempotencyKey(req.ReferenceID).
+		CreatePayoutRequest(payoutRequest).
+		Execute()
+	if sdkErr != nil {
+		if isRejected(sdkErr) {
+			return nil, &RejectedError{Code: sdkErr.ErrorCode(), Message: xenditError(sdkErr).Error()}
+		}
+		return nil, xenditError(sdkErr)
+	}
+
+	return toPayout(result.Payout), nil
+}
+
+func (p *XenditProvider) GetPayout(payoutID string) (*Payout, error) {
+	result, _, sdkErr := p.Client.PayoutApi.GetPayoutById(context.Background(), payoutID).Execute()
+	if sdkErr != nil {
+		return nil, xenditError(sdkErr)
+	}
+
+	return toPayout(result.Payout), nil
+}
+
+func toPayout(xenditPayout *payout.Payout) *Payout {
+	result := &Payout{
+		ID:          xenditPayout.GetId(),
+		ReferenceID: xenditPayout.GetReferenceId(),
+		Amount:      float64(xenditPayout.GetAmount()),
+		Status:      PayoutStatusPending,
+		FailureCode: xenditPayout.GetFailureCode(),
+	}
+
+	switch xenditPayout.GetStatus() {
+	case "SUCCEEDED":
+		result.Status = PayoutStatusSucceeded
+	case "FAILED", "CANCELLED", "REVERSED":
+		result.Status = PayoutStatusFailed
+	}
+
+	return result
+}
+
+// isRejected reports whether Xendit refused the request itself. Timeouts, conflicts of a request still in progress,
+// rate limits and server errors may still have created the payout.
+func isRejected(err *common.XenditSdkError) bool {
+	status, convErr := strconv.Atoi(err.Status())
+	if convErr != nil {
+		return false
+	}
+
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 400 && status < 500
+}
+
+func xenditError(err *common.XenditSdkError) error {
+	return fmt.Errorf("xendit: %s (%s)", err.Error(), err.ErrorCode())
+}
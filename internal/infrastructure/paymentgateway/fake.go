@@ -0,0 +1,208 @@
+package paymentgateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/spf13/viper"
+)
+
+// FakeProvider keeps invoices in memory so the booking and payment flow can run without Xendit credentials.
+// Invoices are settled by posting an invoice callback payload to the payment callback endpoint, or by calling Pay.
+type FakeProvider struct {
+	BaseURL       string
+	CallbackToken string
+
+	mu       sync.Mutex
+	invoices map[string]*Invoice
+	refunds  map[string]*Refund
+}
+
+func NewFakeProvider() *FakeProvider {
+	baseURL := viper.GetString("payment.fake.base_url")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000/fake-payments"
+	}
+
+	return &FakeProvider{
+		BaseURL:       baseURL,
+		CallbackToken: viper.GetString("payment.fake.callback_token"),
+		invoices:      make(map[string]*Invoice),
+		refunds:       make(map[string]*Refund),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateInvoice(req *CreateInvoiceRequest) (*Invoice, error) {
+	if req.ExternalID == "" {
+		return nil, fmt.Errorf("fake: external id is required")
+	}
+	if req.Amount < 0 {
+		return nil, fmt.Errorf("fake: amount must not be negative")
+	}
+
+	duration := req.Duration
+	if duration == 0 {
+		duration = 24 * time.Hour
+	}
+
+	id := "fake-inv-" + pkg.RandomString(12)
+	paymentInvoice := &Invoice{
+		ID:         id,
+		ExternalID: req.ExternalID,
+		Amount:     req.Amount,
+		Status:     InvoiceStatusPending,
+		InvoiceURL: fmt.Sprintf("%s/%s", p.BaseURL, id),
+		ExpiryDate: pkg.GetCurrentTime().Add(duration),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.invoices[id] = paymentInvoice
+
+	result := *paymentInvoice
+	return &result, nil
+}
+
+func (p *FakeProvider) GetInvoice(invoiceID string) (*Invoice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paymentInvoice, ok := p.invoices[invoiceID]
+	if !ok {
+		return nil, fmt.Errorf("fake: %w: %s", ErrInvoiceNotFound, invoiceID)
+	}
+
+	if paymentInvoice.Status == InvoiceStatusPending && paymentInvoice.ExpiryDate.Before(pkg.GetCurrentTime()) {
+		paymentInvoice.Status = InvoiceStatusExpired
+	}
+
+	result := *paymentInvoice
+	return &result, nil
+}
+
+func (p *FakeProvider) ExpireInvoice(invoiceID string) (*Invoice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paymentInvoice, ok := p.invoices[invoiceID]
+	if !ok {
+		return nil, fmt.Errorf("fake: invoice %s not found", invoiceID)
+	}
+
+	if paymentInvoice.IsPaid() {
+		return nil, fmt.Errorf("fake: invoice %s is already paid", invoiceID)
+	}
+
+	paymentInvoice.Status = InvoiceStatusExpired
+
+	result := *paymentInvoice
+	return &result, nil
+}
+
+func (p *FakeProvider) Refund(req *RefundRequest) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paymentInvoice, ok := p.invoices[req.InvoiceID]
+	if !ok {
+		return nil, fmt.Errorf("fake: invoice %s not found", req.InvoiceID)
+	}
+
+	if !paymentInvoice.IsPaid() {
+		return nil, fmt.Errorf("fake: invoice %s is not paid", req.InvoiceID)
+	}
+
+	refunded := 0.0
+	for _, r := range p.refunds {
+		if r.InvoiceID == req.InvoiceID {
+			refunded += r.Amount
+		}
+	}
+	if refunded+req.Amount > paymentInvoice.PaidAmount {
+		return nil, fmt.Errorf("fake: refund amount exceeds paid amount")
+	}
+
+	paymentRefund := &Refund{
+		ID:        "fake-rfd-" + pkg.RandomString(12),
+		InvoiceID: req.InvoiceID,
+		Amount:    req.Amount,
+		Status:    "SUCCEEDED",
+	}
+	p.refunds[paymentRefund.ID] = paymentRefund
+
+	result := *paymentRefund
+	return &result, nil
+}
+
+func (p *FakeProvider) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	if p.CallbackToken != "" && !validCallbackToken(p.CallbackToken, header.Get("X-Callback-Token")) {
+		return nil, ErrInvalidWebhookToken
+	}
+
+	var callback struct {
+		ID         string  `json:"id"`
+		ExternalID string  `json:"external_id"`
+		Status     string  `json:"status"`
+		PaidAmount float64 `json:"paid_amount"`
+	}
+	err := json.Unmarshal(body, &callback)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paymentInvoice, ok := p.invoices[callback.ID]
+	if !ok {
+		return nil, fmt.Errorf("fake: invoice %s not found", callback.ID)
+	}
+
+	paymentInvoice.Status = callback.Status
+	if paymentInvoice.IsPaid() {
+		paymentInvoice.PaidAmount = paymentInvoice.Amount
+		if callback.PaidAmount != 0 {
+			paymentInvoice.PaidAmount = callback.PaidAmount
+		}
+	}
+
+	return &WebhookEvent{
+		ID:         fmt.Sprintf("%s:%s", paymentInvoice.ID, paymentInvoice.Status),
+		InvoiceID:  paymentInvoice.ID,
+		ExternalID: paymentInvoice.ExternalID,
+		Status:     paymentInvoice.Status,
+		Amount:     paymentInvoice.Amount,
+		PaidAmount: paymentInvoice.PaidAmount,
+		Payload:    body,
+	}, nil
+}
+
+// Pay marks an invoice as paid and returns the webhook payload Xendit would have delivered for it.
+func (p *FakeProvider) Pay(invoiceID string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paymentInvoice, ok := p.invoices[invoiceID]
+	if !ok {
+		return nil, fmt.Errorf("fake: invoice %s not found", invoiceID)
+	}
+
+	paymentInvoice.Status = InvoiceStatusPaid
+	paymentInvoice.PaidAmount = paymentInvoice.Amount
+
+	return json.Marshal(map[string]interface{}{
+		"id":          paymentInvoice.ID,
+		"external_id": paymentInvoice.ExternalID,
+		"status":      paymentInvoice.Status,
+		"amount":      paymentInvoice.Amount,
+		"paid_amount": paymentInvoice.PaidAmount,
+	})
+}
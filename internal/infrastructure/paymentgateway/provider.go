@@ -0,0 +1,121 @@
+package paymentgateway
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	InvoiceStatusPending = "PENDING"
+	InvoiceStatusPaid    = "PAID"
+	InvoiceStatusSettled = "SETTLED"
+	InvoiceStatusExpired = "EXPIRED"
+)
+
+var (
+	ErrInvalidWebhookToken = errors.New("invalid webhook token")
+	ErrInvoiceNotFound     = errors.New("invoice not found")
+)
+
+// PaymentProvider is the contract every payment gateway used by the booking flow must satisfy.
+type PaymentProvider interface {
+	Name() string
+	CreateInvoice(req *CreateInvoiceRequest) (*Invoice, error)
+	GetInvoice(invoiceID string) (*Invoice, error)
+	ExpireInvoice(invoiceID string) (*Invoice, error)
+	Refund(req *RefundRequest) (*Refund, error)
+	ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
+
+type InvoiceItem struct {
+	Name        string  `json:"name"`
+	Price       float64 `json:"price"`
+	Quantity    float64 `json:"quantity"`
+	ReferenceID string  `json:"reference_id,omitempty"`
+}
+
+type InvoiceFee struct {
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
+}
+
+type CreateInvoiceRequest struct {
+	ExternalID         string
+	Amount             float64
+	Currency           string
+	Description        string
+	PayerEmail         string
+	CustomerID         string
+	CustomerName       string
+	SuccessRedirectURL string
+	Duration           time.Duration
+	Items              []InvoiceItem
+	Fees               []InvoiceFee
+}
+
+type Invoice struct {
+	ID         string    `json:"id"`
+	ExternalID string    `json:"external_id"`
+	Amount     float64   `json:"amount"`
+	PaidAmount float64   `json:"paid_amount"`
+	Status     string    `json:"status"`
+	InvoiceURL string    `json:"invoice_url"`
+	ExpiryDate time.Time `json:"expiry_date"`
+}
+
+type RefundRequest struct {
+	InvoiceID   string
+	ReferenceID string
+	Amount      float64
+	Reason      string
+}
+
+type Refund struct {
+	ID        string  `json:"id"`
+	InvoiceID string  `json:"invoice_id"`
+	Amount    float64 `json:"amount"`
+	Status    string  `json:"status"`
+}
+
+type WebhookEvent struct {
+	ID         string  `json:"id"`
+	InvoiceID  string  `json:"invoice_id"`
+	ExternalID string  `json:"external_id"`
+	Status     string  `json:"status"`
+	Amount     float64 `json:"amount"`
+	PaidAmount float64 `json:"paid_amount"`
+	Payload    []byte  `json:"-"`
+}
+
+func (i *Invoice) IsPaid() bool {
+	return i.Status == InvoiceStatusPaid || i.Status == InvoiceStatusSettled
+}
+
+func (e *WebhookEvent) IsPaid() bool {
+	return e.Status == InvoiceStatusPaid || e.Status == InvoiceStatusSettled
+}
+
+func NewPaymentProvider() PaymentProvider {
+	provider := strings.ToLower(viper.GetString("payment.provider"))
+	switch provider {
+	case "fake":
+		logrus.Warn("Using fake payment provider, invoices are not charged")
+		return NewFakeProvider()
+	case "", "xendit":
+		return NewXenditProvider(NewXendit())
+	default:
+		logrus.Fatalf("Unknown payment provider: %s", provider)
+		return nil
+	}
+}
+
+// validCallbackToken compares the tokens in constant time so the expected token cannot be guessed from response timings.
+func validCallbackToken(expected string, received string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(received)) == 1
+}
@@ -1,10 +1,179 @@
 package paymentgateway
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
 	"github.com/spf13/viper"
 	"github.com/xendit/xendit-go/v6"
+	"github.com/xendit/xendit-go/v6/common"
+	"github.com/xendit/xendit-go/v6/invoice"
+	"github.com/xendit/xendit-go/v6/refund"
 )
 
 func NewXendit() *xendit.APIClient {
 	return xendit.NewClient(viper.GetString("xendit.secret_key"))
 }
+
+type XenditProvider struct {
+	Client        *xendit.APIClient
+	CallbackToken string
+}
+
+func NewXenditProvider(client *xendit.APIClient) *XenditProvider {
+	return &XenditProvider{
+		Client:        client,
+		CallbackToken: viper.GetString("xendit.callbak_token"),
+	}
+}
+
+func (p *XenditProvider) Name() string {
+	return "xendit"
+}
+
+func (p *XenditProvider) CreateInvoice(req *CreateInvoiceRequest) (*Invoice, error) {
+	invoiceRequest := *invoice.NewCreateInvoiceRequest(req.ExternalID, req.Amount)
+	// Walk-in guests pay without an account
+	if req.PayerEmail != "" {
+		invoiceRequest.SetPayerEmail(req.PayerEmail)
+	}
+	invoiceRequest.SetDescription(req.Description)
+	invoiceRequest.SetCurrency(req.Currency)
+	if req.SuccessRedirectURL != "" {
+		invoiceRequest.SetSuccessRedirectUrl(req.SuccessRedirectURL)
+	}
+	if req.Duration > 0 {
+		invoiceRequest.SetInvoiceDuration(strconv.Itoa(int(req.Duration.Seconds())))
+	}
+
+	customer := *invoice.NewCustomerObject()
+	if req.PayerEmail != "" {
+		customer.SetEmail(req.PayerEmail)
+	}
+	customer.SetGivenNames(req.CustomerName)
+	customer.SetId(req.CustomerID)
+	invoiceRequest.SetCustomer(customer)
+
+	items := make([]invoice.InvoiceItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		invoiceItem := invoice.InvoiceItem{
+			Name:     item.Name,
+			Price:    float32(item.Price),
+			Quantity: float32(item.Quantity),
+		}
+		if item.ReferenceID != "" {
+			invoiceItem.SetReferenceId(item.ReferenceID)
+		}
+		items = append(items, invoiceItem)
+	}
+	invoiceRequest.SetItems(items)
+
+	if len(req.Fees) > 0 {
+		fees := make([]invoice.InvoiceFee, 0, len(req.Fees))
+		for _, fee := range req.Fees {
+			fees = append(fees, invoice.InvoiceFee{
+				Type:  fee.Type,
+				Value: float32(fee.Value),
+			})
+		}
+		invoiceRequest.SetFees(fees)
+	}
+
+	paymentInvoice, _, sdkErr := p.Client.InvoiceApi.CreateInvoice(context.Background()).
+		CreateInvoiceRequest(invoiceRequest).
+		Execute()
+	if sdkErr != nil {
+		return nil, xenditError(sdkErr)
+	}
+
+	return toInvoice(paymentInvoice), nil
+}
+
+func (p *XenditProvider) GetInvoice(invoiceID string) (*Invoice, error) {
+	paymentInvoice, _, sdkErr := p.Client.InvoiceApi.GetInvoiceById(context.Background(), invoiceID).Execute()
+	if sdkErr != nil {
+		if sdkErr.ErrorCode() == "INVOICE_NOT_FOUND_ERROR" {
+			return nil, fmt.Errorf("xendit: %w: %s", ErrInvoiceNotFound, invoiceID)
+		}
+		return nil, xenditError(sdkErr)
+	}
+
+	return toInvoice(paymentInvoice), nil
+}
+
+func (p *XenditProvider) ExpireInvoice(invoiceID string) (*Invoice, error) {
+	paymentInvoice, _, sdkErr := p.Client.InvoiceApi.ExpireInvoice(context.Background(), invoiceID).Execute()
+	if sdkErr != nil {
+		return nil, xenditError(sdkErr)
+	}
+
+	return toInvoice(paymentInvoice), nil
+}
+
+func (p *XenditProvider) Refund(req *RefundRequest) (*Refund, error) {
+	createRefund := *refund.NewCreateRefund()
+	createRefund.SetInvoiceId(req.InvoiceID)
+	createRefund.SetReferenceId(req.ReferenceID)
+	createRefund.SetAmount(req.Amount)
+	createRefund.SetReason(req.Reason)
+
+	paymentRefund, _, sdkErr := p.Client.RefundApi.CreateRefund(context.Background()).
+		IdempotencyKey(req.ReferenceID).
+		CreateRefund(createRefund).
+		Execute()
+	if sdkErr != nil {
+		return nil, xenditError(sdkErr)
+	}
+
+	return &Refund{
+		ID:        paymentRefund.GetId(),
+		InvoiceID: req.InvoiceID,
+		Amount:    paymentRefund.GetAmount(),
+		Status:    "PENDING",
+	}, nil
+}
+
+func (p *XenditProvider) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	if !validCallbackToken(p.CallbackToken, header.Get("X-Callback-Token")) {
+		return nil, ErrInvalidWebhookToken
+	}
+
+	var callback invoice.InvoiceCallback
+	err := json.Unmarshal(body, &callback)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+
+	return &WebhookEvent{
+		ID:         fmt.Sprintf("%s:%s", callback.Id, callback.Status),
+		InvoiceID:  callback.Id,
+		ExternalID: callback.ExternalId,
+		Status:     callback.Status,
+		Amount:     callback.Amount,
+		PaidAmount: callback.GetPaidAmount(),
+		Payload:    body,
+	}, nil
+}
+
+func toInvoice(paymentInvoice *invoice.Invoice) *Invoice {
+	result := &Invoice{
+		ID:         paymentInvoice.GetId(),
+		ExternalID: paymentInvoice.ExternalId,
+		Amount:     paymentInvoice.Amount,
+		Status:     string(paymentInvoice.Status),
+		InvoiceURL: paymentInvoice.InvoiceUrl,
+		ExpiryDate: paymentInvoice.ExpiryDate,
+	}
+	if result.IsPaid() {
+		result.PaidAmount = result.Amount
+	}
+
+	return result
+}
+
+func xenditError(err *common.XenditSdkError) error {
+	return fmt.Errorf("xendit: %s (%s)", err.Error(), err.ErrorCode())
+}
@@ -0,0 +1,2 @@
+-- Add down migration script here
+ALTER TABLE bookings DROP COLUMN payment_invoice_id;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Add up migration script here
+ALTER TABLE bookings ADD COLUMN payment_invoice_id VARCHAR(255) DEFAULT NULL;
\ No newline at end of file
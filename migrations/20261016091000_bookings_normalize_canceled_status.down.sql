@@ -0,0 +1,2 @@
+-- Add down migration script here
+-- CANCELLED was never a valid status, nothing to restore
\ No newline at end of file
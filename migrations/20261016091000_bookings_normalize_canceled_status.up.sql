@@ -0,0 +1,2 @@
+-- Add up migration script here
+UPDATE bookings SET status = 'CANCELED' WHERE status = 'CANCELLED';
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Add down migration script here
+ALTER TABLE bookings DROP CONSTRAINT bookings_slot_no_overlap;
\ No newline at end of file
@@ -0,0 +1,35 @@
+-- Add up migration script here
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Unpaid bookings overlapping a paid one, or an older unpaid one, on the same slot lost the race, they are expired
+UPDATE bookings b
+SET status = 'EXPIRED', updated_at = CURRENT_TIMESTAMP
+WHERE b.status = 'UNPAID' AND b.deleted_at IS NULL
+AND EXISTS (
+  SELECT 1 FROM bookings o
+  WHERE o.slot_id = b.slot_id AND o.id <> b.id AND o.deleted_at IS NULL
+  AND tsrange(o.start_at, o.end_at, '[)') && tsrange(b.start_at, b.end_at, '[)')
+  AND (o.status = 'PAID' OR (o.status = 'UNPAID' AND o.id < b.id))
+);
+
+-- Overlapping paid bookings cannot be resolved automatically, they must be moved or refunded first
+DO $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM bookings b
+    JOIN bookings o ON o.slot_id = b.slot_id AND o.id > b.id
+    WHERE b.status = 'PAID' AND o.status = 'PAID' AND b.deleted_at IS NULL AND o.deleted_at IS NULL
+    AND tsrange(o.start_at, o.end_at, '[)') && tsrange(b.start_at, b.end_at, '[)')
+  ) THEN
+    RAISE EXCEPTION 'paid bookings overlap on the same slot, resolve them before adding bookings_slot_no_overlap';
+  END IF;
+END
+$$;
+
+-- Active bookings (UNPAID, PAID) must not overlap on the same slot
+ALTER TABLE bookings
+ADD CONSTRAINT bookings_slot_no_overlap
+EXCLUDE USING gist (
+  slot_id WITH =,
+  tsrange(start_at, end_at, '[)') WITH &&
+) WHERE (status IN ('UNPAID', 'PAID') AND deleted_at IS NULL);
\ No newline at end of file
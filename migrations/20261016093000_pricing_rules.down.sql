@@ -0,0 +1,2 @@
+-- Add down migration script here
+DROP TABLE pricing_rules;
\ No newline at end of file
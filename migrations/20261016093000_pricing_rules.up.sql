@@ -0,0 +1,24 @@
+-- Add up migration script here
+CREATE TABLE pricing_rules (
+  id SERIAL PRIMARY KEY,
+  parking_id INT NOT NULL,
+  slot_id INT DEFAULT NULL,
+  name VARCHAR(255) NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  days_of_week JSONB DEFAULT NULL,
+  start_time VARCHAR(5) DEFAULT NULL,
+  end_time VARCHAR(5) DEFAULT NULL,
+  starts_at TIMESTAMP DEFAULT NULL,
+  ends_at TIMESTAMP DEFAULT NULL,
+  rate DECIMAL(10,2) NOT NULL DEFAULT 0,
+  max_fee DECIMAL(10,2) NOT NULL DEFAULT 0,
+  priority INT NOT NULL DEFAULT 0,
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP,
+  FOREIGN KEY (parking_id) REFERENCES parkings(id),
+  FOREIGN KEY (slot_id) REFERENCES parking_slots(id)
+);
+
+CREATE INDEX idx_pricing_rules_parking_id ON pricing_rules (parking_id);
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Add down migration script here
+DROP TABLE booking_extensions;
\ No newline at end of file
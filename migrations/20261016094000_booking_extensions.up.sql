@@ -0,0 +1,21 @@
+-- Add up migration script here
+CREATE TABLE booking_extensions (
+  id SERIAL PRIMARY KEY,
+  booking_id INT NOT NULL,
+  previous_end_at TIMESTAMP NOT NULL,
+  new_end_at TIMESTAMP NOT NULL,
+  additional_hours INT NOT NULL,
+  fee DECIMAL(10, 2) NOT NULL,
+  payment_reference VARCHAR(255) NOT NULL,
+  payment_invoice_id VARCHAR(255) DEFAULT NULL,
+  payment_link VARCHAR(255) DEFAULT NULL,
+  payment_expired_at TIMESTAMP NULL DEFAULT NULL,
+  status VARCHAR(255) NOT NULL,
+  note VARCHAR(255) DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (booking_id) REFERENCES bookings (id)
+);
+
+CREATE INDEX idx_booking_extensions_booking_id ON booking_extensions (booking_id);
+CREATE INDEX idx_booking_extensions_payment_reference ON booking_extensions (payment_reference);
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- Add down migration script here
+DROP INDEX idx_overtime_reference;
+
+ALTER TABLE bookings DROP COLUMN overtime_paid_at;
+ALTER TABLE bookings DROP COLUMN overtime_link;
+ALTER TABLE bookings DROP COLUMN overtime_invoice_id;
+ALTER TABLE bookings DROP COLUMN overtime_reference;
+ALTER TABLE bookings DROP COLUMN overtime_fee;
+ALTER TABLE bookings DROP COLUMN overtime_minutes;
+
+ALTER TABLE parkings DROP COLUMN overtime_grace_minutes;
+ALTER TABLE parkings DROP COLUMN overtime_rate;
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- Add up migration script here
+ALTER TABLE parkings
+ADD COLUMN overtime_rate DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE parkings
+ADD COLUMN overtime_grace_minutes INT NOT NULL DEFAULT 15;
+
+ALTER TABLE bookings
+ADD COLUMN overtime_minutes INT NOT NULL DEFAULT 0;
+
+ALTER TABLE bookings
+ADD COLUMN overtime_fee DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE bookings
+ADD COLUMN overtime_reference VARCHAR(255) DEFAULT NULL;
+
+ALTER TABLE bookings
+ADD COLUMN overtime_invoice_id VARCHAR(255) DEFAULT NULL;
+
+ALTER TABLE bookings
+ADD COLUMN overtime_link VARCHAR(255) DEFAULT NULL;
+
+ALTER TABLE bookings
+ADD COLUMN overtime_paid_at TIMESTAMP NULL DEFAULT NULL;
+
+CREATE INDEX idx_overtime_reference ON bookings (overtime_reference);
\ No newline at end of file
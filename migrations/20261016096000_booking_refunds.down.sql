@@ -0,0 +1,5 @@
+-- Add down migration script here
+DROP TABLE refunds;
+
+ALTER TABLE parkings DROP COLUMN cancel_partial_refund_percent;
+ALTER TABLE parkings DROP COLUMN cancel_full_refund_hours;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- Add up migration script here
+ALTER TABLE parkings
+ADD COLUMN cancel_full_refund_hours INT NOT NULL DEFAULT 24;
+
+ALTER TABLE parkings
+ADD COLUMN cancel_partial_refund_percent INT NOT NULL DEFAULT 50;
+
+CREATE TABLE refunds (
+  id SERIAL PRIMARY KEY,
+  booking_id INT NOT NULL,
+  user_id INT NOT NULL,
+  parking_id INT NOT NULL,
+  payment_invoice_id VARCHAR(255) NOT NULL,
+  reference_id VARCHAR(255) NOT NULL,
+  provider_refund_id VARCHAR(255) DEFAULT NULL,
+  policy VARCHAR(32) NOT NULL,
+  paid_amount DECIMAL(10, 2) NOT NULL,
+  amount DECIMAL(10, 2) NOT NULL,
+  reason VARCHAR(255) DEFAULT NULL,
+  status VARCHAR(32) NOT NULL,
+  note VARCHAR(255) DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (booking_id) REFERENCES bookings (id),
+  FOREIGN KEY (user_id) REFERENCES users (id),
+  FOREIGN KEY (parking_id) REFERENCES parkings (id)
+);
+
+CREATE INDEX idx_refunds_booking_id ON refunds (booking_id);
+CREATE INDEX idx_refunds_user_id ON refunds (user_id);
\ No newline at end of file
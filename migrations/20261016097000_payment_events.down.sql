@@ -0,0 +1,2 @@
+-- Add down migration script here
+DROP TABLE payment_events;
\ No newline at end of file
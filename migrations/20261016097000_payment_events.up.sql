@@ -0,0 +1,25 @@
+-- Add up migration script here
+CREATE TABLE payment_events (
+  id SERIAL PRIMARY KEY,
+  event_id VARCHAR(255) NOT NULL UNIQUE,
+  provider VARCHAR(32) NOT NULL,
+  invoice_id VARCHAR(255) NOT NULL,
+  external_id VARCHAR(255) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  paid_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  payload JSONB,
+  processing_status VARCHAR(32) NOT NULL,
+  booking_id INT DEFAULT NULL,
+  error TEXT DEFAULT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  delivery_count INT NOT NULL DEFAULT 1,
+  last_delivered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  processed_at TIMESTAMP NULL DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (booking_id) REFERENCES bookings (id)
+);
+
+CREATE INDEX idx_payment_events_external_id ON payment_events (external_id);
+CREATE INDEX idx_payment_events_processing_status ON payment_events (processing_status);
\ No newline at end of file
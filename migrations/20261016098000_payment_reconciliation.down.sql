@@ -0,0 +1,4 @@
+-- Add down migration script here
+DROP TABLE reconciliation_reports;
+
+ALTER TABLE payment_events DROP COLUMN source;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- Add up migration script here
+ALTER TABLE payment_events
+ADD COLUMN source VARCHAR(32) NOT NULL DEFAULT 'WEBHOOK';
+
+CREATE TABLE reconciliation_reports (
+  id SERIAL PRIMARY KEY,
+  provider VARCHAR(32) NOT NULL,
+  started_at TIMESTAMP NOT NULL,
+  finished_at TIMESTAMP NOT NULL,
+  checked INT NOT NULL DEFAULT 0,
+  fixed INT NOT NULL DEFAULT 0,
+  amount_mismatches INT NOT NULL DEFAULT 0,
+  unknown_invoices INT NOT NULL DEFAULT 0,
+  errors INT NOT NULL DEFAULT 0,
+  items JSONB,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- Add down migration script here
+ALTER TABLE parkings
+ADD COLUMN available_earnings DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE parkings
+ADD COLUMN withdrawn_earnings DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE parkings
+ADD COLUMN total_earnings DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+UPDATE parkings SET
+  available_earnings = COALESCE((SELECT SUM(amount) FROM ledger_entries WHERE parking_id = parkings.id AND account = 'PARKING_AVAILABLE'), 0),
+  withdrawn_earnings = COALESCE((SELECT SUM(amount) FROM ledger_entries WHERE parking_id = parkings.id AND account = 'PARKING_WITHDRAWN'), 0);
+
+UPDATE parkings SET total_earnings = available_earnings + withdrawn_earnings;
+
+DROP TABLE ledger_entries;
\ No newline at end of file
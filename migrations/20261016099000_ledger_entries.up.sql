@@ -0,0 +1,67 @@
+-- Add up migration script here
+CREATE TABLE ledger_entries (
+  id SERIAL PRIMARY KEY,
+  transaction_id VARCHAR(32) NOT NULL,
+  parking_id INT NOT NULL,
+  booking_id INT DEFAULT NULL,
+  account VARCHAR(32) NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  amount DECIMAL(12, 2) NOT NULL,
+  reference VARCHAR(255) DEFAULT NULL,
+  description VARCHAR(255) DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (parking_id) REFERENCES parkings (id),
+  FOREIGN KEY (booking_id) REFERENCES bookings (id)
+);
+
+CREATE INDEX idx_ledger_entries_parking_id ON ledger_entries (parking_id, account);
+CREATE INDEX idx_ledger_entries_booking_id ON ledger_entries (booking_id);
+CREATE INDEX idx_ledger_entries_transaction_id ON ledger_entries (transaction_id);
+
+-- Rebuild the earnings of every parking from its paid bookings, the available_earnings counter was never kept up to date
+CREATE TEMPORARY TABLE opening_balances AS
+SELECT
+  parkings.id AS parking_id,
+  COALESCE((
+    SELECT SUM(bookings.total_fee + CASE WHEN bookings.overtime_paid_at IS NOT NULL THEN bookings.overtime_fee ELSE 0 END)
+    FROM bookings
+    WHERE bookings.parking_id = parkings.id
+      AND (
+        bookings.status IN ('PAID', 'OVERTIME_UNPAID', 'COMPLETED')
+        OR EXISTS (SELECT 1 FROM refunds WHERE refunds.booking_id = bookings.id AND refunds.status <> 'FAILED')
+      )
+  ), 0) - COALESCE((
+    SELECT SUM(refunds.amount) FROM refunds WHERE refunds.parking_id = parkings.id AND refunds.status <> 'FAILED'
+  ), 0) AS earned,
+  parkings.withdrawn_earnings AS withdrawn,
+  parkings.available_earnings AS counted
+FROM parkings;
+
+INSERT INTO ledger_entries (transaction_id, parking_id, account, type, amount, description)
+SELECT 'LTX-OPENING-' || parking_id, parking_id, 'PARKING_AVAILABLE', 'OPENING_BALANCE', earned - withdrawn, 'Opening balance'
+FROM opening_balances;
+
+INSERT INTO ledger_entries (transaction_id, parking_id, account, type, amount, description)
+SELECT 'LTX-OPENING-' || parking_id, parking_id, 'PARKING_WITHDRAWN', 'OPENING_BALANCE', withdrawn, 'Opening balance'
+FROM opening_balances;
+
+INSERT INTO ledger_entries (transaction_id, parking_id, account, type, amount, description)
+SELECT 'LTX-OPENING-' || parking_id, parking_id, 'GATEWAY_CLEARING', 'OPENING_BALANCE', -earned, 'Opening balance'
+FROM opening_balances;
+
+-- Keep what the old counter claimed on top of the rebuilt balance in suspense for review
+INSERT INTO ledger_entries (transaction_id, parking_id, account, type, amount, description)
+SELECT 'LTX-OPENING-ADJ-' || parking_id, parking_id, 'SUSPENSE', 'OPENING_ADJUSTMENT', counted - (earned - withdrawn), 'Difference against the available earnings counter'
+FROM opening_balances
+WHERE ABS(counted - (earned - withdrawn)) >= 0.005;
+
+INSERT INTO ledger_entries (transaction_id, parking_id, account, type, amount, description)
+SELECT 'LTX-OPENING-ADJ-' || parking_id, parking_id, 'GATEWAY_CLEARING', 'OPENING_ADJUSTMENT', (earned - withdrawn) - counted, 'Difference against the available earnings counter'
+FROM opening_balances
+WHERE ABS(counted - (earned - withdrawn)) >= 0.005;
+
+DROP TABLE opening_balances;
+
+ALTER TABLE parkings DROP COLUMN total_earnings;
+ALTER TABLE parkings DROP COLUMN available_earnings;
+ALTER TABLE parkings DROP COLUMN withdrawn_earnings;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- Add down migration script here
+DROP TABLE payout_status_histories;
+DROP TABLE payouts;
+DROP TABLE bank_accounts;
\ No newline at end of file
@@ -0,0 +1,53 @@
+-- Add up migration script here
+CREATE TABLE bank_accounts (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  bank_code VARCHAR(32) NOT NULL,
+  account_number VARCHAR(32) NOT NULL,
+  account_holder_name VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_bank_accounts_user_id ON bank_accounts (user_id);
+
+CREATE TABLE payouts (
+  id SERIAL PRIMARY KEY,
+  parking_id INT NOT NULL,
+  user_id INT NOT NULL,
+  bank_account_id INT NOT NULL,
+  reference VARCHAR(255) NOT NULL UNIQUE,
+  amount DECIMAL(12, 2) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  provider VARCHAR(32) NOT NULL,
+  provider_payout_id VARCHAR(255) DEFAULT NULL,
+  note VARCHAR(255) DEFAULT NULL,
+  reviewed_by INT DEFAULT NULL,
+  reviewed_at TIMESTAMP DEFAULT NULL,
+  completed_at TIMESTAMP DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (parking_id) REFERENCES parkings (id),
+  FOREIGN KEY (user_id) REFERENCES users (id),
+  FOREIGN KEY (bank_account_id) REFERENCES bank_accounts (id),
+  FOREIGN KEY (reviewed_by) REFERENCES users (id)
+);
+
+CREATE INDEX idx_payouts_parking_id ON payouts (parking_id, status);
+CREATE INDEX idx_payouts_status ON payouts (status);
+
+CREATE TABLE payout_status_histories (
+  id SERIAL PRIMARY KEY,
+  payout_id INT NOT NULL,
+  from_status VARCHAR(32) DEFAULT NULL,
+  to_status VARCHAR(32) NOT NULL,
+  actor_id INT DEFAULT NULL,
+  note VARCHAR(255) DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (payout_id) REFERENCES payouts (id),
+  FOREIGN KEY (actor_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_payout_status_histories_payout_id ON payout_status_histories (payout_id);
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Add down migration script here
+ALTER TABLE refunds DROP COLUMN commission_amount;
+ALTER TABLE bookings DROP COLUMN service_fee;
+ALTER TABLE bookings DROP COLUMN commission_fee;
+ALTER TABLE bookings DROP COLUMN commission_plan_id;
+ALTER TABLE parkings DROP COLUMN commission_plan_id;
+
+DROP TABLE commission_plans;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- Add up migration script here
+CREATE TABLE commission_plans (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  percentage DECIMAL(5, 2) NOT NULL DEFAULT 0,
+  flat_fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  service_fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  is_default BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+-- Only one plan can be the default
+CREATE UNIQUE INDEX idx_commission_plans_default ON commission_plans (is_default) WHERE is_default AND deleted_at IS NULL;
+
+ALTER TABLE parkings
+ADD COLUMN commission_plan_id INT DEFAULT NULL REFERENCES commission_plans (id);
+
+ALTER TABLE bookings
+ADD COLUMN commission_plan_id INT DEFAULT NULL REFERENCES commission_plans (id);
+
+ALTER TABLE bookings
+ADD COLUMN commission_fee DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE bookings
+ADD COLUMN service_fee DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE refunds
+ADD COLUMN commission_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Add down migration script here
+ALTER TABLE bookings DROP COLUMN voucher_discount;
+ALTER TABLE bookings DROP COLUMN voucher_id;
+
+DROP TABLE voucher_redemptions;
+DROP TABLE vouchers;
\ No newline at end of file
@@ -0,0 +1,38 @@
+-- Add up migration script here
+CREATE TABLE vouchers (
+  id SERIAL PRIMARY KEY,
+  code VARCHAR(32) NOT NULL UNIQUE,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  discount_type VARCHAR(20) NOT NULL,
+  discount_value DECIMAL(10, 2) NOT NULL,
+  max_discount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  min_spend DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  starts_at TIMESTAMP DEFAULT NULL,
+  ends_at TIMESTAMP DEFAULT NULL,
+  usage_limit INT NOT NULL DEFAULT 0,
+  usage_limit_per_user INT NOT NULL DEFAULT 0,
+  parking_ids JSONB NOT NULL DEFAULT '[]',
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE voucher_redemptions (
+  id SERIAL PRIMARY KEY,
+  voucher_id INT NOT NULL REFERENCES vouchers (id),
+  user_id INT NOT NULL REFERENCES users (id),
+  booking_id INT NOT NULL UNIQUE REFERENCES bookings (id),
+  discount DECIMAL(10, 2) NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_voucher_redemptions_voucher_user ON voucher_redemptions (voucher_id, user_id, status);
+
+ALTER TABLE bookings
+ADD COLUMN voucher_id INT DEFAULT NULL REFERENCES vouchers (id);
+
+ALTER TABLE bookings
+ADD COLUMN voucher_discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- Add down migration script here
+DROP TABLE subscription_invoices;
+DROP TABLE subscriptions;
+DROP TABLE subscription_plans;
\ No newline at end of file
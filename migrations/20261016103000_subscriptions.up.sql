@@ -0,0 +1,50 @@
+-- Add up migration script here
+CREATE TABLE subscription_plans (
+  id SERIAL PRIMARY KEY,
+  parking_id INT NOT NULL REFERENCES parkings (id),
+  name VARCHAR(255) NOT NULL,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  period VARCHAR(20) NOT NULL,
+  price DECIMAL(10, 2) NOT NULL,
+  days_of_week JSONB NOT NULL DEFAULT '[]',
+  start_time VARCHAR(5) NOT NULL DEFAULT '',
+  end_time VARCHAR(5) NOT NULL DEFAULT '',
+  capacity INT NOT NULL DEFAULT 0,
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE subscriptions (
+  id SERIAL PRIMARY KEY,
+  plan_id INT NOT NULL REFERENCES subscription_plans (id),
+  user_id INT NOT NULL REFERENCES users (id),
+  parking_id INT NOT NULL REFERENCES parkings (id),
+  plate_number VARCHAR(16) NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+  auto_renew BOOLEAN NOT NULL DEFAULT TRUE,
+  current_period_start TIMESTAMP DEFAULT NULL,
+  current_period_end TIMESTAMP DEFAULT NULL,
+  is_reminder_sent BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_subscriptions_parking_status ON subscriptions (parking_id, status);
+
+CREATE TABLE subscription_invoices (
+  id SERIAL PRIMARY KEY,
+  subscription_id INT NOT NULL REFERENCES subscriptions (id),
+  period_start TIMESTAMP NOT NULL,
+  period_end TIMESTAMP NOT NULL,
+  amount DECIMAL(10, 2) NOT NULL,
+  payment_reference VARCHAR(32) NOT NULL UNIQUE,
+  payment_invoice_id VARCHAR(255) NOT NULL DEFAULT '',
+  payment_link VARCHAR(255) NOT NULL DEFAULT '',
+  payment_expired_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  status VARCHAR(20) NOT NULL DEFAULT 'UNPAID',
+  paid_at TIMESTAMP DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
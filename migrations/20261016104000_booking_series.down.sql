@@ -0,0 +1,4 @@
+-- Add down migration script here
+ALTER TABLE bookings DROP COLUMN series_id;
+
+DROP TABLE booking_series;
\ No newline at end of file
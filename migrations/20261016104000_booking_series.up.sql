@@ -0,0 +1,31 @@
+-- Add up migration script here
+CREATE TABLE booking_series (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users (id),
+  parking_id INT NOT NULL REFERENCES parkings (id),
+  slot_id INT DEFAULT NULL REFERENCES parking_slots (id),
+  plate_number VARCHAR(16) NOT NULL,
+  frequency VARCHAR(20) NOT NULL,
+  interval INT NOT NULL DEFAULT 1,
+  days_of_week JSONB NOT NULL DEFAULT '[]',
+  start_time VARCHAR(5) NOT NULL,
+  end_time VARCHAR(5) NOT NULL,
+  start_date VARCHAR(10) NOT NULL,
+  end_date VARCHAR(10) NOT NULL,
+  total_fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  service_fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  payment_reference VARCHAR(255) NOT NULL,
+  payment_invoice_id VARCHAR(255) DEFAULT NULL,
+  payment_link VARCHAR(255) DEFAULT NULL,
+  payment_expired_at TIMESTAMP NULL DEFAULT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'UNPAID',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_booking_series_payment_reference ON booking_series (payment_reference);
+
+ALTER TABLE bookings
+ADD COLUMN series_id INT DEFAULT NULL REFERENCES booking_series (id);
+
+CREATE INDEX idx_bookings_series_id ON bookings (series_id);
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Add down migration script here
+ALTER TABLE parking_slots
+DROP COLUMN attributes;
+
+ALTER TABLE parkings
+DROP COLUMN slot_assignment;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Add up migration script here
+ALTER TABLE parkings
+ADD COLUMN slot_assignment VARCHAR(20) NOT NULL DEFAULT 'CLOSEST';
+
+ALTER TABLE parking_slots
+ADD COLUMN attributes JSONB NOT NULL DEFAULT '[]';
\ No newline at end of file
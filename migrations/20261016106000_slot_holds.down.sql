@@ -0,0 +1,2 @@
+-- Add down migration script here
+DROP TABLE slot_holds;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- Add up migration script here
+CREATE TABLE slot_holds (
+  id SERIAL PRIMARY KEY,
+  token VARCHAR(64) NOT NULL UNIQUE,
+  user_id INT NOT NULL REFERENCES users (id),
+  parking_id INT NOT NULL REFERENCES parkings (id),
+  slot_id INT NOT NULL REFERENCES parking_slots (id),
+  start_at TIMESTAMP NOT NULL,
+  end_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+  booking_id INT DEFAULT NULL REFERENCES bookings (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_slot_holds_slot_id_status ON slot_holds (slot_id, status);
+
+CREATE INDEX idx_slot_holds_user_id_status ON slot_holds (user_id, status);
\ No newline at end of file
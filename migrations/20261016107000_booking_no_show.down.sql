@@ -0,0 +1,8 @@
+-- Add down migration script here
+ALTER TABLE bookings
+DROP COLUMN no_show_at,
+DROP COLUMN arrived_at;
+
+ALTER TABLE parkings
+DROP COLUMN no_show_refund_percent,
+DROP COLUMN no_show_minutes;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- Add up migration script here
+ALTER TABLE parkings
+ADD COLUMN no_show_minutes INT NOT NULL DEFAULT 0,
+ADD COLUMN no_show_refund_percent INT NOT NULL DEFAULT 0;
+
+ALTER TABLE bookings
+ADD COLUMN arrived_at TIMESTAMP NULL DEFAULT NULL,
+ADD COLUMN no_show_at TIMESTAMP NULL DEFAULT NULL;
+
+-- Arrivals were not recorded before, bookings that already started are assumed to have arrived
+UPDATE bookings SET arrived_at = start_at WHERE status IN ('PAID', 'OVERTIME_UNPAID', 'COMPLETED') AND start_at <= CURRENT_TIMESTAMP;
\ No newline at end of file
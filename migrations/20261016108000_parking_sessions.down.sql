@@ -0,0 +1,2 @@
+-- Add down migration script here
+DROP TABLE parking_sessions;
\ No newline at end of file
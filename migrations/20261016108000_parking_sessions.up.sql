@@ -0,0 +1,26 @@
+-- Add up migration script here
+CREATE TABLE parking_sessions (
+  id SERIAL PRIMARY KEY,
+  parking_id INT NOT NULL REFERENCES parkings (id),
+  slot_id INT NOT NULL REFERENCES parking_slots (id),
+  plate_number VARCHAR(20) NOT NULL,
+  entered_at TIMESTAMP NOT NULL,
+  exited_at TIMESTAMP DEFAULT NULL,
+  total_hours INT NOT NULL DEFAULT 0,
+  fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  commission_fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  payment_reference VARCHAR(64) DEFAULT NULL,
+  payment_invoice_id VARCHAR(255) DEFAULT NULL,
+  payment_link VARCHAR(255) DEFAULT NULL,
+  payment_expired_at TIMESTAMP DEFAULT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+  paid_at TIMESTAMP DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_parking_sessions_open_slot_id ON parking_sessions (slot_id) WHERE status = 'OPEN';
+
+CREATE INDEX idx_parking_sessions_payment_reference ON parking_sessions (payment_reference);
+
+CREATE INDEX idx_parking_sessions_parking_id_status ON parking_sessions (parking_id, status);
\ No newline at end of file
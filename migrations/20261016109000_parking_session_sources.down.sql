@@ -0,0 +1,12 @@
+-- Add down migration script here
+DROP INDEX idx_parking_sessions_parking_id_entered_at;
+
+DROP INDEX idx_parking_sessions_booking_id;
+
+ALTER TABLE parking_sessions ALTER COLUMN plate_number DROP DEFAULT;
+
+ALTER TABLE parking_sessions
+DROP COLUMN exit_source,
+DROP COLUMN source,
+DROP COLUMN subscription_id,
+DROP COLUMN booking_id;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- Add up migration script here
+ALTER TABLE parking_sessions
+ADD COLUMN booking_id INT DEFAULT NULL REFERENCES bookings (id),
+ADD COLUMN subscription_id INT DEFAULT NULL REFERENCES subscriptions (id),
+ADD COLUMN source VARCHAR(20) NOT NULL DEFAULT 'CAMERA',
+ADD COLUMN exit_source VARCHAR(20) DEFAULT NULL;
+
+ALTER TABLE parking_sessions ALTER COLUMN plate_number SET DEFAULT '';
+
+CREATE INDEX idx_parking_sessions_booking_id ON parking_sessions (booking_id);
+
+CREATE INDEX idx_parking_sessions_parking_id_entered_at ON parking_sessions (parking_id, entered_at);
\ No newline at end of file
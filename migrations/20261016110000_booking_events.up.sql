@@ -0,0 +1,15 @@
+-- Add up migration script here
+CREATE TABLE booking_events (
+  id SERIAL PRIMARY KEY,
+  booking_id INT NOT NULL REFERENCES bookings (id),
+  type VARCHAR(32) NOT NULL,
+  from_status VARCHAR(20) NOT NULL DEFAULT '',
+  to_status VARCHAR(20) NOT NULL DEFAULT '',
+  actor_type VARCHAR(20) NOT NULL,
+  actor_id INT DEFAULT NULL REFERENCES users (id),
+  source VARCHAR(20) NOT NULL,
+  payload JSONB DEFAULT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_booking_events_booking_id_created_at ON booking_events (booking_id, created_at);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- Add down migration script here
+ALTER TABLE subscription_invoices
+DROP COLUMN note,
+DROP COLUMN provider_refund_id;
\ No newline at end of file
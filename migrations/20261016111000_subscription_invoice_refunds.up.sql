@@ -0,0 +1,4 @@
+-- Add up migration script here
+ALTER TABLE subscription_invoices
+ADD COLUMN provider_refund_id VARCHAR(255) DEFAULT NULL,
+ADD COLUMN note VARCHAR(255) DEFAULT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Add down migration script here
+ALTER TABLE parking_slots DROP COLUMN device_secret;
\ No newline at end of file
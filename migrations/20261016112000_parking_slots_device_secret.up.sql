@@ -0,0 +1,3 @@
+-- Add up migration script here
+ALTER TABLE parking_slots
+ADD COLUMN device_secret VARCHAR(255) DEFAULT NULL;
\ No newline at end of file
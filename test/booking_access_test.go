@@ -0,0 +1,47 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+)
+
+func TestCanAccessBooking(t *testing.T) {
+	owner := &models.User{ID: 1, Role: "USER"}
+	staff := &models.User{ID: 2, Role: "USER"}
+	other := &models.User{ID: 3, Role: "USER"}
+	admin := &models.User{ID: 4, Role: "ADMIN"}
+	booking := &models.Booking{UserID: owner.ID, Parking: &models.Parking{AuthorID: staff.ID}}
+
+	cases := []struct {
+		name     string
+		user     *models.User
+		access   models.BookingAccess
+		expected bool
+	}{
+		{"owner views", owner, models.BookingAccessView, true},
+		{"staff views", staff, models.BookingAccessView, true},
+		{"other views", other, models.BookingAccessView, false},
+		{"admin views", admin, models.BookingAccessView, true},
+		{"owner owns", owner, models.BookingAccessOwn, true},
+		{"staff owns", staff, models.BookingAccessOwn, false},
+		{"other owns", other, models.BookingAccessOwn, false},
+		{"admin owns", admin, models.BookingAccessOwn, true},
+		{"owner overrides", owner, models.BookingAccessOverride, false},
+		{"staff overrides", staff, models.BookingAccessOverride, false},
+		{"admin overrides", admin, models.BookingAccessOverride, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if models.CanAccessBooking(c.user, booking, c.access) != c.expected {
+				t.Errorf("expected %v, got %v", c.expected, !c.expected)
+			}
+		})
+	}
+
+	// Without its parking loaded a booking is only visible to its user
+	if models.CanAccessBooking(staff, &models.Booking{UserID: owner.ID}, models.BookingAccessView) {
+		t.Error("expected staff to be denied without the parking loaded")
+	}
+}
@@ -0,0 +1,32 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestCalculateRefund(t *testing.T) {
+	startAt := time.Date(2026, 3, 2, 10, 0, 0, 0, jakarta)
+
+	cases := []struct {
+		canceledAt time.Time
+		policy     string
+		amount     float64
+	}{
+		{startAt.Add(-48 * time.Hour), models.RefundPolicyFull, 20000},
+		{startAt.Add(-24 * time.Hour), models.RefundPolicyFull, 20000},
+		{startAt.Add(-2 * time.Hour), models.RefundPolicyPartial, 10000},
+		{startAt, models.RefundPolicyNone, 0},
+		{startAt.Add(time.Hour), models.RefundPolicyNone, 0},
+	}
+
+	for _, c := range cases {
+		policy, amount := services.CalculateRefund(startAt, c.canceledAt, 24, 50, 20000)
+		if policy != c.policy || amount != c.amount {
+			t.Errorf("canceled at %s: expected %s refund of %.2f, got %s refund of %.2f", c.canceledAt.Format(time.DateTime), c.policy, c.amount, policy, amount)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/config"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/database"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/validation"
+	"github.com/spf13/viper"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// loadTestDatabase connects to the database of config.yml, tests needing one are skipped without it.
+func loadTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	_, err := os.Stat("config.yml")
+	if err != nil {
+		t.Skip("config.yml not found, skipping database test")
+	}
+
+	config.Load()
+	viper.Set("payment.provider", "fake")
+	viper.Set("disbursement.provider", "fake")
+	viper.Set("environment", "dev")
+
+	return database.NewDatabase()
+}
+
+// seedTestParking creates a parking of author with one slot, removed again when the test ends.
+func seedTestParking(t *testing.T, db *gorm.DB, author *models.User) (*models.Parking, *models.ParkingSlot) {
+	t.Helper()
+
+	parking := &models.Parking{
+		AuthorID:   author.ID,
+		Slug:       "test-" + strings.ToLower(pkg.RandomString(8)),
+		Name:       "Test Parking",
+		Address:    "Test Address",
+		DefaultFee: 5000,
+		Layout:     datatypes.JSON("[]"),
+	}
+	err := db.Create(parking).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slot := &models.ParkingSlot{ParkingID: parking.ID, Name: "A1", Status: "AVAILABLE", Fee: 5000}
+	err = db.Create(slot).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		bookings := db.Unscoped().Model(&models.Booking{}).Select("id").Where("parking_id = ?", parking.ID)
+		db.Where("booking_id IN (?)", bookings).Delete(&models.BookingEvent{})
+		db.Where("booking_id IN (?)", bookings).Delete(&models.BookingExtension{})
+		db.Where("booking_id IN (?)", bookings).Delete(&models.VoucherRedemption{})
+		db.Where("booking_id IN (?)", bookings).Delete(&models.PaymentEvent{})
+		db.Where("parking_id = ?", parking.ID).Delete(&models.Refund{})
+		db.Where("parking_id = ?", parking.ID).Delete(&models.LedgerEntry{})
+		db.Where("parking_id = ?", parking.ID).Delete(&models.ParkingSession{})
+		db.Where("parking_id = ?", parking.ID).Delete(&models.SlotHold{})
+		db.Unscoped().Where("parking_id = ?", parking.ID).Delete(&models.Booking{})
+		db.Where("parking_id = ?", parking.ID).Delete(&models.BookingSeries{})
+		db.Unscoped().Where("parking_id = ?", parking.ID).Delete(&models.ParkingSlot{})
+		db.Delete(parking)
+	})
+
+	return parking, slot
+}
+
+// newTestBookingService wires a booking service paying through the returned fake gateway.
+func newTestBookingService(db *gorm.DB) (*services.BookingService, *paymentgateway.FakeProvider) {
+	validate := validation.New()
+	mailService := services.NewMailService()
+	ledger := services.NewLedgerService(db)
+	paymentProvider := paymentgateway.NewFakeProvider()
+	pricingService := services.NewPricingService(db, validate)
+	sessionService := services.NewParkingSessionService(db, validate, paymentProvider, pricingService, ledger)
+	lifecycle := services.NewBookingLifecycle(db, mailService, ledger)
+
+	return services.NewBookingService(db, validate, paymentProvider, mailService, lifecycle, pricingService, sessionService), paymentProvider
+}
+
+// payTestInvoice pays the invoice at the fake provider and delivers its webhook to the booking service.
+func payTestInvoice(t *testing.T, bookingService *services.BookingService, paymentProvider *paymentgateway.FakeProvider, invoiceID string) {
+	t.Helper()
+
+	payload, err := paymentProvider.Pay(invoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := paymentProvider.ParseWebhook(http.Header{}, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bookingService.ProcessPaymentEvent(event, models.PaymentEventSourceWebhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateBooking_ConcurrentSameSlot(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, _ := newTestBookingService(db)
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, slot := seedTestParking(t, db, &user)
+
+	// Use a far future window so earlier runs never collide with this one
+	startAt := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour)
+	endAt := startAt.Add(4 * time.Hour)
+
+	const attempts = 10
+	var wg sync.WaitGroup
+	results := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Shift some windows so they partially overlap or fully contain the others
+			offset := time.Duration(i%3) * 30 * time.Minute
+			_, err := bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+				ParkingID:   slot.ParkingID,
+				SlotID:      slot.ID,
+				PlateNumber: "KB 1234 TST",
+				StartAt:     startAt.Add(offset),
+				EndAt:       endAt.Add(-offset),
+			})
+			results <- err
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		if !errors.Is(err, services.ErrSlotAlreadyBooked) {
+			t.Errorf("expected ErrSlotAlreadyBooked, got %v", err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("expected exactly 1 booking to succeed, got %d", succeeded)
+	}
+}
+
+func TestCreateBooking_ConcurrentAnySlot(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, _ := newTestBookingService(db)
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	parking, _ := seedTestParking(t, db, &user)
+
+	const slots = 4
+	for i := 2; i <= slots; i++ {
+		err = db.Create(&models.ParkingSlot{ParkingID: parking.ID, Name: fmt.Sprintf("A%d", i), Status: "AVAILABLE", Fee: 5000}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	startAt := pkg.GetCurrentTime().AddDate(0, 1, 0).Truncate(time.Hour)
+
+	// Every request ranks the slots the same, the losers of a race move down the ranking instead of failing
+	var wg sync.WaitGroup
+	results := make(chan error, slots)
+	for i := 0; i < slots; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+				ParkingID:   parking.ID,
+				PlateNumber: "KB 1234 TST",
+				StartAt:     startAt,
+				EndAt:       startAt.Add(4 * time.Hour),
+			})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	for err := range results {
+		if err != nil {
+			t.Errorf("expected every booking to get a slot, got %v", err)
+		}
+	}
+
+	_, err = bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+		ParkingID:   parking.ID,
+		PlateNumber: "KB 1234 TST",
+		StartAt:     startAt,
+		EndAt:       startAt.Add(4 * time.Hour),
+	})
+	if !errors.Is(err, services.ErrNoSlotAvailable) {
+		t.Errorf("expected ErrNoSlotAvailable once every slot is booked, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+)
+
+func TestNewBookingActor(t *testing.T) {
+	cases := []struct {
+		name     string
+		user     *models.User
+		expected string
+	}{
+		{"user", &models.User{ID: 1, Role: "USER"}, models.BookingActorUser},
+		{"admin", &models.User{ID: 2, Role: "ADMIN"}, models.BookingActorAdmin},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actor := models.NewBookingActor(c.user, models.BookingEventSourceAPI)
+			if actor.Type != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, actor.Type)
+			}
+			if actor.UserID == nil || *actor.UserID != c.user.ID {
+				t.Errorf("expected user %d, got %v", c.user.ID, actor.UserID)
+			}
+		})
+	}
+
+	actor := models.NewSystemBookingActor(models.BookingEventSourceJob)
+	if actor.Type != models.BookingActorSystem || actor.UserID != nil {
+		t.Errorf("unexpected system actor %+v", actor)
+	}
+}
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+)
+
+// seedPaidBooking books the slot for two hours from startAt and pays the invoice.
+func seedPaidBooking(t *testing.T, bookingService *services.BookingService, paymentProvider *paymentgateway.FakeProvider, user *models.User, slot *models.ParkingSlot, startAt time.Time) *models.Booking {
+	t.Helper()
+
+	booking, err := bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+		ParkingID:   slot.ParkingID,
+		SlotID:      slot.ID,
+		PlateNumber: "KB 1234 TST",
+		StartAt:     startAt,
+		EndAt:       startAt.Add(2 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payTestInvoice(t, bookingService, paymentProvider, booking.PaymentInvoiceID)
+
+	booking, err = bookingService.GetBookingByID(booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != models.BookingStatusPaid {
+		t.Fatalf("expected PAID booking, got %s", booking.Status)
+	}
+
+	return booking
+}
+
+func TestExtendBooking_PaidExtensionMovesEnd(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, paymentProvider := newTestBookingService(db)
+
+	user := seedTestUser(t, db, "extend", "USER")
+	_, slot := seedTestParking(t, db, user)
+
+	// Use a far future window so earlier runs never collide with this one
+	startAt := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour)
+	booking := seedPaidBooking(t, bookingService, paymentProvider, user, slot, startAt)
+
+	_, err := bookingService.ExtendBooking(user.ID+1, booking.ID, &models.ExtendBookingRequest{EndAt: booking.EndAt.Add(time.Hour)})
+	if err == nil {
+		t.Error("expected extending the booking of another user to fail")
+	}
+
+	extension, err := bookingService.ExtendBooking(user.ID, booking.ID, &models.ExtendBookingRequest{EndAt: booking.EndAt.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extension.Status != models.BookingExtensionStatusUnpaid || extension.Fee != 5000 || extension.AdditionalHours != 1 {
+		t.Fatalf("expected an UNPAID extension of 1 hour for 5000, got %s of %d hours for %.2f", extension.Status, extension.AdditionalHours, extension.Fee)
+	}
+
+	_, err = bookingService.ExtendBooking(user.ID, booking.ID, &models.ExtendBookingRequest{EndAt: booking.EndAt.Add(2 * time.Hour)})
+	if err == nil {
+		t.Error("expected a second extension to be rejected while the first one is pending")
+	}
+
+	// The booking only moves once the extension is paid, a repeated delivery changes nothing
+	payload, err := paymentProvider.Pay(extension.PaymentInvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		event, err := paymentProvider.ParseWebhook(http.Header{}, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = bookingService.ProcessPaymentEvent(event, models.PaymentEventSourceWebhook)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	extended, err := bookingService.GetBookingByID(booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extended.EndAt.Equal(booking.EndAt.Add(time.Hour)) {
+		t.Errorf("expected the booking to end at %s, got %s", booking.EndAt.Add(time.Hour), extended.EndAt)
+	}
+	if extended.TotalHours != booking.TotalHours+1 || extended.TotalFee != booking.TotalFee+5000 {
+		t.Errorf("expected %d hours for %.2f, got %d hours for %.2f", booking.TotalHours+1, booking.TotalFee+5000, extended.TotalHours, extended.TotalFee)
+	}
+
+	var stored models.BookingExtension
+	err = db.First(&stored, extension.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.BookingExtensionStatusPaid {
+		t.Errorf("expected PAID extension, got %s", stored.Status)
+	}
+}
+
+func TestExtendBooking_ConflictingBooking(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, paymentProvider := newTestBookingService(db)
+
+	user := seedTestUser(t, db, "extend", "USER")
+	_, slot := seedTestParking(t, db, user)
+
+	startAt := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour)
+	booking := seedPaidBooking(t, bookingService, paymentProvider, user, slot, startAt)
+	seedPaidBooking(t, bookingService, paymentProvider, user, slot, booking.EndAt)
+
+	_, err := bookingService.ExtendBooking(user.ID, booking.ID, &models.ExtendBookingRequest{EndAt: booking.EndAt.Add(time.Hour)})
+	if !errors.Is(err, services.ErrSlotAlreadyBooked) {
+		t.Errorf("expected ErrSlotAlreadyBooked, got %v", err)
+	}
+
+	var extensions int64
+	db.Model(&models.BookingExtension{}).Where("booking_id = ?", booking.ID).Count(&extensions)
+	if extensions != 0 {
+		t.Errorf("expected no extension to be created, got %d", extensions)
+	}
+}
+
+func TestExtendBooking_FailedExtensionIsRefunded(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, paymentProvider := newTestBookingService(db)
+
+	user := seedTestUser(t, db, "extend", "USER")
+	_, slot := seedTestParking(t, db, user)
+
+	startAt := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour)
+	booking := seedPaidBooking(t, bookingService, paymentProvider, user, slot, startAt)
+
+	extension, err := bookingService.ExtendBooking(user.ID, booking.ID, &models.ExtendBookingRequest{EndAt: booking.EndAt.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The booking is canceled while the extension invoice is still open and the guest pays it anyway
+	_, err = bookingService.CancelBooking(user.ID, booking.ID, &models.CancelBookingRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payTestInvoice(t, bookingService, paymentProvider, extension.PaymentInvoiceID)
+
+	var stored models.BookingExtension
+	err = db.First(&stored, extension.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.BookingExtensionStatusFailed {
+		t.Errorf("expected FAILED extension, got %s", stored.Status)
+	}
+
+	canceled, err := bookingService.GetBookingByID(booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canceled.EndAt.Equal(booking.EndAt) {
+		t.Errorf("expected the canceled booking to keep its end at %s, got %s", booking.EndAt, canceled.EndAt)
+	}
+
+	var refund models.Refund
+	err = db.Where("reference_id = ?", extension.PaymentReference).First(&refund).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Policy != models.RefundPolicyFull || refund.Amount != extension.Fee || refund.Status == models.RefundStatusFailed {
+		t.Errorf("expected a %s refund of %.2f, got %s of %.2f (%s)", models.RefundPolicyFull, extension.Fee, refund.Policy, refund.Amount, refund.Status)
+	}
+
+	report, err := bookingService.Lifecycle.Ledger.CheckParking(booking.ParkingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.IsConsistent {
+		t.Errorf("expected the ledger to account for the refunded extension, got %+v", report.Issues)
+	}
+
+	// The whole payment was refunded, nothing is left to refund at the gateway
+	_, err = paymentProvider.Refund(&paymentgateway.RefundRequest{InvoiceID: extension.PaymentInvoiceID, Amount: 1})
+	if err == nil {
+		t.Error("expected the extension payment to be refunded in full")
+	}
+}
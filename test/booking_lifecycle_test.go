@@ -0,0 +1,111 @@
+package test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+)
+
+func TestBookingTransitions(t *testing.T) {
+	cases := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{models.BookingStatusUnpaid, models.BookingStatusPaid, true},
+		{models.BookingStatusUnpaid, models.BookingStatusExpired, true},
+		{models.BookingStatusUnpaid, models.BookingStatusCanceled, true},
+		{models.BookingStatusUnpaid, models.BookingStatusCompleted, false},
+		{models.BookingStatusPaid, models.BookingStatusCompleted, true},
+		{models.BookingStatusPaid, models.BookingStatusCanceled, true},
+		{models.BookingStatusPaid, models.BookingStatusUnpaid, false},
+		{models.BookingStatusCompleted, models.BookingStatusPaid, false},
+		{models.BookingStatusExpired, models.BookingStatusPaid, false},
+		{models.BookingStatusCanceled, models.BookingStatusPaid, false},
+		{models.BookingStatusPaid, "CANCELLED", false},
+		{models.BookingStatusPaid, models.BookingStatusOvertimeUnpaid, true},
+		{models.BookingStatusOvertimeUnpaid, models.BookingStatusCompleted, true},
+		{models.BookingStatusOvertimeUnpaid, models.BookingStatusCanceled, false},
+		{models.BookingStatusPaid, models.BookingStatusNoShow, true},
+		{models.BookingStatusNoShow, models.BookingStatusPaid, false},
+	}
+
+	for _, c := range cases {
+		if models.CanTransitionBooking(c.from, c.to) != c.allowed {
+			t.Errorf("transition %s -> %s: expected allowed=%v", c.from, c.to, c.allowed)
+		}
+	}
+}
+
+func TestBookingLifecycle_SlotStatus(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, paymentProvider := newTestBookingService(db)
+
+	user := seedTestUser(t, db, "lifecycle", "USER")
+	_, slot := seedTestParking(t, db, user)
+	slotStatus := func() string {
+		var current models.ParkingSlot
+		err := db.First(&current, slot.ID).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		return current.Status
+	}
+
+	// A booking weeks ahead leaves the slot to whoever parks now
+	seedPaidBooking(t, bookingService, paymentProvider, user, slot, pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour))
+	if status := slotStatus(); status != "AVAILABLE" {
+		t.Errorf("expected a future booking to leave the slot AVAILABLE, got %s", status)
+	}
+
+	startAt := pkg.GetCurrentTime().Add(5 * time.Minute)
+	book := func() *models.Booking {
+		booking, err := bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+			ParkingID:   slot.ParkingID,
+			SlotID:      slot.ID,
+			PlateNumber: "KB 1234 TST",
+			StartAt:     startAt,
+			EndAt:       startAt.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status := slotStatus(); status != "BOOKED" {
+			t.Errorf("expected a starting booking to make the slot BOOKED, got %s", status)
+		}
+		return booking
+	}
+
+	booking := book()
+	_, err := bookingService.CancelBooking(user.ID, booking.ID, &models.CancelBookingRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := slotStatus(); status != "AVAILABLE" {
+		t.Errorf("expected canceling the booking to release the slot, got %s", status)
+	}
+
+	// A vehicle parked on the slot keeps it when the booking goes away
+	booking = book()
+	err = db.Create(&models.ParkingSession{
+		ParkingID:   slot.ParkingID,
+		SlotID:      slot.ID,
+		PlateNumber: "KB 5678 TST",
+		Source:      models.ParkingSessionSourceCamera,
+		EnteredAt:   pkg.GetCurrentTime(),
+		Status:      models.ParkingSessionStatusOpen,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bookingService.CancelBooking(user.ID, booking.ID, &models.CancelBookingRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := slotStatus(); status != "BOOKED" {
+		t.Errorf("expected the slot of a parked vehicle not to be released, got %s", status)
+	}
+}
@@ -0,0 +1,47 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestIsNoShow(t *testing.T) {
+	startAt := time.Date(2026, 3, 2, 10, 0, 0, 0, jakarta)
+	arrivedAt := startAt.Add(10 * time.Minute)
+	parking := &models.Parking{NoShowMinutes: 30}
+
+	cases := []struct {
+		name     string
+		booking  *models.Booking
+		parking  *models.Parking
+		now      time.Time
+		expected bool
+	}{
+		{"within window", &models.Booking{Status: models.BookingStatusPaid, StartAt: startAt}, parking, startAt.Add(29 * time.Minute), false},
+		{"window passed", &models.Booking{Status: models.BookingStatusPaid, StartAt: startAt}, parking, startAt.Add(30 * time.Minute), true},
+		{"arrived", &models.Booking{Status: models.BookingStatusPaid, StartAt: startAt, ArrivedAt: &arrivedAt}, parking, startAt.Add(time.Hour), false},
+		{"not paid", &models.Booking{Status: models.BookingStatusCanceled, StartAt: startAt}, parking, startAt.Add(time.Hour), false},
+		{"disabled", &models.Booking{Status: models.BookingStatusPaid, StartAt: startAt}, &models.Parking{}, startAt.Add(time.Hour), false},
+	}
+
+	for _, c := range cases {
+		if services.IsNoShow(c.booking, c.parking, c.now) != c.expected {
+			t.Errorf("%s: expected no-show=%v", c.name, c.expected)
+		}
+	}
+}
+
+func TestCalculateNoShowRefund(t *testing.T) {
+	policy, amount := services.CalculateNoShowRefund(0, 20000)
+	if policy != models.RefundPolicyNone || amount != 0 {
+		t.Errorf("expected no refund, got %s refund of %.2f", policy, amount)
+	}
+
+	policy, amount = services.CalculateNoShowRefund(25, 20000)
+	if policy != models.RefundPolicyNoShow || amount != 5000 {
+		t.Errorf("expected NO_SHOW refund of 5000, got %s refund of %.2f", policy, amount)
+	}
+}
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestCalculateOvertime(t *testing.T) {
+	endAt := time.Date(2026, 3, 2, 10, 0, 0, 0, jakarta)
+
+	cases := []struct {
+		checkoutAt time.Time
+		minutes    int
+		fee        float64
+	}{
+		{endAt.Add(-30 * time.Minute), 0, 0},
+		{endAt.Add(15 * time.Minute), 0, 0},
+		{endAt.Add(16 * time.Minute), 16, 4000},
+		{endAt.Add(61 * time.Minute), 61, 8000},
+	}
+
+	for _, c := range cases {
+		minutes, fee := services.CalculateOvertime(endAt, c.checkoutAt, 15, 4000)
+		if minutes != c.minutes || fee != c.fee {
+			t.Errorf("checkout at %s: expected %d minutes for %.2f, got %d minutes for %.2f", c.checkoutAt.Format(time.Kitchen), c.minutes, c.fee, minutes, fee)
+		}
+	}
+}
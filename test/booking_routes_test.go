@@ -0,0 +1,231 @@
+package test
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/injector"
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"gorm.io/gorm"
+)
+
+// seedTestUser creates a user with the role, removed again when the test ends.
+func seedTestUser(t *testing.T, db *gorm.DB, name string, role string) *models.User {
+	t.Helper()
+
+	suffix := strings.ToLower(pkg.RandomString(8))
+	user := &models.User{
+		Username: fmt.Sprintf("%s-%s", name, suffix),
+		FullName: fmt.Sprintf("Booking %s", name),
+		Email:    fmt.Sprintf("%s-%s@parkingo.test", name, suffix),
+		GoogleID: fmt.Sprintf("%s-%s", name, suffix),
+		Role:     role,
+	}
+	err := db.Create(user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Unscoped().Delete(user)
+	})
+
+	return user
+}
+
+func TestBookingRoutes_Authorization(t *testing.T) {
+	db := loadTestDatabase(t)
+
+	route := injector.InjectRoutes()
+	route.RegisterRoutes()
+	bookingService := route.BookingController.BookingService
+	paymentProvider := bookingService.PaymentProvider.(*paymentgateway.FakeProvider)
+	jwtService := services.NewJWTService()
+
+	owner := seedTestUser(t, db, "owner", "USER")
+	staff := seedTestUser(t, db, "staff", "USER")
+	other := seedTestUser(t, db, "other", "USER")
+	admin := seedTestUser(t, db, "admin", "ADMIN")
+
+	// The staff user authors the parking of every booking of the test
+	parking, slot := seedTestParking(t, db, staff)
+
+	// Use a far future window so earlier runs never collide with this one, every booking gets its own day
+	day := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(24 * time.Hour)
+	at := func(days int) time.Time {
+		return day.AddDate(0, 0, days).Add(10 * time.Hour)
+	}
+
+	createPaidBooking := func(startAt time.Time) *models.Booking {
+		booking, err := bookingService.CreateBooking(owner.ID, &models.CreateBookingRequest{
+			ParkingID:   parking.ID,
+			SlotID:      slot.ID,
+			PlateNumber: "KB 1234 TST",
+			StartAt:     startAt,
+			EndAt:       startAt.Add(2 * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payTestInvoice(t, bookingService, paymentProvider, booking.PaymentInvoiceID)
+
+		return booking
+	}
+	booking := createPaidBooking(at(0))
+	canceled := createPaidBooking(at(1))
+
+	seriesRequest := func(days int) *models.CreateBookingSeriesRequest {
+		return &models.CreateBookingSeriesRequest{
+			ParkingID:   parking.ID,
+			SlotID:      &slot.ID,
+			PlateNumber: "KB 1234 TST",
+			Frequency:   models.BookingSeriesFrequencyDaily,
+			StartTime:   "08:00",
+			EndTime:     "09:00",
+			StartDate:   day.AddDate(0, 0, days).Format("2006-01-02"),
+			EndDate:     day.AddDate(0, 0, days+1).Format("2006-01-02"),
+		}
+	}
+	series, err := bookingService.CreateBookingSeries(owner.ID, seriesRequest(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hold, err := bookingService.HoldSlot(owner.ID, parking.ID, slot.ID, &models.CreateSlotHoldRequest{
+		StartAt: at(9),
+		EndAt:   at(9).Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bookingPath := fmt.Sprintf("/v1/bookings/%d", booking.ID)
+	canceledPath := fmt.Sprintf("/v1/bookings/%d", canceled.ID)
+	referencePath := fmt.Sprintf("/v1/bookings/reference/%s", booking.PaymentReference)
+	seriesPath := fmt.Sprintf("/v1/bookings/series/%d", series.ID)
+	holdPath := fmt.Sprintf("/v1/parkings/%d/slots/%d/hold", parking.ID, slot.ID)
+	extendBody := fmt.Sprintf(`{"end_at":"%s"}`, booking.EndAt.Add(time.Hour).Format(time.RFC3339))
+	windowBody := func(days int) string {
+		return fmt.Sprintf(`{"start_at":"%s","end_at":"%s"}`, at(days).Format(time.RFC3339), at(days).Add(time.Hour).Format(time.RFC3339))
+	}
+	quoteBody := fmt.Sprintf(`{"parking_id":%d,"slot_id":%d,"plate_number":"KB 1234 TST","start_at":"%s","end_at":"%s"}`,
+		parking.ID, slot.ID, at(12).Format(time.RFC3339), at(12).Add(2*time.Hour).Format(time.RFC3339))
+	seriesRequestBody := seriesRequest(6)
+	seriesBody := fmt.Sprintf(`{"parking_id":%d,"slot_id":%d,"plate_number":"KB 1234 TST","frequency":"DAILY","start_time":"08:00","end_time":"09:00","start_date":"%s","end_date":"%s"}`,
+		parking.ID, slot.ID, seriesRequestBody.StartDate, seriesRequestBody.EndDate)
+
+	// Cases run in order, the later ones change the bookings the earlier ones look at
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		user     *models.User
+		body     string
+		expected int
+	}{
+		{"anonymous views booking", http.MethodGet, bookingPath, nil, "", http.StatusUnauthorized},
+		{"owner views booking", http.MethodGet, bookingPath, owner, "", http.StatusOK},
+		{"staff views booking", http.MethodGet, bookingPath, staff, "", http.StatusOK},
+		{"other views booking", http.MethodGet, bookingPath, other, "", http.StatusForbidden},
+		{"admin views booking", http.MethodGet, bookingPath, admin, "", http.StatusOK},
+		{"owner views reference", http.MethodGet, referencePath, owner, "", http.StatusOK},
+		{"staff views reference", http.MethodGet, referencePath, staff, "", http.StatusOK},
+		{"other views reference", http.MethodGet, referencePath, other, "", http.StatusForbidden},
+		{"admin views reference", http.MethodGet, referencePath, admin, "", http.StatusOK},
+		{"owner views timeline", http.MethodGet, bookingPath + "/timeline", owner, "", http.StatusOK},
+		{"other views timeline", http.MethodGet, bookingPath + "/timeline", other, "", http.StatusForbidden},
+
+		{"owner lists own bookings", http.MethodGet, "/v1/bookings", owner, "", http.StatusOK},
+		{"owner lists bookings of another user", http.MethodGet, fmt.Sprintf("/v1/bookings?user_id=%d", other.ID), owner, "", http.StatusForbidden},
+		{"other lists bookings of the parking", http.MethodGet, fmt.Sprintf("/v1/bookings?parking_id=%d", parking.ID), other, "", http.StatusOK},
+		{"other lists bookings of the owner in the parking", http.MethodGet, fmt.Sprintf("/v1/bookings?parking_id=%d&user_id=%d", parking.ID, owner.ID), other, "", http.StatusForbidden},
+		{"staff lists bookings of the parking", http.MethodGet, fmt.Sprintf("/v1/bookings?parking_id=%d", parking.ID), staff, "", http.StatusOK},
+		{"staff lists bookings of the owner in the parking", http.MethodGet, fmt.Sprintf("/v1/bookings?parking_id=%d&user_id=%d", parking.ID, owner.ID), staff, "", http.StatusOK},
+		{"owner lists booking history", http.MethodGet, "/v1/bookings/history", owner, "", http.StatusForbidden},
+		{"admin lists booking history", http.MethodGet, "/v1/bookings/history", admin, "", http.StatusOK},
+
+		{"owner changes plate number", http.MethodPatch, bookingPath, owner, `{"plate_number":"KB 4321 TST"}`, http.StatusOK},
+		{"owner overrides fee", http.MethodPatch, bookingPath, owner, `{"total_fee":1}`, http.StatusBadRequest},
+		{"staff changes plate number", http.MethodPatch, bookingPath, staff, `{"plate_number":"KB 9999 TST"}`, http.StatusForbidden},
+		{"other changes plate number", http.MethodPatch, bookingPath, other, `{"plate_number":"KB 9999 TST"}`, http.StatusForbidden},
+		{"admin overrides fee", http.MethodPatch, bookingPath, admin, `{"total_fee":20000}`, http.StatusOK},
+
+		{"anonymous quotes booking", http.MethodPost, "/v1/bookings/quote", nil, quoteBody, http.StatusUnauthorized},
+		{"owner quotes booking", http.MethodPost, "/v1/bookings/quote", owner, quoteBody, http.StatusOK},
+		{"owner quotes booking without plate number", http.MethodPost, "/v1/bookings/quote", owner, fmt.Sprintf(`{"parking_id":%d}`, parking.ID), http.StatusBadRequest},
+
+		{"other holds slot", http.MethodPost, holdPath, other, windowBody(10), http.StatusCreated},
+		{"owner holds slot held by other", http.MethodPost, holdPath, owner, windowBody(10), http.StatusConflict},
+		{"other releases hold of owner", http.MethodDelete, holdPath + "/" + hold.Token, other, "", http.StatusNotFound},
+		{"owner releases hold", http.MethodDelete, holdPath + "/" + hold.Token, owner, "", http.StatusOK},
+		{"owner releases released hold", http.MethodDelete, holdPath + "/" + hold.Token, owner, "", http.StatusNotFound},
+
+		{"owner views series", http.MethodGet, seriesPath, owner, "", http.StatusOK},
+		{"staff views series", http.MethodGet, seriesPath, staff, "", http.StatusForbidden},
+		{"admin views series", http.MethodGet, seriesPath, admin, "", http.StatusOK},
+		{"owner creates series", http.MethodPost, "/v1/bookings/series", owner, seriesBody, http.StatusCreated},
+		{"other creates series on the same days", http.MethodPost, "/v1/bookings/series", other, seriesBody, http.StatusConflict},
+		{"other cancels series", http.MethodPost, seriesPath + "/cancel", other, `{}`, http.StatusForbidden},
+		{"owner cancels series", http.MethodPost, seriesPath + "/cancel", owner, `{"reason":"Plans changed"}`, http.StatusOK},
+		{"owner cancels canceled series", http.MethodPost, seriesPath + "/cancel", owner, `{}`, http.StatusConflict},
+
+		{"other extends booking", http.MethodPost, bookingPath + "/extend", other, extendBody, http.StatusForbidden},
+		{"staff extends booking", http.MethodPost, bookingPath + "/extend", staff, extendBody, http.StatusForbidden},
+		{"owner extends booking", http.MethodPost, bookingPath + "/extend", owner, extendBody, http.StatusCreated},
+		{"owner extends booking again", http.MethodPost, bookingPath + "/extend", owner, extendBody, http.StatusConflict},
+
+		{"other cancels booking", http.MethodPost, canceledPath + "/cancel", other, `{}`, http.StatusForbidden},
+		{"staff cancels booking", http.MethodPost, canceledPath + "/cancel", staff, `{}`, http.StatusForbidden},
+		{"owner cancels booking", http.MethodPost, canceledPath + "/cancel", owner, `{"reason":"Plans changed"}`, http.StatusOK},
+		{"owner cancels canceled booking", http.MethodPost, canceledPath + "/cancel", owner, `{}`, http.StatusConflict},
+
+		{"owner lists own refunds", http.MethodGet, fmt.Sprintf("/v1/bookings/refunds?booking_id=%d", canceled.ID), owner, "", http.StatusOK},
+		{"owner lists refund history", http.MethodGet, "/v1/bookings/refunds/history", owner, "", http.StatusForbidden},
+		{"admin lists refund history", http.MethodGet, fmt.Sprintf("/v1/bookings/refunds/history?parking_id=%d", parking.ID), admin, "", http.StatusOK},
+
+		{"owner deletes booking", http.MethodDelete, bookingPath, owner, "", http.StatusForbidden},
+		{"staff deletes booking", http.MethodDelete, bookingPath, staff, "", http.StatusForbidden},
+		{"admin deletes booking", http.MethodDelete, bookingPath, admin, "", http.StatusNoContent},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			if c.user != nil {
+				token, err := jwtService.GenerateToken(c.user.ID, time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			res, err := route.FiberApp.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != c.expected {
+				t.Errorf("expected status %d, got %d", c.expected, res.StatusCode)
+			}
+		})
+	}
+
+	// Canceling the paid booking refunded it
+	var refunds []models.Refund
+	err = db.Where("booking_id = ?", canceled.ID).Find(&refunds).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) == 0 {
+		t.Error("expected the canceled booking to be refunded")
+	}
+}
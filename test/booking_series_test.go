@@ -0,0 +1,210 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestExpandBookingSeries(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+
+	// Every weekday of March 2026, which starts on a Sunday
+	weekdays, err := services.ExpandBookingSeries(&models.CreateBookingSeriesRequest{
+		Frequency:  models.BookingSeriesFrequencyWeekly,
+		DaysOfWeek: []int{1, 2, 3, 4, 5},
+		StartTime:  "08:00",
+		EndTime:    "17:00",
+		StartDate:  "2026-03-01",
+		EndDate:    "2026-03-31",
+	}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weekdays) != 22 {
+		t.Errorf("expected 22 occurrences, got %d", len(weekdays))
+	}
+	if !weekdays[0].StartAt.Equal(time.Date(2026, 3, 2, 8, 0, 0, 0, loc)) || !weekdays[0].EndAt.Equal(time.Date(2026, 3, 2, 17, 0, 0, 0, loc)) {
+		t.Errorf("expected the first occurrence on 2026-03-02 08:00-17:00, got %s-%s", weekdays[0].StartAt, weekdays[0].EndAt)
+	}
+
+	// Every other day overnight
+	nights, err := services.ExpandBookingSeries(&models.CreateBookingSeriesRequest{
+		Frequency: models.BookingSeriesFrequencyDaily,
+		Interval:  2,
+		StartTime: "22:00",
+		EndTime:   "06:00",
+		StartDate: "2026-03-01",
+		EndDate:   "2026-03-07",
+	}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nights) != 4 {
+		t.Errorf("expected 4 occurrences, got %d", len(nights))
+	}
+	if !nights[1].EndAt.Equal(time.Date(2026, 3, 4, 6, 0, 0, 0, loc)) {
+		t.Errorf("expected the second occurrence to end on 2026-03-04 06:00, got %s", nights[1].EndAt)
+	}
+
+	// Every other week on the weekday of the start date
+	biweekly, err := services.ExpandBookingSeries(&models.CreateBookingSeriesRequest{
+		Frequency: models.BookingSeriesFrequencyWeekly,
+		Interval:  2,
+		StartTime: "08:00",
+		EndTime:   "12:00",
+		StartDate: "2026-03-04",
+		EndDate:   "2026-03-31",
+	}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(biweekly) != 2 || biweekly[1].StartAt.Day() != 18 {
+		t.Errorf("expected occurrences on 2026-03-04 and 2026-03-18, got %v", biweekly)
+	}
+
+	_, err = services.ExpandBookingSeries(&models.CreateBookingSeriesRequest{
+		Frequency: models.BookingSeriesFrequencyDaily,
+		StartTime: "08:00",
+		EndTime:   "17:00",
+		StartDate: "2026-03-01",
+		EndDate:   "2026-12-31",
+	}, loc)
+	if err == nil {
+		t.Error("expected a series with too many occurrences to be rejected")
+	}
+}
+
+func TestCancelBookingSeries_CancelsEveryOccurrence(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, paymentProvider := newTestBookingService(db)
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	parking, slot := seedTestParking(t, db, &user)
+
+	startDate := pkg.GetCurrentTime().AddDate(0, 1, 0)
+	series, err := bookingService.CreateBookingSeries(user.ID, &models.CreateBookingSeriesRequest{
+		ParkingID:   parking.ID,
+		SlotID:      &slot.ID,
+		PlateNumber: "KB 1234 TST",
+		Frequency:   models.BookingSeriesFrequencyDaily,
+		StartTime:   "08:00",
+		EndTime:     "10:00",
+		StartDate:   startDate.Format("2006-01-02"),
+		EndDate:     startDate.AddDate(0, 0, 2).Format("2006-01-02"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := paymentProvider.Pay(series.PaymentInvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := paymentProvider.ParseWebhook(http.Header{}, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bookingService.ProcessPaymentEvent(event, models.PaymentEventSourceWebhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Canceling an occurrence of another user is forbidden and leaves the series untouched
+	_, err = bookingService.CancelBookingSeries(user.ID+1, series.ID, &models.CancelBookingRequest{})
+	if err == nil {
+		t.Fatal("expected canceling the series of another user to fail")
+	}
+
+	series, err = bookingService.CancelBookingSeries(user.ID, series.ID, &models.CancelBookingRequest{Reason: "Plans changed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Status != models.BookingSeriesStatusCanceled {
+		t.Fatalf("expected CANCELED series, got %s", series.Status)
+	}
+	if len(series.Bookings) != 3 {
+		t.Fatalf("expected 3 occurrences, got %d", len(series.Bookings))
+	}
+	for _, booking := range series.Bookings {
+		if booking.Status != models.BookingStatusCanceled {
+			t.Errorf("expected occurrence %s to be CANCELED, got %s", booking.StartAt, booking.Status)
+		}
+	}
+
+	var refunds []models.Refund
+	err = db.Where("parking_id = ?", parking.ID).Find(&refunds).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 3 {
+		t.Fatalf("expected a refund per occurrence, got %d", len(refunds))
+	}
+	for _, refund := range refunds {
+		if refund.Policy != models.RefundPolicyFull || refund.Amount != refund.PaidAmount {
+			t.Errorf("expected a full refund, got %s of %.2f/%.2f", refund.Policy, refund.Amount, refund.PaidAmount)
+		}
+	}
+
+	_, err = bookingService.CancelBookingSeries(user.ID, series.ID, &models.CancelBookingRequest{})
+	if err == nil {
+		t.Error("expected a canceled series to not be canceled again")
+	}
+}
+
+func TestCheckoutWithPlateNumber_PicksCurrentBooking(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, _ := newTestBookingService(db)
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	parking, slot := seedTestParking(t, db, &user)
+
+	now := pkg.GetCurrentTime()
+	newBooking := func(startAt time.Time, status string) *models.Booking {
+		booking := &models.Booking{
+			UserID:           user.ID,
+			ParkingID:        parking.ID,
+			SlotID:           slot.ID,
+			PlateNumber:      "KB 1234 TST",
+			StartAt:          startAt,
+			EndAt:            startAt.Add(2 * time.Hour),
+			TotalHours:       2,
+			TotalFee:         10000,
+			PaymentReference: "PKGO-" + pkg.RandomString(8),
+			Status:           status,
+		}
+		err := db.Create(booking).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		return booking
+	}
+
+	// Inserted first so the lowest id is not the booking leaving
+	newBooking(now.Add(-48*time.Hour), models.BookingStatusCompleted)
+	newBooking(now.AddDate(0, 0, 1), models.BookingStatusPaid)
+	current := newBooking(now.Add(-time.Hour), models.BookingStatusPaid)
+
+	booking, err := bookingService.CheckoutWithPlateNumber(&user, "KB 1234 TST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.ID != current.ID {
+		t.Fatalf("expected booking %d to be checked out, got %d", current.ID, booking.ID)
+	}
+	if booking.Status != models.BookingStatusCompleted {
+		t.Fatalf("expected COMPLETED booking, got %s", booking.Status)
+	}
+}
@@ -0,0 +1,34 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestCalculateCommission(t *testing.T) {
+	plan := &models.CommissionPlan{Percentage: 10, FlatFee: 2000}
+
+	cases := []struct {
+		name         string
+		plan         *models.CommissionPlan
+		amount       float64
+		firstPayment bool
+		commission   float64
+	}{
+		{"no plan", nil, 20000, true, 0},
+		{"booking payment", plan, 20000, true, 4000},
+		{"extension payment", plan, 5000, false, 500},
+		{"rounded to cents", &models.CommissionPlan{Percentage: 7.5}, 333, false, 24.98},
+		{"capped at amount", &models.CommissionPlan{FlatFee: 5000}, 3000, true, 3000},
+		{"free booking", plan, 0, true, 0},
+	}
+
+	for _, c := range cases {
+		commission := services.CalculateCommission(c.plan, c.amount, c.firstPayment)
+		if commission != c.commission {
+			t.Errorf("%s: expected commission of %.2f, got %.2f", c.name, c.commission, commission)
+		}
+	}
+}
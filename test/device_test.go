@@ -0,0 +1,102 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/injector"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+)
+
+func TestDeviceSignature(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	body := []byte(`{"parking_slug":"test","slot":"A1","plate_number":"KB 1234 TST"}`)
+	signature := pkg.SignDeviceRequest("device-secret-0001", now.Unix(), http.MethodPost, "/v1/bookings/validate", body)
+
+	cases := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		path      string
+		body      []byte
+		expected  bool
+	}{
+		{"signed request", "device-secret-0001", now.Unix(), "/v1/bookings/validate", body, true},
+		{"slot without secret", "", now.Unix(), "/v1/bookings/validate", body, false},
+		{"other secret", "device-secret-0002", now.Unix(), "/v1/bookings/validate", body, false},
+		{"changed body", "device-secret-0001", now.Unix(), "/v1/bookings/validate", []byte(`{"parking_slug":"test","slot":"A1","plate_number":""}`), false},
+		{"other path", "device-secret-0001", now.Unix(), "/v1/parkings/slug/test/slot/A1/status/AVAILABLE", body, false},
+		{"other timestamp", "device-secret-0001", now.Unix() + 1, "/v1/bookings/validate", body, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			valid := pkg.VerifyDeviceRequest(c.secret, signature, c.timestamp, http.MethodPost, c.path, c.body, now)
+			if valid != c.expected {
+				t.Errorf("expected %v, got %v", c.expected, valid)
+			}
+		})
+	}
+
+	// A captured request cannot be replayed once the tolerance passed
+	if pkg.VerifyDeviceRequest("device-secret-0001", signature, now.Unix(), http.MethodPost, "/v1/bookings/validate", body, now.Add(pkg.DeviceSignatureTolerance+time.Second)) {
+		t.Error("expected an old signature to be rejected")
+	}
+}
+
+func TestDeviceRoutes_Signature(t *testing.T) {
+	db := loadTestDatabase(t)
+
+	route := injector.InjectRoutes()
+	route.RegisterRoutes()
+
+	author := seedTestUser(t, db, "device", "USER")
+	parking, slot := seedTestParking(t, db, author)
+	err := db.Model(slot).Update("device_secret", "device-secret-0001").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statusPath := fmt.Sprintf("/v1/parkings/slug/%s/slot/%s/status/AVAILABLE", parking.Slug, slot.Name)
+	validateBody := fmt.Sprintf(`{"parking_slug":%q,"slot":%q,"plate_number":""}`, parking.Slug, slot.Name)
+
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		secret   string
+		expected int
+	}{
+		{"unsigned slot status", http.MethodPatch, statusPath, "", "", http.StatusUnauthorized},
+		{"slot status signed with another secret", http.MethodPatch, statusPath, "", "device-secret-0002", http.StatusUnauthorized},
+		{"signed slot status", http.MethodPatch, statusPath, "", "device-secret-0001", http.StatusOK},
+		{"unsigned validation", http.MethodPost, "/v1/bookings/validate", validateBody, "", http.StatusUnauthorized},
+		{"signed validation", http.MethodPost, "/v1/bookings/validate", validateBody, "device-secret-0001", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			if c.secret != "" {
+				timestamp := pkg.GetCurrentTime().Unix()
+				req.Header.Set("X-Device-Timestamp", strconv.FormatInt(timestamp, 10))
+				req.Header.Set("X-Device-Signature", pkg.SignDeviceRequest(c.secret, timestamp, c.method, c.path, []byte(c.body)))
+			}
+
+			res, err := route.FiberApp.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != c.expected {
+				t.Errorf("expected status %d, got %d", c.expected, res.StatusCode)
+			}
+		})
+	}
+}
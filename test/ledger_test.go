@@ -0,0 +1,27 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestLedgerService_RejectsUnbalancedTransaction(t *testing.T) {
+	ledger := services.NewLedgerService(nil)
+
+	err := ledger.PostTx(nil, []models.LedgerEntry{
+		{ParkingID: 1, Account: models.LedgerAccountGatewayClearing, Amount: -10000},
+		{ParkingID: 1, Account: models.LedgerAccountParkingAvailable, Amount: 9000},
+	})
+	if err == nil {
+		t.Fatal("expected unbalanced transaction to be rejected")
+	}
+
+	err = ledger.PostTx(nil, []models.LedgerEntry{
+		{ParkingID: 1, Account: models.LedgerAccountParkingAvailable, Amount: 10000},
+	})
+	if err == nil {
+		t.Fatal("expected single entry transaction to be rejected")
+	}
+}
@@ -0,0 +1,65 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestGuestSessionBillingEnd(t *testing.T) {
+	enteredAt := time.Date(2026, 3, 2, 10, 0, 0, 0, jakarta)
+
+	cases := []struct {
+		name     string
+		exitedAt time.Time
+		expected time.Time
+	}{
+		{"immediate exit", enteredAt, enteredAt.Add(time.Hour)},
+		{"within first hour", enteredAt.Add(40 * time.Minute), enteredAt.Add(time.Hour)},
+		{"exact hours", enteredAt.Add(2 * time.Hour), enteredAt.Add(2 * time.Hour)},
+		{"started hour", enteredAt.Add(2*time.Hour + time.Minute), enteredAt.Add(3 * time.Hour)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			end := services.GuestSessionBillingEnd(enteredAt, c.exitedAt)
+			if !end.Equal(c.expected) {
+				t.Errorf("expected %v, got %v", c.expected, end)
+			}
+		})
+	}
+}
+
+func TestBuildOccupancyReport(t *testing.T) {
+	from := time.Date(2026, 3, 2, 8, 0, 0, 0, jakarta)
+	to := from.Add(4 * time.Hour)
+	now := from.Add(3 * time.Hour)
+	bookingID := 1
+	exitedAt := from.Add(90 * time.Minute)
+
+	slots := []models.ParkingSlot{{ID: 1, ParkingID: 1, Name: "A1"}, {ID: 2, ParkingID: 1, Name: "A2"}}
+	bookings := []*models.Booking{
+		{ID: bookingID, SlotID: 1, StartAt: from.Add(-time.Hour), EndAt: from.Add(2 * time.Hour)},
+	}
+	sessions := []*models.ParkingSession{
+		{SlotID: 1, BookingID: &bookingID, EnteredAt: from.Add(30 * time.Minute), ExitedAt: &exitedAt},
+		{SlotID: 2, EnteredAt: from.Add(2 * time.Hour)},
+	}
+
+	report := services.BuildOccupancyReport(slots, bookings, sessions, from, to, now)
+
+	if report.BookedMinutes != 120 {
+		t.Errorf("expected 120 booked minutes, got %d", report.BookedMinutes)
+	}
+	if report.OccupiedMinutes != 120 {
+		t.Errorf("expected 120 occupied minutes, got %d", report.OccupiedMinutes)
+	}
+	if report.GuestMinutes != 60 {
+		t.Errorf("expected 60 guest minutes, got %d", report.GuestMinutes)
+	}
+	if report.Slots[0].OccupiedMinutes != 60 || report.Slots[1].GuestMinutes != 60 {
+		t.Errorf("unexpected slot occupancy %+v", report.Slots)
+	}
+}
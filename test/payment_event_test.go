@@ -0,0 +1,214 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/validation"
+	"gorm.io/gorm"
+)
+
+// seedUnpaidGuestSession creates a guest session billed 10000 with its invoice at the fake provider.
+func seedUnpaidGuestSession(t *testing.T, db *gorm.DB, provider *paymentgateway.FakeProvider, parking *models.Parking, slot *models.ParkingSlot) (*models.ParkingSession, *paymentgateway.Invoice) {
+	t.Helper()
+
+	reference := "PKGO-GST-" + pkg.RandomString(8)
+	paymentInvoice, err := provider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{ExternalID: reference, Amount: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exitedAt := pkg.GetCurrentTime()
+	session := &models.ParkingSession{
+		ParkingID:        parking.ID,
+		SlotID:           slot.ID,
+		PlateNumber:      "KB 1234 TST",
+		EnteredAt:        exitedAt.Add(-2 * time.Hour),
+		ExitedAt:         &exitedAt,
+		TotalHours:       2,
+		Fee:              10000,
+		PaymentReference: reference,
+		PaymentInvoiceID: paymentInvoice.ID,
+		Status:           models.ParkingSessionStatusUnpaid,
+	}
+	err = db.Create(session).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("external_id = ?", reference).Delete(&models.PaymentEvent{})
+		db.Delete(session)
+	})
+
+	return session, paymentInvoice
+}
+
+func TestPaymentService_DuplicateWebhookDelivery(t *testing.T) {
+	db := loadTestDatabase(t)
+	validate := validation.New()
+	provider := paymentgateway.NewFakeProvider()
+	ledger := services.NewLedgerService(db)
+	sessionService := services.NewParkingSessionService(db, validate, provider, services.NewPricingService(db, validate), ledger)
+	paymentService := services.NewPaymentService(db, provider, nil, nil, sessionService)
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	parking, slot := seedTestParking(t, db, &user)
+
+	session, paymentInvoice := seedUnpaidGuestSession(t, db, provider, parking, slot)
+	reference := session.PaymentReference
+
+	payload, err := provider.Pay(paymentInvoice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The gateway delivers the same callback again when it did not see the first response in time
+	for i := 0; i < 3; i++ {
+		_, err = paymentService.HandleWebhook(http.Header{}, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var paymentEvent models.PaymentEvent
+	err = db.Where("external_id = ?", reference).First(&paymentEvent).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paymentEvent.DeliveryCount != 3 || paymentEvent.Attempts != 1 {
+		t.Fatalf("expected 3 deliveries processed once, got %d deliveries and %d attempts", paymentEvent.DeliveryCount, paymentEvent.Attempts)
+	}
+	if paymentEvent.ProcessingStatus != models.PaymentEventStatusProcessed {
+		t.Fatalf("expected PROCESSED event, got %s", paymentEvent.ProcessingStatus)
+	}
+
+	var payments int64
+	err = db.Model(&models.LedgerEntry{}).
+		Where("reference = ? AND account = ?", reference, models.LedgerAccountParkingAvailable).
+		Count(&payments).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payments != 1 {
+		t.Fatalf("expected the payment to be recorded once, got %d", payments)
+	}
+
+	// An event left processing by a crash can be replayed once it timed out
+	err = db.Model(&models.PaymentEvent{}).Where("id = ?", paymentEvent.ID).Updates(map[string]interface{}{
+		"processing_status": models.PaymentEventStatusProcessing,
+		"updated_at":        pkg.GetCurrentTime().Add(-time.Hour),
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := paymentService.ReplayPaymentEvent(paymentEvent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ProcessingStatus != models.PaymentEventStatusProcessed || replayed.Attempts != 2 {
+		t.Fatalf("expected the stale event to be processed again, got %s after %d attempts", replayed.ProcessingStatus, replayed.Attempts)
+	}
+}
+
+func TestPaymentService_ShortPaymentLeftForReview(t *testing.T) {
+	db := loadTestDatabase(t)
+	validate := validation.New()
+	provider := paymentgateway.NewFakeProvider()
+	ledger := services.NewLedgerService(db)
+	sessionService := services.NewParkingSessionService(db, validate, provider, services.NewPricingService(db, validate), ledger)
+	paymentService := services.NewPaymentService(db, provider, nil, nil, sessionService)
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	parking, slot := seedTestParking(t, db, &user)
+	session, paymentInvoice := seedUnpaidGuestSession(t, db, provider, parking, slot)
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          paymentInvoice.ID,
+		"external_id": session.PaymentReference,
+		"status":      paymentgateway.InvoiceStatusPaid,
+		"amount":      session.Fee,
+		"paid_amount": session.Fee / 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Whether the delivery is answered with an error is up to the gateway handling, the event itself must not apply
+	paymentService.HandleWebhook(http.Header{}, payload)
+
+	var paymentEvent models.PaymentEvent
+	err = db.Where("external_id = ?", session.PaymentReference).First(&paymentEvent).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paymentEvent.ProcessingStatus != models.PaymentEventStatusFailed {
+		t.Errorf("expected the short payment to be left FAILED, got %s", paymentEvent.ProcessingStatus)
+	}
+
+	var stored models.ParkingSession
+	err = db.First(&stored, session.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.ParkingSessionStatusUnpaid {
+		t.Errorf("expected the session to stay UNPAID, got %s", stored.Status)
+	}
+}
+
+func TestPaymentService_PayFakeInvoice(t *testing.T) {
+	db := loadTestDatabase(t)
+	validate := validation.New()
+	provider := paymentgateway.NewFakeProvider()
+	ledger := services.NewLedgerService(db)
+	sessionService := services.NewParkingSessionService(db, validate, provider, services.NewPricingService(db, validate), ledger)
+	paymentService := services.NewPaymentService(db, provider, nil, nil, sessionService)
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	parking, slot := seedTestParking(t, db, &user)
+	session, paymentInvoice := seedUnpaidGuestSession(t, db, provider, parking, slot)
+
+	// Following the invoice link pays the invoice and delivers its webhook
+	paymentEvent, err := paymentService.PayFakeInvoice(paymentInvoice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paymentEvent.ProcessingStatus != models.PaymentEventStatusProcessed {
+		t.Errorf("expected the payment to be processed, got %s", paymentEvent.ProcessingStatus)
+	}
+
+	var stored models.ParkingSession
+	err = db.First(&stored, session.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.ParkingSessionStatusPaid {
+		t.Errorf("expected the session to be PAID, got %s", stored.Status)
+	}
+
+	_, err = paymentService.PayFakeInvoice(paymentInvoice.ID)
+	if err == nil {
+		t.Error("expected a paid invoice not to be paid again")
+	}
+	_, err = paymentService.PayFakeInvoice("fake-inv-unknown")
+	if err == nil {
+		t.Error("expected an unknown invoice to be rejected")
+	}
+}
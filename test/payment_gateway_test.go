@@ -0,0 +1,78 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+)
+
+func TestFakePaymentProvider_InvoiceFlow(t *testing.T) {
+	provider := paymentgateway.NewFakeProvider()
+
+	paymentInvoice, err := provider.CreateInvoice(&paymentgateway.CreateInvoiceRequest{
+		ExternalID: "PKGO-TEST0001",
+		Amount:     30000,
+		Currency:   "IDR",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paymentInvoice.Status != paymentgateway.InvoiceStatusPending {
+		t.Fatalf("expected PENDING invoice, got %s", paymentInvoice.Status)
+	}
+
+	payload, err := provider.Pay(paymentInvoice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := provider.ParseWebhook(http.Header{}, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !event.IsPaid() || event.ExternalID != "PKGO-TEST0001" {
+		t.Fatalf("unexpected webhook event: %+v", event)
+	}
+
+	paymentRefund, err := provider.Refund(&paymentgateway.RefundRequest{
+		InvoiceID:   paymentInvoice.ID,
+		ReferenceID: "PKGO-TEST0001-RFD",
+		Amount:      10000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paymentRefund.Amount != 10000 {
+		t.Fatalf("expected refund of 10000, got %.2f", paymentRefund.Amount)
+	}
+
+	_, err = provider.Refund(&paymentgateway.RefundRequest{
+		InvoiceID:   paymentInvoice.ID,
+		ReferenceID: "PKGO-TEST0001-RFD2",
+		Amount:      25000,
+	})
+	if err == nil {
+		t.Fatal("expected refund above paid amount to fail")
+	}
+}
+
+func TestFakePaymentProvider_RejectsInvalidToken(t *testing.T) {
+	provider := paymentgateway.NewFakeProvider()
+	provider.CallbackToken = "secret"
+
+	_, err := provider.ParseWebhook(http.Header{"X-Callback-Token": []string{"wrong"}}, []byte(`{}`))
+	if err != paymentgateway.ErrInvalidWebhookToken {
+		t.Fatalf("expected ErrInvalidWebhookToken, got %v", err)
+	}
+}
+
+func TestFakePaymentProvider_UnknownInvoice(t *testing.T) {
+	provider := paymentgateway.NewFakeProvider()
+
+	_, err := provider.GetInvoice("fake-inv-missing")
+	if !errors.Is(err, paymentgateway.ErrInvoiceNotFound) {
+		t.Fatalf("expected ErrInvoiceNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/disbursement"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/validation"
+)
+
+func TestPayoutTransitions(t *testing.T) {
+	cases := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{models.PayoutStatusRequested, models.PayoutStatusApproved, true},
+		{models.PayoutStatusRequested, models.PayoutStatusRejected, true},
+		{models.PayoutStatusRequested, models.PayoutStatusCompleted, false},
+		{models.PayoutStatusApproved, models.PayoutStatusProcessing, true},
+		{models.PayoutStatusApproved, models.PayoutStatusRejected, false},
+		{models.PayoutStatusProcessing, models.PayoutStatusCompleted, true},
+		{models.PayoutStatusProcessing, models.PayoutStatusFailed, true},
+		{models.PayoutStatusCompleted, models.PayoutStatusFailed, false},
+		{models.PayoutStatusFailed, models.PayoutStatusProcessing, false},
+	}
+
+	for _, c := range cases {
+		if models.CanTransitionPayout(c.from, c.to) != c.allowed {
+			t.Errorf("transition %s -> %s: expected allowed=%v", c.from, c.to, c.allowed)
+		}
+	}
+}
+
+func TestFakeDisbursementProvider_PayoutFlow(t *testing.T) {
+	provider := disbursement.NewFakeProvider()
+
+	req := &disbursement.CreatePayoutRequest{
+		ReferenceID:   "PKGO-PO-TEST0001",
+		ChannelCode:   "ID_BCA",
+		AccountNumber: "1234567890",
+		Amount:        50000,
+		Currency:      "IDR",
+	}
+	payout, err := provider.CreatePayout(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payout.Status != disbursement.PayoutStatusPending {
+		t.Fatalf("expected PENDING payout, got %s", payout.Status)
+	}
+
+	again, err := provider.CreatePayout(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != payout.ID {
+		t.Fatal("expected the same reference to return the same payout")
+	}
+
+	payout, err = provider.GetPayout(payout.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !payout.IsSucceeded() {
+		t.Fatalf("expected payout to succeed, got %s", payout.Status)
+	}
+
+	failed, err := provider.CreatePayout(&disbursement.CreatePayoutRequest{
+		ReferenceID:   "PKGO-PO-TEST0002",
+		AccountNumber: "1234567890",
+		Amount:        50000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.Fail(failed.ID, "INVALID_DESTINATION")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err = provider.GetPayout(failed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !failed.IsFailed() || failed.FailureCode != "INVALID_DESTINATION" {
+		t.Fatalf("unexpected failed payout: %+v", failed)
+	}
+}
+
+func TestPayoutService_RequestApproveLedger(t *testing.T) {
+	db := loadTestDatabase(t)
+	ledger := services.NewLedgerService(db)
+	payoutService := services.NewPayoutService(db, validation.New(), disbursement.NewFakeProvider(), ledger, services.NewMailService())
+
+	var user models.User
+	err := db.First(&user).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parking, _ := seedTestParking(t, db, &user)
+	t.Cleanup(func() {
+		db.Where("payout_id IN (?)", db.Model(&models.Payout{}).Select("id").Where("parking_id = ?", parking.ID)).Delete(&models.PayoutStatusHistory{})
+		db.Where("parking_id = ?", parking.ID).Delete(&models.Payout{})
+		db.Unscoped().Where("user_id = ? AND account_holder_name = ?", user.ID, parking.Slug).Delete(&models.BankAccount{})
+	})
+
+	err = ledger.PostTx(db, []models.LedgerEntry{
+		{ParkingID: parking.ID, Account: models.LedgerAccountParkingAvailable, Type: models.LedgerEntryOpeningBalance, Amount: 100000},
+		{ParkingID: parking.ID, Account: models.LedgerAccountGatewayClearing, Type: models.LedgerEntryOpeningBalance, Amount: -100000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bankAccount := &models.BankAccount{UserID: user.ID, BankCode: "ID_BCA", AccountNumber: "1234567890", AccountHolderName: parking.Slug}
+	err = db.Create(bankAccount).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = payoutService.RequestPayout(&user, parking.ID, &models.CreatePayoutRequest{BankAccountID: bankAccount.ID, Amount: 150000})
+	if !errors.Is(err, services.ErrInsufficientEarnings) {
+		t.Fatalf("expected ErrInsufficientEarnings, got %v", err)
+	}
+
+	payout, err := payoutService.RequestPayout(&user, parking.ID, &models.CreatePayoutRequest{BankAccountID: bankAccount.ID, Amount: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The requested amount counts as spent until the payout is reviewed
+	_, err = payoutService.RequestPayout(&user, parking.ID, &models.CreatePayoutRequest{BankAccountID: bankAccount.ID, Amount: 50000})
+	if !errors.Is(err, services.ErrInsufficientEarnings) {
+		t.Fatalf("expected ErrInsufficientEarnings, got %v", err)
+	}
+
+	payout, err = payoutService.ApprovePayout(user.ID, payout.ID, &models.ReviewPayoutRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payout.Status != models.PayoutStatusProcessing {
+		t.Fatalf("expected PROCESSING payout, got %s", payout.Status)
+	}
+
+	balance, err := models.LoadParkingBalance(db, parking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.AvailableEarnings != 40000 || balance.WithdrawnEarnings != 60000 {
+		t.Fatalf("expected 40000 available and 60000 withdrawn, got %+v", balance)
+	}
+
+	_, err = payoutService.SyncPayouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payout, err = payoutService.GetPayoutByID(payout.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payout.Status != models.PayoutStatusCompleted {
+		t.Fatalf("expected COMPLETED payout, got %s", payout.Status)
+	}
+
+	// A payout the provider refuses is returned to the available earnings
+	rejected := &models.BankAccount{UserID: user.ID, BankCode: "ID_BCA", AccountHolderName: parking.Slug}
+	err = db.Create(rejected).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err := payoutService.RequestPayout(&user, parking.ID, &models.CreatePayoutRequest{BankAccountID: rejected.ID, Amount: 40000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err = payoutService.ApprovePayout(user.ID, failed.ID, &models.ReviewPayoutRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != models.PayoutStatusFailed {
+		t.Fatalf("expected FAILED payout, got %s", failed.Status)
+	}
+
+	balance, err = models.LoadParkingBalance(db, parking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.AvailableEarnings != 40000 || balance.WithdrawnEarnings != 60000 {
+		t.Fatalf("expected the failed payout to be returned, got %+v", balance)
+	}
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+var jakarta, _ = time.LoadLocation("Asia/Jakarta")
+
+func TestCalculatePrice_BaseRate(t *testing.T) {
+	startAt := time.Date(2026, 3, 2, 8, 0, 0, 0, jakarta)
+	price := services.CalculatePrice(5000, nil, startAt, startAt.Add(3*time.Hour))
+
+	if price.TotalHours != 3 || price.Total != 15000 {
+		t.Fatalf("expected 3 hours for 15000, got %d hours for %.2f", price.TotalHours, price.Total)
+	}
+	if len(price.Items) != 1 || price.Items[0].Quantity != 3 {
+		t.Fatalf("expected a single line item of 3 hours, got %+v", price.Items)
+	}
+}
+
+func TestCalculatePrice_PartialHour(t *testing.T) {
+	startAt := time.Date(2026, 3, 2, 8, 0, 0, 0, jakarta)
+
+	// A started hour is charged in full
+	price := services.CalculatePrice(5000, nil, startAt, startAt.Add(2*time.Hour+time.Minute))
+	if price.TotalHours != 3 || price.Total != 15000 {
+		t.Fatalf("expected 3 hours for 15000, got %d hours for %.2f", price.TotalHours, price.Total)
+	}
+
+	price = services.CalculatePrice(5000, nil, startAt, startAt.Add(30*time.Minute))
+	if price.TotalHours != 1 || price.Total != 5000 {
+		t.Fatalf("expected 1 hour for 5000, got %d hours for %.2f", price.TotalHours, price.Total)
+	}
+}
+
+func TestCalculatePrice_Rules(t *testing.T) {
+	eventStart := time.Date(2026, 3, 7, 18, 0, 0, 0, jakarta)
+	eventEnd := eventStart.Add(2 * time.Hour)
+	rules := []models.PricingRule{
+		{Name: "First hour", Type: models.PricingRuleFirstHour, Rate: 2000},
+		{Name: "Peak", Type: models.PricingRuleTimeBand, StartTime: "17:00", EndTime: "19:00", Rate: 8000},
+		{Name: "Weekend", Type: models.PricingRuleDayRate, DaysOfWeek: []int{0, 6}, Rate: 6000},
+		{Name: "Concert", Type: models.PricingRuleEvent, StartsAt: &eventStart, EndsAt: &eventEnd, Rate: 20000},
+	}
+
+	// Monday 16:00 - 19:00: first hour, then two peak hours
+	startAt := time.Date(2026, 3, 2, 16, 0, 0, 0, jakarta)
+	price := services.CalculatePrice(5000, rules, startAt, startAt.Add(3*time.Hour))
+	if price.Total != 2000+8000+8000 {
+		t.Fatalf("expected weekday total 18000, got %.2f (%+v)", price.Total, price.Items)
+	}
+
+	// Saturday 17:00 - 20:00: first hour, then the event overrides the peak band
+	startAt = time.Date(2026, 3, 7, 17, 0, 0, 0, jakarta)
+	price = services.CalculatePrice(5000, rules, startAt, startAt.Add(3*time.Hour))
+	if price.Total != 2000+20000+20000 {
+		t.Fatalf("expected event total 42000, got %.2f (%+v)", price.Total, price.Items)
+	}
+
+	// Sunday 09:00 - 12:00: first hour, then weekend rate
+	startAt = time.Date(2026, 3, 8, 9, 0, 0, 0, jakarta)
+	price = services.CalculatePrice(5000, rules, startAt, startAt.Add(3*time.Hour))
+	if price.Total != 2000+6000+6000 {
+		t.Fatalf("expected weekend total 14000, got %.2f (%+v)", price.Total, price.Items)
+	}
+}
+
+func TestCalculatePrice_DailyCap(t *testing.T) {
+	rules := []models.PricingRule{
+		{Name: "Daily cap", Type: models.PricingRuleDailyCap, MaxFee: 30000},
+	}
+
+	// 20:00 Monday until 20:00 Tuesday, 4 hours on Monday and 20 hours on Tuesday
+	startAt := time.Date(2026, 3, 2, 20, 0, 0, 0, jakarta)
+	price := services.CalculatePrice(5000, rules, startAt, startAt.Add(24*time.Hour))
+
+	if price.Subtotal != 120000 {
+		t.Fatalf("expected subtotal 120000, got %.2f", price.Subtotal)
+	}
+	if len(price.Adjustments) != 1 || price.Adjustments[0].Amount != -70000 {
+		t.Fatalf("expected a single -70000 cap adjustment, got %+v", price.Adjustments)
+	}
+	if price.Total != 20000+30000 {
+		t.Fatalf("expected total 50000, got %.2f", price.Total)
+	}
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+)
+
+func TestRankSlots(t *testing.T) {
+	entrances := services.LayoutEntrances([]byte(`[["P","P","P"],["E","","P"]]`))
+	if len(entrances) != 1 || entrances[0] != [2]int{1, 0} {
+		t.Fatalf("expected a single entrance at 1,0, got %v", entrances)
+	}
+
+	slots := []*models.ParkingSlot{
+		{ID: 1, Name: "P00", Row: 0, Col: 0, Attributes: []string{"EV_CHARGER"}},
+		{ID: 2, Name: "P01", Row: 0, Col: 1},
+		{ID: 3, Name: "P02", Row: 0, Col: 2},
+		{ID: 4, Name: "P12", Row: 1, Col: 2, Attributes: []string{"wide"}},
+	}
+	usage := map[int]int{1: 5, 2: 0, 3: 2, 4: 1}
+
+	candidates := func(required []string) []*services.SlotCandidate {
+		var candidates []*services.SlotCandidate
+		for _, slot := range slots {
+			if candidate := services.NewSlotCandidate(slot, entrances, required, usage[slot.ID]); candidate != nil {
+				candidates = append(candidates, candidate)
+			}
+		}
+		return candidates
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		required []string
+		expected string
+	}{
+		{"closest", models.SlotAssignmentClosest, nil, "P00"},
+		{"balanced", models.SlotAssignmentBalanced, nil, "P01"},
+		{"best fit keeps attribute slots free", models.SlotAssignmentBestFit, nil, "P01"},
+		{"required attribute", models.SlotAssignmentClosest, []string{"WIDE"}, "P12"},
+		{"unknown strategy is closest", "", nil, "P00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := candidates(tt.required)
+			services.RankSlots(ranked, services.NewSlotAssignmentStrategy(tt.strategy))
+			if ranked[0].Slot.Name != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, ranked[0].Slot.Name)
+			}
+		})
+	}
+
+	if len(candidates([]string{"EV_CHARGER", "WIDE"})) != 0 {
+		t.Errorf("expected no slot with both EV_CHARGER and WIDE")
+	}
+}
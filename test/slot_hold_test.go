@@ -0,0 +1,33 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+)
+
+func TestSlotHoldIsActive(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		status    string
+		expiresAt time.Time
+		expected  bool
+	}{
+		{"active", models.SlotHoldStatusActive, now.Add(time.Minute), true},
+		{"expired", models.SlotHoldStatusActive, now, false},
+		{"consumed", models.SlotHoldStatusConsumed, now.Add(time.Minute), false},
+		{"released", models.SlotHoldStatusReleased, now.Add(time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hold := &models.SlotHold{Status: tt.status, ExpiresAt: tt.expiresAt}
+			if hold.IsActive(now) != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, hold.IsActive(now))
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/paymentgateway"
+	"github.com/agilistikmal/parkingo-core/internal/infrastructure/validation"
+)
+
+func TestSubscriptionPeriodEnd(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	start := time.Date(2026, 1, 31, 8, 0, 0, 0, loc)
+
+	weekly := services.SubscriptionPeriodEnd(models.SubscriptionPeriodWeekly, start)
+	if !weekly.Equal(time.Date(2026, 2, 7, 8, 0, 0, 0, loc)) {
+		t.Errorf("expected weekly period to end on 2026-02-07, got %s", weekly)
+	}
+
+	monthly := services.SubscriptionPeriodEnd(models.SubscriptionPeriodMonthly, start)
+	if !monthly.Equal(time.Date(2026, 3, 3, 8, 0, 0, 0, loc)) {
+		t.Errorf("expected monthly period to end on 2026-03-03, got %s", monthly)
+	}
+}
+
+func TestSubscriptionPlanCovers(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Jakarta")
+	// Monday
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, loc)
+
+	workdays := &models.SubscriptionPlan{DaysOfWeek: []int{1, 2, 3, 4, 5}, StartTime: "07:00", EndTime: "19:00"}
+	night := &models.SubscriptionPlan{StartTime: "22:00", EndTime: "06:00"}
+	anytime := &models.SubscriptionPlan{}
+
+	cases := []struct {
+		name   string
+		plan   *models.SubscriptionPlan
+		at     time.Time
+		covers bool
+	}{
+		{"workday office hours", workdays, monday.Add(9 * time.Hour), true},
+		{"workday before opening", workdays, monday.Add(6 * time.Hour), false},
+		{"workday at closing", workdays, monday.Add(19 * time.Hour), false},
+		{"weekend", workdays, monday.AddDate(0, 0, 5).Add(9 * time.Hour), false},
+		{"night before midnight", night, monday.Add(23 * time.Hour), true},
+		{"night after midnight", night, monday.Add(5 * time.Hour), true},
+		{"night during the day", night, monday.Add(12 * time.Hour), false},
+		{"unrestricted", anytime, monday.AddDate(0, 0, 6), true},
+	}
+
+	for _, c := range cases {
+		if services.SubscriptionPlanCovers(c.plan, c.at) != c.covers {
+			t.Errorf("%s: expected covers to be %v", c.name, c.covers)
+		}
+	}
+}
+
+func TestSubscriptionService_LatePaymentRefundedWhenPlanIsFull(t *testing.T) {
+	db := loadTestDatabase(t)
+	paymentProvider := paymentgateway.NewFakeProvider()
+	subscriptionService := services.NewSubscriptionService(db, validation.New(), paymentProvider, services.NewLedgerService(db), services.NewMailService())
+
+	late := seedTestUser(t, db, "subscriber", "USER")
+	parking, _ := seedTestParking(t, db, late)
+	plan := &models.SubscriptionPlan{ParkingID: parking.ID, Name: "Monthly", Period: models.SubscriptionPeriodMonthly, Price: 100000, Capacity: 1, IsActive: true}
+	err := db.Create(plan).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliver := func(payload []byte) {
+		t.Helper()
+		event, err := paymentProvider.ParseWebhook(http.Header{}, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = subscriptionService.ProcessPaymentEvent(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	invoiceOf := func(subscription *models.Subscription) *models.SubscriptionInvoice {
+		t.Helper()
+		var invoice models.SubscriptionInvoice
+		err := db.Where("subscription_id = ?", subscription.ID).First(&invoice).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &invoice
+	}
+
+	// The first subscription lets its invoice expire, giving its place to another subscriber who pays
+	lateSubscription, err := subscriptionService.Subscribe(late, &models.CreateSubscriptionRequest{PlanID: plan.ID, PlateNumber: "KB 1 LATE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lateInvoice := invoiceOf(lateSubscription)
+	deliver([]byte(fmt.Sprintf(`{"id":%q,"status":%q}`, lateInvoice.PaymentInvoiceID, paymentgateway.InvoiceStatusExpired)))
+
+	other := seedTestUser(t, db, "subscriber", "USER")
+	otherSubscription, err := subscriptionService.Subscribe(other, &models.CreateSubscriptionRequest{PlanID: plan.ID, PlateNumber: "KB 2 PAID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := paymentProvider.Pay(invoiceOf(otherSubscription).PaymentInvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliver(payload)
+
+	// The expired invoice is paid anyway while the plan is full
+	payload, err = paymentProvider.Pay(lateInvoice.PaymentInvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliver(payload)
+
+	lateInvoice = invoiceOf(lateSubscription)
+	if lateInvoice.Status != models.SubscriptionInvoiceStatusRefunded || lateInvoice.PaidAt == nil {
+		t.Errorf("expected a paid and REFUNDED invoice, got %s", lateInvoice.Status)
+	}
+
+	stored, err := subscriptionService.GetSubscriptionByID(lateSubscription.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.SubscriptionStatusExpired {
+		t.Errorf("expected the late subscription to stay EXPIRED, got %s", stored.Status)
+	}
+
+	_, err = paymentProvider.Refund(&paymentgateway.RefundRequest{InvoiceID: lateInvoice.PaymentInvoiceID, Amount: 1})
+	if err == nil {
+		t.Error("expected the late payment to be refunded in full")
+	}
+}
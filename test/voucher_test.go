@@ -0,0 +1,226 @@
+package test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agilistikmal/parkingo-core/internal/app/models"
+	"github.com/agilistikmal/parkingo-core/internal/app/pkg"
+	"github.com/agilistikmal/parkingo-core/internal/app/services"
+	"gorm.io/gorm"
+)
+
+func TestCalculateVoucherDiscount(t *testing.T) {
+	cases := []struct {
+		name     string
+		voucher  *models.Voucher
+		amount   float64
+		discount float64
+	}{
+		{"no voucher", nil, 20000, 0},
+		{"percentage", &models.Voucher{DiscountType: models.VoucherDiscountPercentage, DiscountValue: 10}, 20000, 2000},
+		{"percentage rounded down", &models.Voucher{DiscountType: models.VoucherDiscountPercentage, DiscountValue: 15}, 3333, 499},
+		{"percentage capped", &models.Voucher{DiscountType: models.VoucherDiscountPercentage, DiscountValue: 50, MaxDiscount: 5000}, 20000, 5000},
+		{"fixed", &models.Voucher{DiscountType: models.VoucherDiscountFixed, DiscountValue: 5000}, 20000, 5000},
+		{"fixed capped at amount", &models.Voucher{DiscountType: models.VoucherDiscountFixed, DiscountValue: 5000}, 3000, 3000},
+		{"free booking", &models.Voucher{DiscountType: models.VoucherDiscountFixed, DiscountValue: 5000}, 0, 0},
+	}
+
+	for _, c := range cases {
+		discount := services.CalculateVoucherDiscount(c.voucher, c.amount)
+		if discount != c.discount {
+			t.Errorf("%s: expected discount of %.2f, got %.2f", c.name, c.discount, discount)
+		}
+	}
+}
+
+// seedTestVoucher creates a fixed voucher of 1000 under a random code, removed again with its redemptions when the
+// test ends. Seed it before the parking so the bookings using it are removed first.
+func seedTestVoucher(t *testing.T, db *gorm.DB, voucher *models.Voucher) *models.Voucher {
+	t.Helper()
+
+	voucher.Code = "TEST" + strings.ToUpper(pkg.RandomString(8))
+	voucher.DiscountType = models.VoucherDiscountFixed
+	voucher.DiscountValue = 1000
+	voucher.IsActive = true
+	err := db.Create(voucher).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Where("voucher_id = ?", voucher.ID).Delete(&models.VoucherRedemption{})
+		db.Unscoped().Delete(voucher)
+	})
+
+	return voucher
+}
+
+func TestCreateBooking_VoucherUsageLimits(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, _ := newTestBookingService(db)
+
+	first := seedTestUser(t, db, "first", "USER")
+	second := seedTestUser(t, db, "second", "USER")
+	third := seedTestUser(t, db, "third", "USER")
+	voucher := seedTestVoucher(t, db, &models.Voucher{UsageLimit: 2, UsageLimitPerUser: 1})
+	parking, slot := seedTestParking(t, db, first)
+
+	// Use a far future window so earlier runs never collide with this one, every booking gets its own day
+	startAt := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour)
+	book := func(user *models.User, days int) (*models.Booking, error) {
+		return bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+			ParkingID:   parking.ID,
+			SlotID:      slot.ID,
+			PlateNumber: "KB 1234 TST",
+			StartAt:     startAt.AddDate(0, 0, days),
+			EndAt:       startAt.AddDate(0, 0, days).Add(2 * time.Hour),
+			VoucherCode: voucher.Code,
+		})
+	}
+
+	booking, err := book(first, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.VoucherDiscount != 1000 || booking.TotalFee != 9000 {
+		t.Errorf("expected a discount of 1000 on a fee of 9000, got %.2f on %.2f", booking.VoucherDiscount, booking.TotalFee)
+	}
+
+	_, err = book(first, 1)
+	if err == nil {
+		t.Error("expected a second use by the same user to be rejected")
+	}
+
+	_, err = book(second, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = book(third, 3)
+	if err == nil {
+		t.Error("expected a use past the usage limit to be rejected")
+	}
+
+	var redemptions int64
+	db.Model(&models.VoucherRedemption{}).Where("voucher_id = ?", voucher.ID).Count(&redemptions)
+	if redemptions != 2 {
+		t.Errorf("expected 2 redemptions, got %d", redemptions)
+	}
+}
+
+func TestCreateBooking_VoucherConcurrentRedemption(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, _ := newTestBookingService(db)
+
+	const attempts = 5
+	users := make([]*models.User, attempts)
+	for i := range users {
+		users[i] = seedTestUser(t, db, fmt.Sprintf("user%d", i), "USER")
+	}
+	voucher := seedTestVoucher(t, db, &models.Voucher{UsageLimit: 1})
+	parking, slot := seedTestParking(t, db, users[0])
+
+	// Every user books another day so only the voucher is contended
+	startAt := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour)
+	var wg sync.WaitGroup
+	results := make(chan error, attempts)
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, user *models.User) {
+			defer wg.Done()
+			_, err := bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+				ParkingID:   parking.ID,
+				SlotID:      slot.ID,
+				PlateNumber: "KB 1234 TST",
+				StartAt:     startAt.AddDate(0, 0, i),
+				EndAt:       startAt.AddDate(0, 0, i).Add(2 * time.Hour),
+				VoucherCode: voucher.Code,
+			})
+			results <- err
+		}(i, user)
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("expected exactly 1 booking to redeem the voucher, got %d", succeeded)
+	}
+
+	var redemptions int64
+	db.Model(&models.VoucherRedemption{}).
+		Where("voucher_id = ? AND status = ?", voucher.ID, models.VoucherRedemptionStatusActive).
+		Count(&redemptions)
+	if redemptions != 1 {
+		t.Errorf("expected 1 active redemption, got %d", redemptions)
+	}
+}
+
+func TestVoucherRedemption_ReleasedWhenBookingIsNotPaid(t *testing.T) {
+	db := loadTestDatabase(t)
+	bookingService, _ := newTestBookingService(db)
+
+	user := seedTestUser(t, db, "voucher", "USER")
+	voucher := seedTestVoucher(t, db, &models.Voucher{UsageLimit: 1})
+	parking, slot := seedTestParking(t, db, user)
+
+	startAt := pkg.GetCurrentTime().AddDate(1, 0, rand.Intn(3000)).Truncate(time.Hour)
+	book := func(days int) *models.Booking {
+		booking, err := bookingService.CreateBooking(user.ID, &models.CreateBookingRequest{
+			ParkingID:   parking.ID,
+			SlotID:      slot.ID,
+			PlateNumber: "KB 1234 TST",
+			StartAt:     startAt.AddDate(0, 0, days),
+			EndAt:       startAt.AddDate(0, 0, days).Add(2 * time.Hour),
+			VoucherCode: voucher.Code,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return booking
+	}
+	redemptionStatus := func(booking *models.Booking) string {
+		var redemption models.VoucherRedemption
+		err := db.Where("booking_id = ?", booking.ID).First(&redemption).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		return redemption.Status
+	}
+
+	// Canceling the unpaid booking gives the only use of the voucher back
+	canceled := book(0)
+	_, err := bookingService.CancelBooking(user.ID, canceled.ID, &models.CancelBookingRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := redemptionStatus(canceled); status != models.VoucherRedemptionStatusReleased {
+		t.Errorf("expected the redemption of the canceled booking to be RELEASED, got %s", status)
+	}
+
+	// So does letting the invoice of the next booking expire
+	expired := book(1)
+	db.Model(expired).Update("payment_expired_at", pkg.GetCurrentTime().Add(-time.Minute))
+	_, err = bookingService.ExpireUnpaidBookings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := redemptionStatus(expired); status != models.VoucherRedemptionStatusReleased {
+		t.Errorf("expected the redemption of the expired booking to be RELEASED, got %s", status)
+	}
+
+	last := book(2)
+	if status := redemptionStatus(last); status != models.VoucherRedemptionStatusActive {
+		t.Errorf("expected the redemption of the last booking to be ACTIVE, got %s", status)
+	}
+}